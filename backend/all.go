@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/falcon/backend/internal/config"
+	"github.com/falcon/backend/internal/database"
+	"github.com/falcon/backend/internal/events"
+	"github.com/falcon/backend/internal/logging"
+	"github.com/falcon/backend/internal/metrics"
+	"github.com/falcon/backend/internal/storage"
+	"github.com/falcon/backend/internal/streamer"
+	"github.com/falcon/backend/internal/tracing"
+	"github.com/falcon/backend/internal/transcoder"
+	"github.com/falcon/backend/internal/uploader"
+	"github.com/falcon/backend/internal/validation"
+	"github.com/falcon/backend/internal/webhooks"
+	"github.com/go-redis/redis/v8"
+	"go.temporal.io/sdk/client"
+)
+
+// startAll runs the uploader, transcoder and streamer inside this process, sharing the
+// database pool, storage client and Temporal client. The uploader and streamer listen
+// on uploader.port and streamer.port, and the transcoder answers health probes on
+// transcoder.port. The returned function stops them.
+func startAll(cfg *config.Config, db *database.Database) func() {
+	// Set up storage service
+	storageService, err := storage.NewStorageService(cfg.Storage)
+	if err != nil {
+		logging.Fatal("Failed to initialize storage service", "error", err)
+	}
+
+	// Seed the default profile from the ffmpeg config section
+	defaultProfile, err := cfg.FFmpeg.DefaultProfile()
+	if err != nil {
+		logging.Fatal("Invalid default profile", "error", err)
+	}
+	if err := db.SeedProfile(context.Background(), defaultProfile); err != nil {
+		logging.Fatal("Failed to seed default profile", "error", err)
+	}
+
+	// Set up Temporal client
+	temporalClient, err := tracing.NewTemporalClient(client.Options{
+		HostPort:  cfg.Temporal.HostPort(),
+		Namespace: cfg.Temporal.Namespace,
+		Logger:    logging.Temporal(),
+	})
+	if err != nil {
+		logging.Fatal("Unable to create Temporal client", "error", err)
+	}
+
+	// Set up Redis client
+	redisClient := redis.NewClient(cfg.Redis.Options())
+	if _, err := redisClient.Ping(context.Background()).Result(); err != nil {
+		logging.Fatal("Failed to connect to Redis", "error", err)
+	}
+
+	// Set up FFmpeg
+	ff := cfg.FFmpeg.NewFFmpeg()
+
+	// Start the transcoder worker
+	deps := &transcoder.ActivityDependencies{
+		Storage: storageService,
+		DB:      db,
+		FFmpeg:  ff,
+		Events:  events.NewPublisher(redisClient),
+	}
+	w := transcoder.NewWorker(temporalClient, deps)
+	if err := w.Start(); err != nil {
+		logging.Fatal("Unable to start worker", "error", err)
+	}
+	slog.Info("Transcoder worker started")
+
+	// Start the webhook worker
+	webhookWorker := webhooks.NewWorker(temporalClient, &webhooks.ActivityDependencies{
+		DB:     db,
+		Client: webhooks.NewHTTPClient(cfg.Webhooks.Timeout),
+	})
+	if err := webhookWorker.Start(); err != nil {
+		logging.Fatal("Unable to start webhook worker", "error", err)
+	}
+	slog.Info("Webhook worker started")
+
+	// Set up the uploader
+	validator := validation.NewValidator(ff, cfg.Upload.Validation)
+	uploadHandler := uploader.NewUploadHandler(storageService, db, temporalClient, validator, cfg.Upload.DedupePolicy, cfg.Uploader.MaxUploadSize)
+	profileHandler := uploader.NewProfileHandler(db)
+	webhookHandler := uploader.NewWebhookHandler(db, temporalClient)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go metrics.PollTaskQueues(ctx, temporalClient, cfg.Temporal.Namespace,
+		[]string{transcoder.TaskQueue, webhooks.TaskQueue}, cfg.Metrics.BacklogPollInterval)
+	go uploadHandler.RetryFailedSchedules(ctx, cfg.Upload.ScheduleRetryInterval)
+
+	// Set up the streamer, sharing one Redis subscription between every event stream
+	hubCtx, stopHub := context.WithCancel(context.Background())
+	hub := events.NewHub(redisClient)
+	go hub.Run(hubCtx)
+
+	streamerHandler := &streamer.StreamerHandler{
+		DB:      db,
+		Storage: storageService,
+		Redis:   redisClient,
+		Events:  hub,
+	}
+
+	// Each service keeps its own listener and health routes, so probes and the
+	// main service's readiness report work the same as with separate binaries
+	uploaderChecker := uploader.NewChecker(cfg.Health.Timeout, db, storageService, temporalClient, ff)
+	streamerChecker := streamer.NewChecker(cfg.Health.Timeout, db, storageService, redisClient)
+	transcoderChecker := transcoder.NewChecker(cfg.Health.Timeout, temporalClient, deps)
+
+	servers := map[string]*http.Server{
+		"uploader": uploader.NewServer(fmt.Sprintf(":%d", cfg.Uploader.Port), uploader.NewRouter(uploadHandler, profileHandler, webhookHandler, uploaderChecker),
+			cfg.Uploader.ReadTimeout, cfg.Uploader.WriteTimeout),
+		"streamer": streamer.NewServer(fmt.Sprintf(":%d", cfg.Streamer.Port), streamer.NewRouter(streamerHandler, streamerChecker),
+			cfg.Streamer.ReadTimeout, cfg.Streamer.WriteTimeout),
+		"transcoder": transcoder.NewHealthServer(fmt.Sprintf(":%d", cfg.Transcoder.Port), transcoderChecker),
+	}
+	// End event streams when shutdown starts, they would hold it until the timeout
+	servers["streamer"].RegisterOnShutdown(stopHub)
+
+	for name, srv := range servers {
+		go func(name string, srv *http.Server) {
+			slog.Info("Service starting", "child", name, "addr", srv.Addr)
+			if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				logging.Fatal("Failed to start server", "child", name, "error", err)
+			}
+		}(name, srv)
+	}
+
+	return func() {
+		// Stop accepting requests before the shared clients go away
+		shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), 15*time.Second)
+		defer cancelShutdown()
+
+		var wg sync.WaitGroup
+		for name, srv := range servers {
+			wg.Add(1)
+			go func(name string, srv *http.Server) {
+				defer wg.Done()
+				if err := srv.Shutdown(shutdownCtx); err != nil {
+					slog.Error("Server shutdown failed", "child", name, "error", err)
+				}
+			}(name, srv)
+		}
+		wg.Wait()
+
+		cancel()
+		w.Stop()
+		webhookWorker.Stop()
+		temporalClient.Close()
+		redisClient.Close()
+		slog.Info("All services stopped")
+	}
+}
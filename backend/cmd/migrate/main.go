@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/falcon/backend/internal/config"
+	"github.com/falcon/backend/internal/database"
+)
+
+const usage = `Usage: migrate <command> [args]
+
+Commands:
+  up            Apply all pending migrations
+  down [n]      Revert the last n applied migrations (default 1)
+  status        List migrations and whether they are applied
+  to <version>  Migrate up or down to the given version (0 reverts everything)
+`
+
+func main() {
+	timeout := flag.Duration("timeout", 5*time.Minute, "Maximum time to wait for the migration lock and migrations")
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() < 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Set up database
+	db, err := database.NewDatabase(cfg.Database)
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	switch command := flag.Arg(0); command {
+	case "up":
+		err = db.Migrate(ctx)
+	case "down":
+		steps := 1
+		if flag.NArg() > 1 {
+			steps, err = strconv.Atoi(flag.Arg(1))
+			if err != nil || steps < 1 {
+				log.Fatalf("Invalid number of steps: %s", flag.Arg(1))
+			}
+		}
+		err = db.MigrateDown(ctx, steps)
+	case "to":
+		if flag.NArg() < 2 {
+			log.Fatal("Missing target version")
+		}
+		version, parseErr := strconv.ParseInt(flag.Arg(1), 10, 64)
+		if parseErr != nil || version < 0 {
+			log.Fatalf("Invalid version: %s", flag.Arg(1))
+		}
+		err = db.MigrateTo(ctx, version)
+	case "status":
+		err = printStatus(ctx, db)
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err != nil {
+		log.Fatalf("Migration failed: %v", err)
+	}
+
+	if flag.Arg(0) != "status" {
+		log.Println("Migration completed successfully")
+	}
+}
+
+// printStatus writes a table of migrations and their state to stdout
+func printStatus(ctx context.Context, db *database.Database) error {
+	statuses, err := db.MigrationStatus(ctx)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+	for _, status := range statuses {
+		state, appliedAt := "pending", ""
+		if status.Applied {
+			state = "applied"
+			appliedAt = status.AppliedAt.Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%04d\t%s\t%s\t%s\n", status.Version, status.Name, state, appliedAt)
+	}
+
+	return w.Flush()
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/falcon/backend/internal/storage"
+)
+
+// LocalSource ingests files from a local directory
+type LocalSource struct {
+	dir            string
+	storageService *storage.StorageService
+}
+
+// NewLocalSource creates a local directory source, creating its outcome folders
+func NewLocalSource(dir string, storageService *storage.StorageService) (*LocalSource, error) {
+	for _, outcome := range []string{OutcomeDone, OutcomeFailed} {
+		if err := os.MkdirAll(filepath.Join(dir, outcome), 0755); err != nil {
+			return nil, fmt.Errorf("failed to create %s folder: %v", outcome, err)
+		}
+	}
+
+	return &LocalSource{
+		dir:            dir,
+		storageService: storageService,
+	}, nil
+}
+
+// String returns a description of the source
+func (s *LocalSource) String() string {
+	return "directory " + s.dir
+}
+
+// List returns the regular files at the top level of the directory
+func (s *LocalSource) List(ctx context.Context) ([]Candidate, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory: %v", err)
+	}
+
+	var candidates []Candidate
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || !isIngestible(entry.Name()) {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			// File was removed between listing and stat
+			continue
+		}
+
+		_, err = os.Stat(s.path(entry.Name() + ".json"))
+		candidates = append(candidates, Candidate{
+			Name:       entry.Name(),
+			Size:       info.Size(),
+			ModTime:    info.ModTime(),
+			HasSidecar: err == nil,
+		})
+	}
+
+	return candidates, nil
+}
+
+// ReadSidecar reads the candidate's sidecar JSON file
+func (s *LocalSource) ReadSidecar(ctx context.Context, c Candidate) ([]byte, error) {
+	data, err := os.ReadFile(s.path(c.Name + ".json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sidecar: %v", err)
+	}
+	return data, nil
+}
+
+// Upload uploads the local file to storage
+func (s *LocalSource) Upload(ctx context.Context, c Candidate, objectKey string) error {
+	_, err := s.storageService.UploadFile(ctx, s.path(c.Name), objectKey)
+	return err
+}
+
+// Finish moves the file and its sidecar into the outcome folder and writes the report
+func (s *LocalSource) Finish(ctx context.Context, c Candidate, outcome string, report []byte) error {
+	target := filepath.Join(s.dir, outcome)
+
+	if err := os.Rename(s.path(c.Name), filepath.Join(target, c.Name)); err != nil {
+		return fmt.Errorf("failed to move file: %v", err)
+	}
+
+	if c.HasSidecar {
+		sidecar := c.Name + ".json"
+		if err := os.Rename(s.path(sidecar), filepath.Join(target, sidecar)); err != nil {
+			return fmt.Errorf("failed to move sidecar: %v", err)
+		}
+	}
+
+	if err := os.WriteFile(filepath.Join(target, c.Name+".report.json"), report, 0644); err != nil {
+		return fmt.Errorf("failed to write report: %v", err)
+	}
+
+	return nil
+}
+
+// path returns the absolute path of a file in the watched directory
+func (s *LocalSource) path(name string) string {
+	return filepath.Join(s.dir, name)
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/falcon/backend/internal/config"
+	"github.com/falcon/backend/internal/database"
+	"github.com/falcon/backend/internal/logging"
+	"github.com/falcon/backend/internal/storage"
+	"github.com/falcon/backend/internal/tracing"
+	"go.temporal.io/sdk/client"
+)
+
+// Set up logging
+func init() {
+	flag.Parse()
+	logging.Init("watcher")
+}
+
+func main() {
+	cfg := config.MustLoad()
+
+	// Export traces, continuing those started by other services
+	shutdownTracing, err := tracing.Init(context.Background(), "watcher", cfg.Tracing)
+	if err != nil {
+		logging.Fatal("Failed to initialize tracing", "error", err)
+	}
+	defer shutdownTracing(context.Background())
+
+	// Set up storage service
+	storageService, err := storage.NewStorageService(cfg.Storage)
+	if err != nil {
+		logging.Fatal("Failed to initialize storage service", "error", err)
+	}
+
+	// Set up database
+	db, err := database.NewDatabase(cfg.Database)
+	if err != nil {
+		logging.Fatal("Failed to connect to database", "error", err)
+	}
+	defer db.Close()
+
+	// Apply pending schema migrations
+	if err := db.Migrate(context.Background()); err != nil {
+		logging.Fatal("Failed to migrate database", "error", err)
+	}
+
+	// Set up Temporal client
+	temporalClient, err := tracing.NewTemporalClient(client.Options{
+		HostPort:  cfg.Temporal.HostPort(),
+		Namespace: cfg.Temporal.Namespace,
+		Logger:    logging.Temporal(),
+	})
+	if err != nil {
+		logging.Fatal("Unable to create Temporal client", "error", err)
+	}
+	defer temporalClient.Close()
+
+	// Select the ingest source
+	var src Source
+	switch cfg.Watcher.Source {
+	case "local":
+		if cfg.Watcher.Dir == "" {
+			logging.Fatal("watcher.dir must be set for the local source")
+		}
+		src, err = NewLocalSource(cfg.Watcher.Dir, storageService)
+		if err != nil {
+			logging.Fatal("Failed to initialize local source", "error", err)
+		}
+	case "s3":
+		src = NewS3Source(storageService, cfg.Watcher.S3Prefix)
+	default:
+		logging.Fatal("Unknown watcher source", "source", cfg.Watcher.Source)
+	}
+
+	watcher := NewWatcher(src, storageService, db, temporalClient,
+		cfg.Watcher.StableFor)
+
+	// Stop polling on interrupt
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	interval := cfg.Watcher.PollInterval
+	slog.Info("Watcher service polling", "source", src, "interval", interval)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		watcher.Poll(ctx)
+
+		select {
+		case <-ctx.Done():
+			slog.Info("Watcher service stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
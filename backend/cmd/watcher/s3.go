@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"strings"
+
+	"github.com/falcon/backend/internal/storage"
+)
+
+// S3Source ingests objects dropped under a prefix of the storage bucket
+type S3Source struct {
+	prefix         string
+	storageService *storage.StorageService
+}
+
+// NewS3Source creates a source watching the given bucket prefix
+func NewS3Source(storageService *storage.StorageService, prefix string) *S3Source {
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+
+	return &S3Source{
+		prefix:         prefix,
+		storageService: storageService,
+	}
+}
+
+// String returns a description of the source
+func (s *S3Source) String() string {
+	return "prefix " + s.prefix
+}
+
+// List returns the objects directly under the prefix
+func (s *S3Source) List(ctx context.Context) ([]Candidate, error) {
+	objects, err := s.storageService.ListObjects(ctx, s.prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]bool, len(objects))
+	for _, obj := range objects {
+		keys[obj.Key] = true
+	}
+
+	var candidates []Candidate
+	for _, obj := range objects {
+		name := strings.TrimPrefix(obj.Key, s.prefix)
+
+		// Skip nested keys, which include the outcome folders
+		if strings.Contains(name, "/") || !isIngestible(name) {
+			continue
+		}
+
+		candidates = append(candidates, Candidate{
+			Name:       name,
+			Size:       obj.Size,
+			ModTime:    obj.LastModified,
+			ETag:       obj.ETag,
+			HasSidecar: keys[obj.Key+".json"],
+		})
+	}
+
+	return candidates, nil
+}
+
+// ReadSidecar reads the candidate's sidecar JSON object
+func (s *S3Source) ReadSidecar(ctx context.Context, c Candidate) ([]byte, error) {
+	return s.storageService.ReadObject(ctx, s.prefix+c.Name+".json")
+}
+
+// Upload copies the object to the uploads location
+func (s *S3Source) Upload(ctx context.Context, c Candidate, objectKey string) error {
+	return s.storageService.CopyObject(ctx, s.prefix+c.Name, objectKey)
+}
+
+// Finish moves the object and its sidecar into the outcome folder and writes the report
+func (s *S3Source) Finish(ctx context.Context, c Candidate, outcome string, report []byte) error {
+	target := s.prefix + outcome + "/"
+
+	if err := s.storageService.MoveObject(ctx, s.prefix+c.Name, target+c.Name); err != nil {
+		return err
+	}
+
+	if c.HasSidecar {
+		sidecar := c.Name + ".json"
+		if err := s.storageService.MoveObject(ctx, s.prefix+sidecar, target+sidecar); err != nil {
+			return err
+		}
+	}
+
+	_, err := s.storageService.UploadBytes(ctx, report, target+c.Name+".report.json")
+	return err
+}
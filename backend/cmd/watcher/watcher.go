@@ -0,0 +1,340 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"mime"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/falcon/backend/internal/database"
+	"github.com/falcon/backend/internal/id"
+	"github.com/falcon/backend/internal/logging"
+	"github.com/falcon/backend/internal/storage"
+	"go.temporal.io/sdk/client"
+)
+
+// Outcome folders that processed files are moved into
+const (
+	OutcomeDone   = "done"
+	OutcomeFailed = "failed"
+)
+
+// errRetryLater is returned when a file was not ingested and stays in place to be
+// ingested again on a later poll
+var errRetryLater = errors.New("ingestion rolled back, retrying on a later poll")
+
+// Candidate is a file found in the watched location
+type Candidate struct {
+	Name       string
+	Size       int64
+	ModTime    time.Time
+	ETag       string
+	HasSidecar bool
+}
+
+// Source is a location the watcher ingests files from
+type Source interface {
+	fmt.Stringer
+
+	// List returns the files waiting to be ingested, excluding sidecars and outcome folders
+	List(ctx context.Context) ([]Candidate, error)
+
+	// ReadSidecar returns the content of the candidate's sidecar JSON file
+	ReadSidecar(ctx context.Context, c Candidate) ([]byte, error)
+
+	// Upload stores the candidate in storage under the given object key
+	Upload(ctx context.Context, c Candidate, objectKey string) error
+
+	// Finish moves the candidate and its sidecar into the outcome folder along with a report
+	Finish(ctx context.Context, c Candidate, outcome string, report []byte) error
+}
+
+// Sidecar holds optional video metadata supplied next to an ingested file as <file>.json
+type Sidecar struct {
+	Title       string            `json:"title"`
+	Description string            `json:"description"`
+	Tags        []string          `json:"tags"`
+	Metadata    map[string]string `json:"metadata"`
+	ContentType string            `json:"content_type"`
+	Profile     string            `json:"profile"`
+}
+
+// Report records the outcome of ingesting a single file
+type Report struct {
+	File       string    `json:"file"`
+	Status     string    `json:"status"`
+	VideoID    string    `json:"video_id,omitempty"`
+	ObjectKey  string    `json:"object_key,omitempty"`
+	WorkflowID string    `json:"workflow_id,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	Warning    string    `json:"warning,omitempty"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+}
+
+// observation tracks when a candidate was last seen changing
+type observation struct {
+	size    int64
+	modTime time.Time
+	etag    string
+	since   time.Time
+}
+
+// outcome is the result of an ingested file that still has to be moved out of the source
+type outcome struct {
+	folder string
+	report []byte
+}
+
+// Watcher polls a source and ingests files once they stop changing
+type Watcher struct {
+	source         Source
+	storageService *storage.StorageService
+	db             *database.Database
+	temporalClient client.Client
+	stableFor      time.Duration
+	seen           map[string]observation
+	unfinished     map[string]outcome // Ingested files that could not be moved yet
+	unscheduled    map[string]bool    // Videos to mark failed_to_schedule once the database is back
+}
+
+// NewWatcher creates a new watcher
+func NewWatcher(source Source, storageService *storage.StorageService, db *database.Database, temporalClient client.Client, stableFor time.Duration) *Watcher {
+	return &Watcher{
+		source:         source,
+		storageService: storageService,
+		db:             db,
+		temporalClient: temporalClient,
+		stableFor:      stableFor,
+		seen:           make(map[string]observation),
+		unfinished:     make(map[string]outcome),
+		unscheduled:    make(map[string]bool),
+	}
+}
+
+// Poll lists the source once and ingests every file that has been stable long enough
+func (w *Watcher) Poll(ctx context.Context) {
+	candidates, err := w.source.List(ctx)
+	if err != nil {
+		slog.Error("Failed to list source", "source", w.source, "error", err)
+		return
+	}
+
+	w.markUnscheduled(ctx)
+
+	now := time.Now()
+	listed := make(map[string]bool, len(candidates))
+
+	for _, c := range candidates {
+		listed[c.Name] = true
+
+		// Ingested files are only moved, never ingested twice
+		if o, ok := w.unfinished[c.Name]; ok {
+			w.finish(ctx, c, o)
+			continue
+		}
+
+		obs, ok := w.seen[c.Name]
+		if !ok || obs.size != c.Size || !obs.modTime.Equal(c.ModTime) || obs.etag != c.ETag {
+			// New or still being written, restart the stability window
+			w.seen[c.Name] = observation{size: c.Size, modTime: c.ModTime, etag: c.ETag, since: now}
+			continue
+		}
+
+		if now.Sub(obs.since) < w.stableFor {
+			continue
+		}
+
+		delete(w.seen, c.Name)
+		w.ingest(ctx, c)
+	}
+
+	// Forget files that disappeared before becoming stable or being moved
+	for name := range w.seen {
+		if !listed[name] {
+			delete(w.seen, name)
+		}
+	}
+	for name := range w.unfinished {
+		if !listed[name] {
+			delete(w.unfinished, name)
+		}
+	}
+}
+
+// ingest uploads a stable file, creates its video and starts transcoding
+func (w *Watcher) ingest(ctx context.Context, c Candidate) {
+	report := Report{
+		File:      c.Name,
+		StartedAt: time.Now(),
+	}
+
+	err := w.process(ctx, c, &report)
+	if errors.Is(err, errRetryLater) {
+		slog.Warn("Leaving file in place to retry", "file", c.Name, "error", err)
+		return
+	}
+
+	folder := OutcomeDone
+	if err != nil {
+		folder = OutcomeFailed
+		report.Error = err.Error()
+		slog.Error("Failed to ingest file", "file", c.Name, "error", err)
+	} else {
+		slog.Info("Ingested file", "file", c.Name, logging.KeyVideoID, report.VideoID)
+	}
+	report.Status = folder
+	report.FinishedAt = time.Now()
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		slog.Error("Failed to encode report", "file", c.Name, "error", err)
+		return
+	}
+
+	w.finish(ctx, c, outcome{folder: folder, report: data})
+}
+
+// finish moves an ingested file into its outcome folder, remembering it to try
+// again on the next poll when the move fails
+func (w *Watcher) finish(ctx context.Context, c Candidate, o outcome) {
+	if err := w.source.Finish(ctx, c, o.folder, o.report); err != nil {
+		slog.Error("Failed to move file, retrying on the next poll", "file", c.Name, "outcome", o.folder, "error", err)
+		w.unfinished[c.Name] = o
+		return
+	}
+	delete(w.unfinished, c.Name)
+}
+
+// markUnscheduled records failed_to_schedule on videos whose workflow could not be
+// started while the database was unavailable, so the uploader retries them
+func (w *Watcher) markUnscheduled(ctx context.Context) {
+	for videoID := range w.unscheduled {
+		if err := w.db.UpdateVideoStatus(ctx, videoID, database.StateFailedToSchedule); err != nil {
+			slog.Warn("Failed to mark video as failed_to_schedule, retrying on the next poll", logging.KeyVideoID, videoID, "error", err)
+			continue
+		}
+		delete(w.unscheduled, videoID)
+	}
+}
+
+// process performs the ingestion steps, filling in the report as it goes
+func (w *Watcher) process(ctx context.Context, c Candidate, report *Report) error {
+	// Read optional sidecar metadata
+	var sidecar Sidecar
+	if c.HasSidecar {
+		data, err := w.source.ReadSidecar(ctx, c)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(data, &sidecar); err != nil {
+			return fmt.Errorf("invalid sidecar JSON: %v", err)
+		}
+	}
+
+	ext := filepath.Ext(c.Name)
+	contentType := sidecar.ContentType
+	if contentType == "" {
+		contentType = mime.TypeByExtension(strings.ToLower(ext))
+	}
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	title := sidecar.Title
+	if title == "" {
+		title = strings.TrimSuffix(c.Name, ext)
+	}
+
+	profile := sidecar.Profile
+	if profile == "" {
+		profile = database.DefaultProfile
+	}
+	if _, err := w.db.GetProfile(ctx, profile); err != nil {
+		return err
+	}
+
+	// Upload to storage using the same layout as the uploader service
+	videoID := id.NewVideoID()
+	objectKey := fmt.Sprintf("uploads/%s/%s", videoID, videoID+ext)
+	report.VideoID = videoID
+	report.ObjectKey = objectKey
+
+	if err := w.source.Upload(ctx, c, objectKey); err != nil {
+		return err
+	}
+
+	// Create the video record
+	now := time.Now()
+	video := &database.Video{
+		ID:              videoID,
+		Slug:            id.NewSlug(),
+		Title:           title,
+		Description:     sidecar.Description,
+		Tags:            sidecar.Tags,
+		Metadata:        sidecar.Metadata,
+		OriginalName:    c.Name,
+		OriginalPath:    objectKey,
+		ProcessingState: database.StateUploaded,
+		Profile:         profile,
+		Size:            c.Size,
+		ContentType:     contentType,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}
+
+	if err := w.db.CreateVideo(ctx, video); err != nil {
+		// Nothing refers to the upload yet, so drop it and ingest the file again later
+		if deleteErr := w.storageService.DeleteObject(ctx, objectKey); deleteErr != nil {
+			slog.Warn("Failed to delete upload of a file to retry", "file", c.Name, "object_key", objectKey, "error", deleteErr)
+		}
+		return fmt.Errorf("%w: failed to create video: %v", errRetryLater, err)
+	}
+
+	// Start transcoding workflow
+	workflowOptions := client.StartWorkflowOptions{
+		ID:        "transcode-" + videoID,
+		TaskQueue: "TRANSCODER_TASK_QUEUE",
+	}
+
+	workflowParams := map[string]interface{}{
+		"videoID":     videoID,
+		"objectKey":   objectKey,
+		"filename":    c.Name,
+		"contentType": contentType,
+		"profile":     profile,
+	}
+
+	run, err := w.temporalClient.ExecuteWorkflow(ctx, workflowOptions, "TranscodeWorkflow", workflowParams)
+	if err != nil {
+		// The upload is safe in storage, the uploader retries scheduling later
+		if statusErr := w.db.UpdateVideoStatus(ctx, videoID, database.StateFailedToSchedule); statusErr != nil {
+			slog.Warn("Failed to mark video as failed_to_schedule, retrying on the next poll", logging.KeyVideoID, videoID, "error", statusErr)
+			w.unscheduled[videoID] = true
+		}
+		report.Warning = fmt.Sprintf("transcoding not scheduled yet: %v", err)
+		return nil
+	}
+	report.WorkflowID = run.GetID()
+
+	return nil
+}
+
+// isIngestible reports whether a file name should be picked up by the watcher
+func isIngestible(name string) bool {
+	if strings.HasPrefix(name, ".") {
+		return false
+	}
+
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".json", ".part", ".tmp", ".crdownload":
+		return false
+	}
+
+	return true
+}
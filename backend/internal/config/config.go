@@ -0,0 +1,386 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/falcon/backend/internal/database"
+	"github.com/falcon/backend/internal/ffmpeg"
+	"github.com/falcon/backend/internal/gateway"
+	"github.com/falcon/backend/internal/logging"
+	"github.com/falcon/backend/internal/storage"
+	"github.com/falcon/backend/internal/supervisor"
+	"github.com/falcon/backend/internal/tracing"
+	"github.com/falcon/backend/internal/validation"
+	"github.com/go-redis/redis/v8"
+	"github.com/spf13/viper"
+)
+
+// EnvPrefix prefixes environment variables overriding config keys, with dots
+// replaced by underscores, such as FALCON_UPLOADER_PORT for uploader.port
+const EnvPrefix = "FALCON"
+
+// configFile is set with --config, shared by every command
+var configFile = flag.String("config", "", "Path to the YAML config file (default ./config.yaml, or $"+EnvPrefix+"_CONFIG)")
+
+// Config is the configuration of every Falcon service
+type Config struct {
+	Server     ServerConfig      `mapstructure:"server"`
+	Uploader   UploaderConfig    `mapstructure:"uploader"`
+	Streamer   StreamerConfig    `mapstructure:"streamer"`
+	Transcoder TranscoderConfig  `mapstructure:"transcoder"`
+	Health     HealthConfig      `mapstructure:"health"`
+	Metrics    MetricsConfig     `mapstructure:"metrics"`
+	Tracing    tracing.Config    `mapstructure:"tracing"`
+	Logging    LoggingConfig     `mapstructure:"logging"`
+	Webhooks   WebhooksConfig    `mapstructure:"webhooks"`
+	Database   database.DbConfig `mapstructure:"database"`
+	Redis      RedisConfig       `mapstructure:"redis"`
+	Storage    storage.Config    `mapstructure:"storage"`
+	Temporal   TemporalConfig    `mapstructure:"temporal"`
+	FFmpeg     FFmpegConfig      `mapstructure:"ffmpeg"`
+	Upload     UploadConfig      `mapstructure:"upload"`
+	Watcher    WatcherConfig     `mapstructure:"watcher"`
+	Supervisor SupervisorConfig  `mapstructure:"supervisor"`
+	Gateway    gateway.Config    `mapstructure:"gateway"`
+
+	// File is the config file that was read, empty when only defaults and the
+	// environment were used
+	File string `mapstructure:"-"`
+}
+
+// ServerConfig configures the main launcher and gateway listener
+type ServerConfig struct {
+	Host string `mapstructure:"host"`
+	Port int    `mapstructure:"port"`
+}
+
+// UploaderConfig configures the uploader listener
+type UploaderConfig struct {
+	Port         int           `mapstructure:"port"`
+	ReadTimeout  time.Duration `mapstructure:"read_timeout"`
+	WriteTimeout time.Duration `mapstructure:"write_timeout"`
+	// MaxUploadSize limits the request body of an upload in bytes, 0 disables the limit
+	MaxUploadSize int64 `mapstructure:"max_upload_size"`
+}
+
+// StreamerConfig configures the streamer listener
+type StreamerConfig struct {
+	Port         int           `mapstructure:"port"`
+	ReadTimeout  time.Duration `mapstructure:"read_timeout"`
+	WriteTimeout time.Duration `mapstructure:"write_timeout"`
+}
+
+// TranscoderConfig configures the transcoder's health listener
+type TranscoderConfig struct {
+	Port int `mapstructure:"port"`
+}
+
+// HealthConfig configures readiness checks
+type HealthConfig struct {
+	// Timeout bounds each dependency check
+	Timeout time.Duration `mapstructure:"timeout"`
+}
+
+// MetricsConfig configures metrics collected in the background
+type MetricsConfig struct {
+	// BacklogPollInterval is how often the transcoder task queue backlog is read from Temporal
+	BacklogPollInterval time.Duration `mapstructure:"backlog_poll_interval"`
+}
+
+// LoggingConfig configures the JSON logs of every service
+type LoggingConfig struct {
+	// Level is the minimum level logged: debug, info, warn or error
+	Level string `mapstructure:"level"`
+}
+
+// WebhooksConfig configures webhook deliveries
+type WebhooksConfig struct {
+	// Timeout bounds each delivery attempt, including reading the response
+	Timeout time.Duration `mapstructure:"timeout"`
+}
+
+// RedisConfig configures the Redis connection
+type RedisConfig struct {
+	Host     string `mapstructure:"host"`
+	Port     int    `mapstructure:"port"`
+	Password string `mapstructure:"password"`
+	DB       int    `mapstructure:"db"`
+}
+
+// Addr returns the host:port of the Redis server
+func (c RedisConfig) Addr() string {
+	return fmt.Sprintf("%s:%d", c.Host, c.Port)
+}
+
+// Options returns the client options for the Redis server
+func (c RedisConfig) Options() *redis.Options {
+	return &redis.Options{
+		Addr:     c.Addr(),
+		Password: c.Password,
+		DB:       c.DB,
+	}
+}
+
+// TemporalConfig configures the Temporal connection
+type TemporalConfig struct {
+	Host      string `mapstructure:"host"`
+	Port      int    `mapstructure:"port"`
+	Namespace string `mapstructure:"namespace"`
+}
+
+// HostPort returns the host:port of the Temporal frontend
+func (c TemporalConfig) HostPort() string {
+	return fmt.Sprintf("%s:%d", c.Host, c.Port)
+}
+
+// FFmpegConfig configures encoding and the default transcoding profile
+type FFmpegConfig struct {
+	Path        string               `mapstructure:"path"`
+	ThreadCount int                  `mapstructure:"thread_count"`
+	Preset      string               `mapstructure:"preset"`
+	AV1Encoder  string               `mapstructure:"av1_encoder"`
+	Formats     []FormatConfig       `mapstructure:"formats"`
+	Resolutions []database.Rendition `mapstructure:"resolutions"`
+	Downloads   []database.Rendition `mapstructure:"downloads"`
+}
+
+// FormatConfig enables an output format of the default profile
+type FormatConfig struct {
+	Name    string `mapstructure:"name"`
+	Enabled bool   `mapstructure:"enabled"`
+}
+
+// NewFFmpeg creates the FFmpeg wrapper described by the ffmpeg section
+func (c FFmpegConfig) NewFFmpeg() *ffmpeg.FFmpeg {
+	ff := ffmpeg.NewFFmpeg(c.Path, c.ThreadCount, c.Preset)
+	if c.AV1Encoder != "" {
+		ff.AV1Encoder = c.AV1Encoder
+	}
+	return ff
+}
+
+// DefaultProfile builds the default transcoding profile from the ffmpeg section
+func (c FFmpegConfig) DefaultProfile() (*database.Profile, error) {
+	var formats []string
+	for _, format := range c.Formats {
+		if format.Enabled {
+			formats = append(formats, format.Name)
+		}
+	}
+	return database.NewDefaultProfile(c.Resolutions, c.Downloads, formats)
+}
+
+// UploadConfig configures upload handling
+type UploadConfig struct {
+	ScheduleRetryInterval time.Duration    `mapstructure:"schedule_retry_interval"`
+	DedupePolicy          string           `mapstructure:"dedupe_policy"`
+	Validation            validation.Rules `mapstructure:"validation"`
+}
+
+// WatcherConfig configures the ingest watcher
+type WatcherConfig struct {
+	Source       string        `mapstructure:"source"`
+	Dir          string        `mapstructure:"dir"`
+	S3Prefix     string        `mapstructure:"s3_prefix"`
+	PollInterval time.Duration `mapstructure:"poll_interval"`
+	StableFor    time.Duration `mapstructure:"stable_for"`
+}
+
+// SupervisorConfig configures the services run by the main launcher
+type SupervisorConfig struct {
+	BinDir            string   `mapstructure:"bin_dir"`
+	Services          []string `mapstructure:"services"`
+	supervisor.Policy `mapstructure:",squash"`
+}
+
+// Load reads the config file given with --config or $FALCON_CONFIG, falling back to
+// ./config.yaml, applies defaults and environment overrides, and validates the result
+func Load() (*Config, error) {
+	v := viper.New()
+	setDefaults(v)
+
+	v.SetEnvPrefix(EnvPrefix)
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	v.AutomaticEnv()
+
+	path := *configFile
+	if path == "" {
+		path = os.Getenv(EnvPrefix + "_CONFIG")
+	}
+
+	if path != "" {
+		v.SetConfigFile(path)
+		if err := v.ReadInConfig(); err != nil {
+			return nil, fmt.Errorf("failed to read config file %s: %v", path, err)
+		}
+	} else {
+		v.SetConfigName("config")
+		v.SetConfigType("yaml")
+		v.AddConfigPath(".")
+		if err := v.ReadInConfig(); err != nil {
+			var notFound viper.ConfigFileNotFoundError
+			if !errors.As(err, &notFound) {
+				return nil, fmt.Errorf("failed to read config file: %v", err)
+			}
+			slog.Warn("No config.yaml in the working directory, using defaults and environment")
+		}
+	}
+
+	var cfg Config
+	if err := v.Unmarshal(&cfg); err != nil {
+		return nil, fmt.Errorf("failed to decode config: %v", err)
+	}
+	cfg.File = v.ConfigFileUsed()
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &cfg, nil
+}
+
+// MustLoad loads the config and exits with the validation errors when it is invalid
+func MustLoad() *Config {
+	cfg, err := Load()
+	var invalid *ValidationError
+	if errors.As(err, &invalid) {
+		logging.Fatal("Invalid configuration", "problems", invalid.Problems)
+	}
+	if err != nil {
+		logging.Fatal("Failed to load config", "error", err)
+	}
+
+	// Validation guarantees the level is known
+	logging.SetLevel(cfg.Logging.Level)
+
+	if cfg.File != "" {
+		slog.Info("Loaded config", "file", cfg.File)
+	}
+	return cfg
+}
+
+// setDefaults registers a default for every key, which also lets environment
+// variables override keys missing from the config file
+func setDefaults(v *viper.Viper) {
+	v.SetDefault("server.host", "0.0.0.0")
+	v.SetDefault("server.port", 8000)
+
+	v.SetDefault("uploader.port", 8001)
+	v.SetDefault("uploader.read_timeout", "15m")
+	v.SetDefault("uploader.write_timeout", "15m")
+	v.SetDefault("uploader.max_upload_size", 10<<30)
+
+	v.SetDefault("streamer.port", 8002)
+	v.SetDefault("streamer.read_timeout", "15s")
+	v.SetDefault("streamer.write_timeout", "15s")
+
+	v.SetDefault("transcoder.port", 8003)
+
+	v.SetDefault("health.timeout", "3s")
+
+	v.SetDefault("metrics.backlog_poll_interval", "15s")
+
+	v.SetDefault("logging.level", "info")
+
+	v.SetDefault("webhooks.timeout", "10s")
+
+	v.SetDefault("tracing.exporter", tracing.ExporterNone)
+	v.SetDefault("tracing.endpoint", "")
+	v.SetDefault("tracing.insecure", true)
+	v.SetDefault("tracing.sample_ratio", 1.0)
+
+	v.SetDefault("database.host", "localhost")
+	v.SetDefault("database.port", 5432)
+	v.SetDefault("database.user", "postgres")
+	v.SetDefault("database.password", "")
+	v.SetDefault("database.dbname", "falcon")
+	v.SetDefault("database.sslmode", "disable")
+	v.SetDefault("database.max_connections", 20)
+
+	v.SetDefault("redis.host", "localhost")
+	v.SetDefault("redis.port", 6379)
+	v.SetDefault("redis.password", "")
+	v.SetDefault("redis.db", 0)
+
+	v.SetDefault("storage.endpoint", "localhost:9000")
+	v.SetDefault("storage.region", "us-east-1")
+	v.SetDefault("storage.bucket", "videos")
+	v.SetDefault("storage.access_key", "")
+	v.SetDefault("storage.secret_key", "")
+	v.SetDefault("storage.use_ssl", false)
+
+	v.SetDefault("temporal.host", "localhost")
+	v.SetDefault("temporal.port", 7233)
+	v.SetDefault("temporal.namespace", "default")
+
+	v.SetDefault("ffmpeg.path", "ffmpeg")
+	v.SetDefault("ffmpeg.thread_count", 4)
+	v.SetDefault("ffmpeg.preset", "medium")
+	v.SetDefault("ffmpeg.av1_encoder", "libsvtav1")
+	v.SetDefault("ffmpeg.formats", []map[string]interface{}{
+		{"name": database.FormatHLS, "enabled": true},
+		{"name": database.FormatDASH, "enabled": true},
+	})
+	v.SetDefault("ffmpeg.resolutions", []map[string]interface{}{
+		{"width": 1920, "height": 1080, "bitrate": "5000k"},
+		{"width": 1280, "height": 720, "bitrate": "2500k"},
+		{"width": 854, "height": 480, "bitrate": "1000k"},
+		{"width": 640, "height": 360, "bitrate": "500k"},
+	})
+	v.SetDefault("ffmpeg.downloads", []map[string]interface{}{})
+
+	v.SetDefault("upload.schedule_retry_interval", "1m")
+	v.SetDefault("upload.dedupe_policy", "none")
+	v.SetDefault("upload.validation.containers", []string{})
+	v.SetDefault("upload.validation.video_codecs", []string{})
+	v.SetDefault("upload.validation.audio_codecs", []string{})
+	v.SetDefault("upload.validation.max_duration", "0s")
+	v.SetDefault("upload.validation.max_width", 0)
+	v.SetDefault("upload.validation.max_height", 0)
+
+	v.SetDefault("watcher.source", "local")
+	v.SetDefault("watcher.dir", "")
+	v.SetDefault("watcher.s3_prefix", "ingest/")
+	v.SetDefault("watcher.poll_interval", "10s")
+	v.SetDefault("watcher.stable_for", "30s")
+
+	policy := supervisor.DefaultPolicy()
+	v.SetDefault("supervisor.bin_dir", "bin")
+	v.SetDefault("supervisor.services", []string{"uploader", "transcoder", "streamer"})
+	v.SetDefault("supervisor.restart", policy.Restart)
+	v.SetDefault("supervisor.initial_backoff", policy.InitialBackoff)
+	v.SetDefault("supervisor.max_backoff", policy.MaxBackoff)
+	v.SetDefault("supervisor.stable_after", policy.StableAfter)
+	v.SetDefault("supervisor.crash_loop_restarts", policy.CrashLoopRestarts)
+	v.SetDefault("supervisor.crash_loop_window", policy.CrashLoopWindow)
+	v.SetDefault("supervisor.crash_loop_cooldown", policy.CrashLoopCooldown)
+	v.SetDefault("supervisor.stop_grace_period", policy.StopGracePeriod)
+
+	// Empty upstreams point at the local uploader and streamer ports
+	gw := gateway.DefaultConfig()
+	v.SetDefault("gateway.uploader_url", "")
+	v.SetDefault("gateway.streamer_url", "")
+	v.SetDefault("gateway.upload_timeout", gw.UploadTimeout)
+	v.SetDefault("gateway.api_timeout", gw.APITimeout)
+	v.SetDefault("gateway.stream_timeout", gw.StreamTimeout)
+	v.SetDefault("gateway.events_timeout", gw.EventsTimeout)
+}
+
+// GatewayConfig returns the gateway settings, pointing unset upstreams at the
+// uploader and streamer ports on this host
+func (c *Config) GatewayConfig() gateway.Config {
+	gw := c.Gateway
+	if gw.UploaderURL == "" {
+		gw.UploaderURL = fmt.Sprintf("http://localhost:%d", c.Uploader.Port)
+	}
+	if gw.StreamerURL == "" {
+		gw.StreamerURL = fmt.Sprintf("http://localhost:%d", c.Streamer.Port)
+	}
+	return gw
+}
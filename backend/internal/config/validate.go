@@ -0,0 +1,167 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/falcon/backend/internal/dedupe"
+	"github.com/falcon/backend/internal/logging"
+	"github.com/falcon/backend/internal/supervisor"
+	"github.com/falcon/backend/internal/tracing"
+	"github.com/falcon/backend/internal/webhooks"
+)
+
+// ValidationError lists every invalid setting found in a config
+type ValidationError struct {
+	Problems []string
+}
+
+// Error implements the error interface
+func (e *ValidationError) Error() string {
+	return "invalid configuration:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
+
+// Validate checks the config for missing or out of range settings, reporting all
+// problems at once rather than failing on the first
+func (c *Config) Validate() error {
+	var problems []string
+	fail := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	// Listeners
+	listeners := []struct {
+		key  string
+		port int
+	}{
+		{"server.port", c.Server.Port},
+		{"uploader.port", c.Uploader.Port},
+		{"streamer.port", c.Streamer.Port},
+		{"transcoder.port", c.Transcoder.Port},
+	}
+	seen := make(map[int]string)
+	for _, l := range listeners {
+		if l.port < 1 || l.port > 65535 {
+			fail("%s must be between 1 and 65535, got %d", l.key, l.port)
+			continue
+		}
+		if other, ok := seen[l.port]; ok {
+			fail("%s and %s must differ, both are %d", other, l.key, l.port)
+		}
+		seen[l.port] = l.key
+	}
+	if c.Uploader.MaxUploadSize < 0 {
+		fail("uploader.max_upload_size must not be negative")
+	}
+
+	// Dependencies
+	required := []struct {
+		key   string
+		value string
+	}{
+		{"database.host", c.Database.Host},
+		{"database.user", c.Database.User},
+		{"database.dbname", c.Database.DbName},
+		{"redis.host", c.Redis.Host},
+		{"storage.endpoint", c.Storage.Endpoint},
+		{"storage.bucket", c.Storage.Bucket},
+		{"temporal.host", c.Temporal.Host},
+		{"temporal.namespace", c.Temporal.Namespace},
+		{"ffmpeg.path", c.FFmpeg.Path},
+	}
+	for _, r := range required {
+		if strings.TrimSpace(r.value) == "" {
+			fail("%s is required", r.key)
+		}
+	}
+	if c.Database.Port < 1 || c.Database.Port > 65535 {
+		fail("database.port must be between 1 and 65535, got %d", c.Database.Port)
+	}
+	if c.Database.MaxConnections < 1 {
+		fail("database.max_connections must be at least 1, got %d", c.Database.MaxConnections)
+	}
+	if c.Redis.Port < 1 || c.Redis.Port > 65535 {
+		fail("redis.port must be between 1 and 65535, got %d", c.Redis.Port)
+	}
+	if c.Temporal.Port < 1 || c.Temporal.Port > 65535 {
+		fail("temporal.port must be between 1 and 65535, got %d", c.Temporal.Port)
+	}
+	if c.FFmpeg.ThreadCount < 0 {
+		fail("ffmpeg.thread_count must not be negative")
+	}
+	if _, err := c.FFmpeg.DefaultProfile(); err != nil {
+		fail("ffmpeg: invalid default profile: %v", err)
+	}
+
+	// Durations
+	durations := []struct {
+		key   string
+		value time.Duration
+	}{
+		{"uploader.read_timeout", c.Uploader.ReadTimeout},
+		{"uploader.write_timeout", c.Uploader.WriteTimeout},
+		{"streamer.read_timeout", c.Streamer.ReadTimeout},
+		{"streamer.write_timeout", c.Streamer.WriteTimeout},
+		{"health.timeout", c.Health.Timeout},
+		{"metrics.backlog_poll_interval", c.Metrics.BacklogPollInterval},
+		{"upload.schedule_retry_interval", c.Upload.ScheduleRetryInterval},
+		{"watcher.poll_interval", c.Watcher.PollInterval},
+		{"gateway.upload_timeout", c.Gateway.UploadTimeout},
+		{"gateway.api_timeout", c.Gateway.APITimeout},
+		{"gateway.stream_timeout", c.Gateway.StreamTimeout},
+		{"gateway.events_timeout", c.Gateway.EventsTimeout},
+		{"webhooks.timeout", c.Webhooks.Timeout},
+	}
+	for _, d := range durations {
+		if d.value <= 0 {
+			fail("%s must be a positive duration, got %s", d.key, d.value)
+		}
+	}
+	if c.Webhooks.Timeout > webhooks.MaxTimeout {
+		fail("webhooks.timeout must be at most %s, got %s", webhooks.MaxTimeout, c.Webhooks.Timeout)
+	}
+	if c.Watcher.StableFor < 0 {
+		fail("watcher.stable_for must not be negative")
+	}
+	if c.Upload.Validation.MaxDuration < 0 {
+		fail("upload.validation.max_duration must not be negative")
+	}
+
+	// Policies
+	if !dedupe.IsValid(c.Upload.DedupePolicy) {
+		fail("upload.dedupe_policy must be %s, %s or %s, got %q",
+			dedupe.None, dedupe.ReturnExisting, dedupe.ReuseRenditions, c.Upload.DedupePolicy)
+	}
+	switch c.Watcher.Source {
+	case "local", "s3":
+	default:
+		fail("watcher.source must be local or s3, got %q", c.Watcher.Source)
+	}
+	switch c.Supervisor.Restart {
+	case supervisor.RestartAlways, supervisor.RestartOnFailure, supervisor.RestartNever:
+	default:
+		fail("supervisor.restart must be %s, %s or %s, got %q",
+			supervisor.RestartAlways, supervisor.RestartOnFailure, supervisor.RestartNever, c.Supervisor.Restart)
+	}
+	if c.Supervisor.BinDir == "" {
+		fail("supervisor.bin_dir is required")
+	}
+	if _, err := logging.ParseLevel(c.Logging.Level); err != nil {
+		fail("logging.level must be debug, info, warn or error, got %q", c.Logging.Level)
+	}
+	switch c.Tracing.Exporter {
+	case tracing.ExporterNone, tracing.ExporterOTLP, tracing.ExporterStdout:
+	default:
+		fail("tracing.exporter must be %s, %s or %s, got %q",
+			tracing.ExporterNone, tracing.ExporterOTLP, tracing.ExporterStdout, c.Tracing.Exporter)
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		fail("tracing.sample_ratio must be between 0 and 1, got %g", c.Tracing.SampleRatio)
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Transcode job statuses
+const (
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+)
+
+// TranscodeJob records a single run of the transcoding workflow
+type TranscodeJob struct {
+	ID             int64            `json:"id"`
+	VideoID        string           `json:"video_id"`
+	WorkflowID     string           `json:"workflow_id"`
+	RunID          string           `json:"run_id"`
+	Profile        string           `json:"profile"`
+	Status         string           `json:"status"`
+	Attempts       map[string]int32 `json:"attempts"`
+	ErrorMessage   string           `json:"error_message,omitempty"`
+	FFmpegExitCode *int32           `json:"ffmpeg_exit_code,omitempty"`
+	FFmpegStderr   string           `json:"ffmpeg_stderr,omitempty"`
+	StartedAt      time.Time        `json:"started_at"`
+	FinishedAt     *time.Time       `json:"finished_at,omitempty"`
+}
+
+// StartTranscodeJob records the start of a workflow run. Starting the same run twice is a no-op.
+func (db *Database) StartTranscodeJob(ctx context.Context, job *TranscodeJob) error {
+	_, err := db.pool.Exec(ctx, `
+		INSERT INTO transcode_jobs (video_id, workflow_id, run_id, profile, status)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (workflow_id, run_id) DO NOTHING
+	`, job.VideoID, job.WorkflowID, job.RunID, job.Profile, JobRunning)
+
+	if err != nil {
+		return fmt.Errorf("failed to insert transcode job: %v", err)
+	}
+
+	return nil
+}
+
+// RecordJobAttempt stores the latest attempt number of an activity within a workflow run
+func (db *Database) RecordJobAttempt(ctx context.Context, workflowID, runID, activityName string, attempt int32) error {
+	_, err := db.pool.Exec(ctx, `
+		UPDATE transcode_jobs
+		SET attempts = jsonb_set(
+			attempts, ARRAY[$3::TEXT],
+			to_jsonb(GREATEST(COALESCE((attempts->>$3::TEXT)::INTEGER, 0), $4::INTEGER))
+		)
+		WHERE workflow_id = $1 AND run_id = $2
+	`, workflowID, runID, activityName, attempt)
+
+	if err != nil {
+		return fmt.Errorf("failed to record job attempt: %v", err)
+	}
+
+	return nil
+}
+
+// RecordJobFFmpegFailure stores the exit code and stderr tail of a failed ffmpeg run
+func (db *Database) RecordJobFFmpegFailure(ctx context.Context, workflowID, runID string, exitCode int, stderr string) error {
+	_, err := db.pool.Exec(ctx, `
+		UPDATE transcode_jobs
+		SET ffmpeg_exit_code = $3, ffmpeg_stderr = $4
+		WHERE workflow_id = $1 AND run_id = $2
+	`, workflowID, runID, exitCode, stderr)
+
+	if err != nil {
+		return fmt.Errorf("failed to record ffmpeg failure: %v", err)
+	}
+
+	return nil
+}
+
+// FinishTranscodeJob records the final status of a workflow run
+func (db *Database) FinishTranscodeJob(ctx context.Context, workflowID, runID, status, errMsg string) error {
+	_, err := db.pool.Exec(ctx, `
+		UPDATE transcode_jobs
+		SET status = $3, error_message = $4, finished_at = NOW()
+		WHERE workflow_id = $1 AND run_id = $2
+	`, workflowID, runID, status, errMsg)
+
+	if err != nil {
+		return fmt.Errorf("failed to finish transcode job: %v", err)
+	}
+
+	return nil
+}
+
+// GetTranscodeJobs retrieves all transcode jobs of a video, newest first
+func (db *Database) GetTranscodeJobs(ctx context.Context, videoID string) ([]*TranscodeJob, error) {
+	rows, err := db.pool.Query(ctx, `
+		SELECT
+			id, video_id, workflow_id, run_id, profile, status, attempts, error_message,
+			ffmpeg_exit_code, ffmpeg_stderr, started_at, finished_at
+		FROM transcode_jobs
+		WHERE video_id = $1
+		ORDER BY started_at DESC
+	`, videoID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query transcode jobs: %v", err)
+	}
+	defer rows.Close()
+
+	var jobs []*TranscodeJob
+	for rows.Next() {
+		job := &TranscodeJob{}
+		err := rows.Scan(
+			&job.ID,
+			&job.VideoID,
+			&job.WorkflowID,
+			&job.RunID,
+			&job.Profile,
+			&job.Status,
+			&job.Attempts,
+			&job.ErrorMessage,
+			&job.FFmpegExitCode,
+			&job.FFmpegStderr,
+			&job.StartedAt,
+			&job.FinishedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan transcode job: %v", err)
+		}
+		jobs = append(jobs, job)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read transcode jobs: %v", err)
+	}
+
+	return jobs, nil
+}
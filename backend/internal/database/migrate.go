@@ -0,0 +1,313 @@
+package database
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// migrationFiles holds the numbered SQL migrations, named NNNN_description.up.sql and
+// NNNN_description.down.sql
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migrationLockID is the advisory lock key held while migrating, so services
+// starting at the same time do not apply migrations concurrently
+const migrationLockID = 7_236_000_001
+
+// Migration is a single versioned schema change
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus reports whether a migration has been applied
+type MigrationStatus struct {
+	Version   int64      `json:"version"`
+	Name      string     `json:"name"`
+	Applied   bool       `json:"applied"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+}
+
+// LoadMigrations returns all embedded migrations ordered by version
+func LoadMigrations() ([]Migration, error) {
+	entries, err := fs.ReadDir(migrationFiles, "migrations")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %v", err)
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		name := entry.Name()
+
+		var direction string
+		switch {
+		case strings.HasSuffix(name, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(name, ".down.sql"):
+			direction = "down"
+		default:
+			return nil, fmt.Errorf("unexpected migration file %s", name)
+		}
+
+		base := strings.TrimSuffix(name, "."+direction+".sql")
+		versionStr, description, ok := strings.Cut(base, "_")
+		if !ok {
+			return nil, fmt.Errorf("migration file %s must be named NNNN_description.%s.sql", name, direction)
+		}
+
+		version, err := strconv.ParseInt(versionStr, 10, 64)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("migration file %s has an invalid version", name)
+		}
+
+		content, err := migrationFiles.ReadFile(path.Join("migrations", name))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %v", name, err)
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: description}
+			byVersion[version] = migration
+		} else if migration.Name != description {
+			return nil, fmt.Errorf("migration version %d is used by both %s and %s", version, migration.Name, description)
+		}
+
+		if direction == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migration %04d_%s needs both up and down files", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// Migrate applies all pending migrations
+func (db *Database) Migrate(ctx context.Context) error {
+	migrations, err := LoadMigrations()
+	if err != nil {
+		return err
+	}
+	if len(migrations) == 0 {
+		return nil
+	}
+
+	return db.MigrateTo(ctx, migrations[len(migrations)-1].Version)
+}
+
+// MigrateDown reverts the given number of most recently applied migrations
+func (db *Database) MigrateDown(ctx context.Context, steps int) error {
+	return db.withMigrationLock(ctx, func(conn *pgxpool.Conn) error {
+		migrations, applied, err := loadMigrationState(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(migrations) - 1; i >= 0 && steps > 0; i-- {
+			if _, ok := applied[migrations[i].Version]; !ok {
+				continue
+			}
+			if err := revertMigration(ctx, conn, migrations[i]); err != nil {
+				return err
+			}
+			steps--
+		}
+
+		return nil
+	})
+}
+
+// MigrateTo applies or reverts migrations until the schema is at the given version.
+// Version 0 reverts every migration.
+func (db *Database) MigrateTo(ctx context.Context, version int64) error {
+	return db.withMigrationLock(ctx, func(conn *pgxpool.Conn) error {
+		migrations, applied, err := loadMigrationState(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		if version != 0 && !hasMigration(migrations, version) {
+			return fmt.Errorf("unknown migration version %d", version)
+		}
+
+		// Revert newer migrations, newest first
+		for i := len(migrations) - 1; i >= 0; i-- {
+			if _, ok := applied[migrations[i].Version]; ok && migrations[i].Version > version {
+				if err := revertMigration(ctx, conn, migrations[i]); err != nil {
+					return err
+				}
+			}
+		}
+
+		// Apply pending migrations, oldest first
+		for _, migration := range migrations {
+			if _, ok := applied[migration.Version]; !ok && migration.Version <= version {
+				if err := applyMigration(ctx, conn, migration); err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	})
+}
+
+// MigrationStatus lists every known migration and whether it has been applied
+func (db *Database) MigrationStatus(ctx context.Context) ([]MigrationStatus, error) {
+	var statuses []MigrationStatus
+
+	err := db.withMigrationLock(ctx, func(conn *pgxpool.Conn) error {
+		migrations, applied, err := loadMigrationState(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range migrations {
+			status := MigrationStatus{
+				Version: migration.Version,
+				Name:    migration.Name,
+			}
+			if appliedAt, ok := applied[migration.Version]; ok {
+				status.Applied = true
+				status.AppliedAt = &appliedAt
+			}
+			statuses = append(statuses, status)
+		}
+
+		return nil
+	})
+
+	return statuses, err
+}
+
+// withMigrationLock runs fn on a dedicated connection holding the migration advisory lock
+func (db *Database) withMigrationLock(ctx context.Context, fn func(conn *pgxpool.Conn) error) error {
+	conn, err := db.pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to acquire connection: %v", err)
+	}
+	defer conn.Release()
+
+	if _, err := conn.Exec(ctx, "SELECT pg_advisory_lock($1)", migrationLockID); err != nil {
+		return fmt.Errorf("failed to acquire migration lock: %v", err)
+	}
+	defer conn.Exec(context.Background(), "SELECT pg_advisory_unlock($1)", migrationLockID)
+
+	_, err = conn.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version BIGINT PRIMARY KEY,
+			name TEXT NOT NULL,
+			applied_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations table: %v", err)
+	}
+
+	return fn(conn)
+}
+
+// loadMigrationState returns the known migrations and the applied versions with their timestamps
+func loadMigrationState(ctx context.Context, conn *pgxpool.Conn) ([]Migration, map[int64]time.Time, error) {
+	migrations, err := LoadMigrations()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rows, err := conn.Query(ctx, "SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to query schema_migrations: %v", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int64]time.Time)
+	for rows.Next() {
+		var version int64
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, nil, fmt.Errorf("failed to scan schema_migrations: %v", err)
+		}
+		applied[version] = appliedAt
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("failed to read schema_migrations: %v", err)
+	}
+
+	return migrations, applied, nil
+}
+
+// applyMigration runs an up migration and records it in one transaction
+func applyMigration(ctx context.Context, conn *pgxpool.Conn, migration Migration) error {
+	return runMigration(ctx, conn, migration.Up, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, "INSERT INTO schema_migrations (version, name) VALUES ($1, $2)",
+			migration.Version, migration.Name)
+		return err
+	}, fmt.Sprintf("apply migration %04d_%s", migration.Version, migration.Name))
+}
+
+// revertMigration runs a down migration and removes its record in one transaction
+func revertMigration(ctx context.Context, conn *pgxpool.Conn, migration Migration) error {
+	return runMigration(ctx, conn, migration.Down, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, "DELETE FROM schema_migrations WHERE version = $1", migration.Version)
+		return err
+	}, fmt.Sprintf("revert migration %04d_%s", migration.Version, migration.Name))
+}
+
+// runMigration executes a migration script followed by the bookkeeping statement
+func runMigration(ctx context.Context, conn *pgxpool.Conn, script string, record func(tx pgx.Tx) error, action string) error {
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to %s: %v", action, err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, script); err != nil {
+		return fmt.Errorf("failed to %s: %v", action, err)
+	}
+
+	if err := record(tx); err != nil {
+		return fmt.Errorf("failed to %s: %v", action, err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to %s: %v", action, err)
+	}
+
+	return nil
+}
+
+// Helper function to check whether a version exists
+func hasMigration(migrations []Migration, version int64) bool {
+	for _, migration := range migrations {
+		if migration.Version == version {
+			return true
+		}
+	}
+	return false
+}
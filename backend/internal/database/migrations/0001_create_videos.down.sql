@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS video_streams;
+DROP TABLE IF EXISTS videos;
@@ -0,0 +1,27 @@
+-- Baseline schema previously created by Database.CreateTables. IF NOT EXISTS lets
+-- databases created before migrations were introduced adopt this version.
+CREATE TABLE IF NOT EXISTS videos (
+	id TEXT PRIMARY KEY,
+	title TEXT NOT NULL,
+	original_name TEXT NOT NULL,
+	original_path TEXT NOT NULL,
+	processing_state TEXT NOT NULL,
+	duration FLOAT DEFAULT 0,
+	size BIGINT NOT NULL,
+	content_type TEXT NOT NULL,
+	created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+	updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS video_streams (
+	id TEXT PRIMARY KEY,
+	video_id TEXT NOT NULL REFERENCES videos(id) ON DELETE CASCADE,
+	resolution TEXT NOT NULL,
+	bitrate TEXT NOT NULL,
+	format TEXT NOT NULL,
+	path TEXT NOT NULL,
+	size BIGINT DEFAULT 0,
+	segment_size INTEGER DEFAULT 0,
+	created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+	UNIQUE(video_id, resolution, format)
+);
@@ -0,0 +1,4 @@
+ALTER TABLE videos
+	DROP COLUMN IF EXISTS description,
+	DROP COLUMN IF EXISTS tags,
+	DROP COLUMN IF EXISTS metadata;
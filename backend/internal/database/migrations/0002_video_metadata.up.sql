@@ -0,0 +1,4 @@
+ALTER TABLE videos
+	ADD COLUMN IF NOT EXISTS description TEXT NOT NULL DEFAULT '',
+	ADD COLUMN IF NOT EXISTS tags TEXT[] NOT NULL DEFAULT '{}',
+	ADD COLUMN IF NOT EXISTS metadata JSONB NOT NULL DEFAULT '{}';
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS videos_slug_key;
+ALTER TABLE videos DROP COLUMN IF EXISTS slug;
@@ -0,0 +1,2 @@
+ALTER TABLE videos ADD COLUMN IF NOT EXISTS slug TEXT;
+CREATE UNIQUE INDEX IF NOT EXISTS videos_slug_key ON videos (slug);
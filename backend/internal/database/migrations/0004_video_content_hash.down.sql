@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS videos_content_hash_idx;
+ALTER TABLE videos
+	DROP COLUMN IF EXISTS renditions_from,
+	DROP COLUMN IF EXISTS content_hash;
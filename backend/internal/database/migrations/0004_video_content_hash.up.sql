@@ -0,0 +1,4 @@
+ALTER TABLE videos
+	ADD COLUMN IF NOT EXISTS content_hash TEXT,
+	ADD COLUMN IF NOT EXISTS renditions_from TEXT REFERENCES videos(id) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS videos_content_hash_idx ON videos (content_hash);
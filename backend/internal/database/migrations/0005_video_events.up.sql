@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS video_events (
+	id BIGSERIAL PRIMARY KEY,
+	video_id TEXT NOT NULL REFERENCES videos(id) ON DELETE CASCADE,
+	from_state TEXT NOT NULL DEFAULT '',
+	to_state TEXT NOT NULL,
+	error_message TEXT NOT NULL DEFAULT '',
+	workflow_id TEXT NOT NULL DEFAULT '',
+	run_id TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS video_events_video_id_idx ON video_events (video_id, id);
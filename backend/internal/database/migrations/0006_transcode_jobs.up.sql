@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS transcode_jobs (
+	id BIGSERIAL PRIMARY KEY,
+	video_id TEXT NOT NULL REFERENCES videos(id) ON DELETE CASCADE,
+	workflow_id TEXT NOT NULL,
+	run_id TEXT NOT NULL,
+	profile TEXT NOT NULL,
+	status TEXT NOT NULL,
+	attempts JSONB NOT NULL DEFAULT '{}',
+	error_message TEXT NOT NULL DEFAULT '',
+	ffmpeg_exit_code INTEGER,
+	ffmpeg_stderr TEXT NOT NULL DEFAULT '',
+	started_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+	finished_at TIMESTAMP WITH TIME ZONE,
+	UNIQUE(workflow_id, run_id)
+);
+
+CREATE INDEX IF NOT EXISTS transcode_jobs_video_id_idx ON transcode_jobs (video_id, started_at);
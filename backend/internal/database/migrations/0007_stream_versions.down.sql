@@ -0,0 +1,9 @@
+DELETE FROM video_streams s USING videos v
+WHERE s.video_id = v.id AND s.version <> v.active_version;
+
+ALTER TABLE video_streams DROP CONSTRAINT IF EXISTS video_streams_video_id_version_resolution_format_key;
+ALTER TABLE video_streams ADD CONSTRAINT video_streams_video_id_resolution_format_key
+	UNIQUE (video_id, resolution, format);
+
+ALTER TABLE video_streams DROP COLUMN IF EXISTS version;
+ALTER TABLE videos DROP COLUMN IF EXISTS active_version;
@@ -0,0 +1,8 @@
+-- Each encode writes a new numbered set of streams. Version 0 is the layout used
+-- before versioning, stored directly under videos/{id}/.
+ALTER TABLE videos ADD COLUMN IF NOT EXISTS active_version INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE video_streams ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 0;
+
+ALTER TABLE video_streams DROP CONSTRAINT IF EXISTS video_streams_video_id_resolution_format_key;
+ALTER TABLE video_streams ADD CONSTRAINT video_streams_video_id_version_resolution_format_key
+	UNIQUE (video_id, version, resolution, format);
@@ -0,0 +1,3 @@
+ALTER TABLE videos DROP COLUMN IF EXISTS profile;
+
+DROP TABLE IF EXISTS transcode_profiles;
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS transcode_profiles (
+	name TEXT PRIMARY KEY,
+	description TEXT NOT NULL DEFAULT '',
+	renditions JSONB NOT NULL,
+	segment_duration INTEGER NOT NULL,
+	audio JSONB NOT NULL,
+	formats TEXT[] NOT NULL,
+	thumbnails BOOLEAN NOT NULL DEFAULT TRUE,
+	created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+	updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+-- The profile a video was last encoded with, used when its encode is retried
+ALTER TABLE videos ADD COLUMN IF NOT EXISTS profile TEXT NOT NULL DEFAULT 'default';
@@ -0,0 +1,7 @@
+DELETE FROM video_streams WHERE codec <> 'h264';
+
+ALTER TABLE video_streams DROP CONSTRAINT IF EXISTS video_streams_video_id_version_resolution_format_codec_key;
+ALTER TABLE video_streams ADD CONSTRAINT video_streams_video_id_version_resolution_format_key
+	UNIQUE (video_id, version, resolution, format);
+
+ALTER TABLE video_streams DROP COLUMN IF EXISTS codec;
@@ -0,0 +1,5 @@
+ALTER TABLE video_streams ADD COLUMN IF NOT EXISTS codec TEXT NOT NULL DEFAULT 'h264';
+
+ALTER TABLE video_streams DROP CONSTRAINT IF EXISTS video_streams_video_id_version_resolution_format_key;
+ALTER TABLE video_streams ADD CONSTRAINT video_streams_video_id_version_resolution_format_codec_key
+	UNIQUE (video_id, version, resolution, format, codec);
@@ -0,0 +1,3 @@
+ALTER TABLE videos DROP COLUMN IF EXISTS packaging;
+
+ALTER TABLE transcode_profiles DROP COLUMN IF EXISTS packaging;
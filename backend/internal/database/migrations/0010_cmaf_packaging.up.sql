@@ -0,0 +1,5 @@
+ALTER TABLE transcode_profiles ADD COLUMN IF NOT EXISTS packaging TEXT NOT NULL DEFAULT 'separate';
+
+-- How the active streams of a video are packaged, which decides where the hls and
+-- dash files are stored
+ALTER TABLE videos ADD COLUMN IF NOT EXISTS packaging TEXT NOT NULL DEFAULT 'separate';
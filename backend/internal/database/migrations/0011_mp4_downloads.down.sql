@@ -0,0 +1 @@
+ALTER TABLE transcode_profiles DROP COLUMN IF EXISTS downloads;
@@ -0,0 +1,3 @@
+-- Progressive MP4 renditions produced next to the adaptive formats, as a JSON array
+-- of renditions like the renditions column
+ALTER TABLE transcode_profiles ADD COLUMN IF NOT EXISTS downloads JSONB NOT NULL DEFAULT '[]';
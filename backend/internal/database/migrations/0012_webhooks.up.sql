@@ -0,0 +1,34 @@
+CREATE TABLE IF NOT EXISTS webhook_subscriptions (
+	id BIGSERIAL PRIMARY KEY,
+	url TEXT NOT NULL,
+	secret TEXT NOT NULL,
+	-- Event types delivered to the subscription, empty for every event
+	events TEXT[] NOT NULL DEFAULT '{}',
+	description TEXT NOT NULL DEFAULT '',
+	active BOOLEAN NOT NULL DEFAULT TRUE,
+	created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+	updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+-- Every event sent to a subscription, with the payload so it can be redelivered
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+	id BIGSERIAL PRIMARY KEY,
+	subscription_id BIGINT NOT NULL REFERENCES webhook_subscriptions(id) ON DELETE CASCADE,
+	event_id TEXT NOT NULL,
+	event_type TEXT NOT NULL,
+	video_id TEXT NOT NULL DEFAULT '',
+	payload JSONB NOT NULL,
+	status TEXT NOT NULL,
+	attempts INTEGER NOT NULL DEFAULT 0,
+	response_status INTEGER,
+	error_message TEXT NOT NULL DEFAULT '',
+	redelivery_of BIGINT REFERENCES webhook_deliveries(id) ON DELETE SET NULL,
+	created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+	last_attempt_at TIMESTAMP WITH TIME ZONE,
+	delivered_at TIMESTAMP WITH TIME ZONE
+);
+
+-- An event is delivered once per subscription, redeliveries are separate rows
+CREATE UNIQUE INDEX IF NOT EXISTS webhook_deliveries_event_idx
+	ON webhook_deliveries (event_id, subscription_id) WHERE redelivery_of IS NULL;
+CREATE INDEX IF NOT EXISTS webhook_deliveries_subscription_idx ON webhook_deliveries (subscription_id, id);
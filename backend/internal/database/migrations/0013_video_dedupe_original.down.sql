@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS videos_dedupe_original_idx;
+ALTER TABLE videos DROP COLUMN IF EXISTS dedupe_original;
@@ -0,0 +1,14 @@
+-- The video each deduplicated upload of a content hash is compared against. Uploads
+-- made without deduplication, or that lost a race to another upload, are not originals.
+ALTER TABLE videos ADD COLUMN IF NOT EXISTS dedupe_original BOOLEAN NOT NULL DEFAULT false;
+
+-- The earliest encoded video of each hash becomes its original
+UPDATE videos SET dedupe_original = true
+WHERE id IN (
+	SELECT DISTINCT ON (content_hash) id
+	FROM videos
+	WHERE content_hash IS NOT NULL AND renditions_from IS NULL
+	ORDER BY content_hash, processing_state = 'completed' DESC, created_at ASC
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS videos_dedupe_original_idx ON videos (content_hash) WHERE dedupe_original;
@@ -0,0 +1,384 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/falcon/backend/internal/ffmpeg"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+)
+
+// DefaultProfile is the profile seeded from the ffmpeg config section and used
+// when an upload does not select one
+const DefaultProfile = "default"
+
+// Output formats a profile can produce
+const (
+	FormatHLS  = "hls"
+	FormatDASH = "dash"
+
+	// FormatCMAF marks streams of CMAF packaged renditions, served as both hls and dash
+	FormatCMAF = "cmaf"
+
+	// FormatMP4 marks progressive faststart MP4 files for download and offline playback
+	FormatMP4 = "mp4"
+)
+
+// Packaging modes of a profile
+const (
+	// PackagingSeparate encodes MPEG-TS or fMP4 HLS and DASH separately
+	PackagingSeparate = "separate"
+	// PackagingCMAF writes one set of fMP4 segments referenced by both HLS and DASH
+	PackagingCMAF = "cmaf"
+)
+
+// ErrProfileNotFound is returned when a transcoding profile does not exist
+var ErrProfileNotFound = errors.New("profile not found")
+
+// ErrDuplicateProfile is returned when a profile name is already taken
+var ErrDuplicateProfile = errors.New("profile already exists")
+
+// ErrInvalidProfile is returned when a profile fails validation
+var ErrInvalidProfile = errors.New("invalid profile")
+
+// ErrDefaultProfile is returned when trying to delete the default profile
+var ErrDefaultProfile = errors.New("the default profile cannot be deleted")
+
+// profileNamePattern restricts profile names to URL-safe identifiers
+var profileNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+
+// bitratePattern matches ffmpeg bitrates such as 2500k or 5M
+var bitratePattern = regexp.MustCompile(`^[0-9]+(\.[0-9]+)?[kKmM]?$`)
+
+// Rendition is a single rung of a profile's encoding ladder
+type Rendition struct {
+	Width   int    `json:"width"`
+	Height  int    `json:"height"`
+	Bitrate string `json:"bitrate"`
+	Codec   string `json:"codec,omitempty"` // One of ffmpeg.VideoCodecs, empty for H.264
+}
+
+// AudioSettings controls how audio is encoded
+type AudioSettings struct {
+	Codec    string `json:"codec"`
+	Bitrate  string `json:"bitrate"`
+	Channels int    `json:"channels,omitempty"` // 0 keeps the source layout
+}
+
+// Profile is a named set of encoding settings selectable per upload
+type Profile struct {
+	Name            string        `json:"name"`
+	Description     string        `json:"description"`
+	Renditions      []Rendition   `json:"renditions"`
+	SegmentDuration int           `json:"segment_duration"`
+	Audio           AudioSettings `json:"audio"`
+	Formats         []string      `json:"formats"`   // Ignored with CMAF packaging, which serves both
+	Downloads       []Rendition   `json:"downloads"` // Progressive MP4 files, encoded next to the formats
+	Packaging       string        `json:"packaging"`
+	Thumbnails      bool          `json:"thumbnails"`
+	CreatedAt       time.Time     `json:"created_at"`
+	UpdatedAt       time.Time     `json:"updated_at"`
+}
+
+// HasFormat reports whether the profile produces the given output format
+func (p *Profile) HasFormat(format string) bool {
+	for _, f := range p.Formats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks that the profile can be used for encoding
+func (p *Profile) Validate() error {
+	if !profileNamePattern.MatchString(p.Name) {
+		return fmt.Errorf("%w: name must be 1-64 lowercase letters, digits, '-' or '_'", ErrInvalidProfile)
+	}
+
+	if len(p.Renditions) == 0 {
+		return fmt.Errorf("%w: at least one rendition is required", ErrInvalidProfile)
+	}
+	for i, r := range p.Renditions {
+		if err := r.validate(); err != nil {
+			return fmt.Errorf("%w: rendition %d %v", ErrInvalidProfile, i, err)
+		}
+	}
+
+	if p.Downloads == nil {
+		p.Downloads = []Rendition{}
+	}
+	downloads := make(map[string]bool)
+	for i, r := range p.Downloads {
+		if err := r.validate(); err != nil {
+			return fmt.Errorf("%w: download %d %v", ErrInvalidProfile, i, err)
+		}
+		// Downloads are stored by file name, which only tells height and codec apart
+		name := MP4FileName(r)
+		if downloads[name] {
+			return fmt.Errorf("%w: download %d duplicates %s", ErrInvalidProfile, i, name)
+		}
+		downloads[name] = true
+	}
+
+	if p.SegmentDuration < 1 || p.SegmentDuration > 60 {
+		return fmt.Errorf("%w: segment_duration must be between 1 and 60 seconds", ErrInvalidProfile)
+	}
+
+	if p.Audio.Codec == "" {
+		return fmt.Errorf("%w: audio codec is required", ErrInvalidProfile)
+	}
+	if !bitratePattern.MatchString(p.Audio.Bitrate) {
+		return fmt.Errorf("%w: invalid audio bitrate %q", ErrInvalidProfile, p.Audio.Bitrate)
+	}
+	if p.Audio.Channels < 0 || p.Audio.Channels > 8 {
+		return fmt.Errorf("%w: audio channels must be between 0 and 8", ErrInvalidProfile)
+	}
+
+	if p.Packaging == "" {
+		p.Packaging = PackagingSeparate
+	}
+	if p.Packaging != PackagingSeparate && p.Packaging != PackagingCMAF {
+		return fmt.Errorf("%w: unknown packaging %q, expected %s or %s", ErrInvalidProfile, p.Packaging, PackagingSeparate, PackagingCMAF)
+	}
+
+	if len(p.Formats) == 0 {
+		return fmt.Errorf("%w: at least one format is required", ErrInvalidProfile)
+	}
+	for _, format := range p.Formats {
+		if format != FormatHLS && format != FormatDASH {
+			return fmt.Errorf("%w: unknown format %q, expected %s or %s", ErrInvalidProfile, format, FormatHLS, FormatDASH)
+		}
+	}
+
+	return nil
+}
+
+// validate checks the size, bitrate and codec of a rendition
+func (r Rendition) validate() error {
+	if r.Width <= 0 || r.Height <= 0 || r.Width%2 != 0 || r.Height%2 != 0 {
+		return errors.New("must have a positive, even width and height")
+	}
+	if !bitratePattern.MatchString(r.Bitrate) {
+		return fmt.Errorf("has invalid bitrate %q", r.Bitrate)
+	}
+	if r.Codec != "" && !ffmpeg.IsSupportedVideoCodec(r.Codec) {
+		return fmt.Errorf("has unknown codec %q, expected one of %s", r.Codec, strings.Join(ffmpeg.VideoCodecs, ", "))
+	}
+	return nil
+}
+
+// MP4FileName returns the file name of a progressive MP4 rendition, such as 720p.mp4
+// for H.264 and 720p_hevc.mp4 for other codecs
+func MP4FileName(r Rendition) string {
+	if r.Codec == "" || r.Codec == ffmpeg.CodecH264 {
+		return fmt.Sprintf("%dp.mp4", r.Height)
+	}
+	return fmt.Sprintf("%dp_%s.mp4", r.Height, r.Codec)
+}
+
+// NewDefaultProfile builds the default profile from the ladder, downloads and formats
+// of the ffmpeg config section
+func NewDefaultProfile(renditions, downloads []Rendition, formats []string) (*Profile, error) {
+	profile := &Profile{
+		Name:            DefaultProfile,
+		Description:     "Ladder from the ffmpeg configuration",
+		Renditions:      renditions,
+		Downloads:       downloads,
+		Formats:         formats,
+		SegmentDuration: 10,
+		Packaging:       PackagingSeparate,
+		Audio: AudioSettings{
+			Codec:   "aac",
+			Bitrate: "128k",
+		},
+		Thumbnails: true,
+	}
+
+	if len(profile.Formats) == 0 {
+		profile.Formats = []string{FormatHLS}
+	}
+
+	if err := profile.Validate(); err != nil {
+		return nil, err
+	}
+
+	return profile, nil
+}
+
+// SeedProfile stores a profile unless one with the same name already exists
+func (db *Database) SeedProfile(ctx context.Context, profile *Profile) error {
+	_, err := db.pool.Exec(ctx, `
+		INSERT INTO transcode_profiles (
+			name, description, renditions, segment_duration, audio, formats, packaging,
+			thumbnails, downloads
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (name) DO NOTHING
+	`, profileArgs(profile)...)
+
+	if err != nil {
+		return fmt.Errorf("failed to seed profile: %v", err)
+	}
+
+	return nil
+}
+
+// CreateProfile adds a new transcoding profile
+func (db *Database) CreateProfile(ctx context.Context, profile *Profile) error {
+	if err := profile.Validate(); err != nil {
+		return err
+	}
+
+	err := db.pool.QueryRow(ctx, `
+		INSERT INTO transcode_profiles (
+			name, description, renditions, segment_duration, audio, formats, packaging,
+			thumbnails, downloads
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING created_at, updated_at
+	`, profileArgs(profile)...).Scan(&profile.CreatedAt, &profile.UpdatedAt)
+
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+			return fmt.Errorf("%w: %s", ErrDuplicateProfile, profile.Name)
+		}
+		return fmt.Errorf("failed to insert profile: %v", err)
+	}
+
+	return nil
+}
+
+// UpdateProfile replaces the settings of an existing profile
+func (db *Database) UpdateProfile(ctx context.Context, profile *Profile) error {
+	if err := profile.Validate(); err != nil {
+		return err
+	}
+
+	err := db.pool.QueryRow(ctx, `
+		UPDATE transcode_profiles
+		SET description = $2, renditions = $3, segment_duration = $4, audio = $5,
+			formats = $6, packaging = $7, thumbnails = $8, downloads = $9,
+			updated_at = NOW()
+		WHERE name = $1
+		RETURNING created_at, updated_at
+	`, profileArgs(profile)...).Scan(&profile.CreatedAt, &profile.UpdatedAt)
+
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return fmt.Errorf("%w: %s", ErrProfileNotFound, profile.Name)
+		}
+		return fmt.Errorf("failed to update profile: %v", err)
+	}
+
+	return nil
+}
+
+// DeleteProfile removes a profile. Videos encoded with it keep their streams.
+func (db *Database) DeleteProfile(ctx context.Context, name string) error {
+	if name == DefaultProfile {
+		return ErrDefaultProfile
+	}
+
+	tag, err := db.pool.Exec(ctx, `DELETE FROM transcode_profiles WHERE name = $1`, name)
+	if err != nil {
+		return fmt.Errorf("failed to delete profile: %v", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%w: %s", ErrProfileNotFound, name)
+	}
+
+	return nil
+}
+
+// GetProfile retrieves a profile by name
+func (db *Database) GetProfile(ctx context.Context, name string) (*Profile, error) {
+	profile, err := scanProfile(db.pool.QueryRow(ctx, `
+		SELECT `+profileColumns+`
+		FROM transcode_profiles
+		WHERE name = $1
+	`, name))
+
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, fmt.Errorf("%w: %s", ErrProfileNotFound, name)
+		}
+		return nil, fmt.Errorf("failed to get profile: %v", err)
+	}
+
+	return profile, nil
+}
+
+// ListProfiles retrieves all profiles ordered by name
+func (db *Database) ListProfiles(ctx context.Context) ([]*Profile, error) {
+	rows, err := db.pool.Query(ctx, `
+		SELECT `+profileColumns+`
+		FROM transcode_profiles
+		ORDER BY name
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query profiles: %v", err)
+	}
+	defer rows.Close()
+
+	var profiles []*Profile
+	for rows.Next() {
+		profile, err := scanProfile(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan profile: %v", err)
+		}
+		profiles = append(profiles, profile)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read profiles: %v", err)
+	}
+
+	return profiles, nil
+}
+
+// profileColumns lists the transcode_profiles columns in the order expected by scanProfile
+const profileColumns = `
+			name, description, renditions, segment_duration, audio, formats, packaging,
+			thumbnails, downloads, created_at, updated_at`
+
+// scanProfile scans a row selected with profileColumns into a Profile
+func scanProfile(row pgx.Row) (*Profile, error) {
+	profile := &Profile{}
+	err := row.Scan(
+		&profile.Name,
+		&profile.Description,
+		&profile.Renditions,
+		&profile.SegmentDuration,
+		&profile.Audio,
+		&profile.Formats,
+		&profile.Packaging,
+		&profile.Thumbnails,
+		&profile.Downloads,
+		&profile.CreatedAt,
+		&profile.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return profile, nil
+}
+
+// Helper function to build the insert and update arguments of a profile
+func profileArgs(profile *Profile) []interface{} {
+	return []interface{}{
+		profile.Name,
+		profile.Description,
+		profile.Renditions,
+		profile.SegmentDuration,
+		profile.Audio,
+		profile.Formats,
+		profile.Packaging,
+		profile.Thumbnails,
+		profile.Downloads,
+	}
+}
@@ -2,23 +2,41 @@ package database
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
+	"github.com/falcon/backend/internal/tracing"
+	"github.com/jackc/pgconn"
 	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
-	"github.com/spf13/viper"
 )
 
+// ErrVideoNotFound is returned when a video does not exist
+var ErrVideoNotFound = errors.New("video not found")
+
+// ErrDuplicateVideo is returned when a video ID or slug is already taken
+var ErrDuplicateVideo = errors.New("video already exists")
+
+// ErrDuplicateContent is returned when creating a dedupe original for a content hash
+// that already has one
+var ErrDuplicateContent = errors.New("content already has an original video")
+
+// dedupeOriginalIndex is the unique index allowing one dedupe original per content hash
+const dedupeOriginalIndex = "videos_dedupe_original_idx"
+
+// uniqueViolation is the PostgreSQL error code for unique constraint violations
+const uniqueViolation = "23505"
+
 // DbConfig represents database connection configuration
 type DbConfig struct {
-	Host           string
-	Port           int
-	User           string
-	Password       string
-	DbName         string
-	SSLMode        string
-	MaxConnections int
+	Host           string `mapstructure:"host"`
+	Port           int    `mapstructure:"port"`
+	User           string `mapstructure:"user"`
+	Password       string `mapstructure:"password"`
+	DbName         string `mapstructure:"dbname"`
+	SSLMode        string `mapstructure:"sslmode"`
+	MaxConnections int    `mapstructure:"max_connections"`
 }
 
 // Database represents a database connection
@@ -28,28 +46,71 @@ type Database struct {
 
 // Video represents a video in the database
 type Video struct {
-	ID              string    `json:"id"`
-	Title           string    `json:"title"`
-	OriginalName    string    `json:"original_name"`
-	OriginalPath    string    `json:"original_path"`
-	ProcessingState string    `json:"processing_state"`
-	Duration        float64   `json:"duration"`
-	Size            int64     `json:"size"`
-	ContentType     string    `json:"content_type"`
-	CreatedAt       time.Time `json:"created_at"`
-	UpdatedAt       time.Time `json:"updated_at"`
+	ID              string            `json:"id"`
+	Slug            string            `json:"slug,omitempty"`
+	Title           string            `json:"title"`
+	Description     string            `json:"description"`
+	Tags            []string          `json:"tags"`
+	Metadata        map[string]string `json:"metadata"`
+	ContentHash     string            `json:"content_hash,omitempty"`
+	DedupeOriginal  bool              `json:"-"` // Whether later uploads of the content are deduplicated against this video
+	RenditionsFrom  string            `json:"renditions_from,omitempty"`
+	ActiveVersion   int               `json:"active_version"`
+	Profile         string            `json:"profile"`
+	Packaging       string            `json:"packaging"`
+	OriginalName    string            `json:"original_name"`
+	OriginalPath    string            `json:"original_path"`
+	ProcessingState string            `json:"processing_state"`
+	Duration        float64           `json:"duration"`
+	Size            int64             `json:"size"`
+	ContentType     string            `json:"content_type"`
+	CreatedAt       time.Time         `json:"created_at"`
+	UpdatedAt       time.Time         `json:"updated_at"`
+}
+
+// StorageID returns the video ID under which the video's transcoded files are stored
+func (v *Video) StorageID() string {
+	if v.RenditionsFrom != "" {
+		return v.RenditionsFrom
+	}
+	return v.ID
+}
+
+// StoragePrefix returns the object key prefix of the video's active transcoded files
+func (v *Video) StoragePrefix() string {
+	return StreamPrefix(v.StorageID(), v.ActiveVersion)
+}
+
+// FormatPrefix returns the object key prefix of the video's active files of a format.
+// CMAF packaged videos serve hls and dash from the same files.
+func (v *Video) FormatPrefix(format string) string {
+	if v.Packaging == PackagingCMAF && (format == FormatHLS || format == FormatDASH) {
+		return v.StoragePrefix() + "/" + PackagingCMAF
+	}
+	return v.StoragePrefix() + "/" + format
+}
+
+// StreamPrefix returns the object key prefix of one version of a video's transcoded files.
+// Version 0 is the layout used before outputs were versioned.
+func StreamPrefix(videoID string, version int) string {
+	if version == 0 {
+		return "videos/" + videoID
+	}
+	return fmt.Sprintf("videos/%s/v%d", videoID, version)
 }
 
 // VideoStream represents a transcoded video stream
 type VideoStream struct {
 	ID          string    `json:"id"`
 	VideoID     string    `json:"video_id"`
+	Version     int       `json:"version"`
 	Resolution  string    `json:"resolution"`
 	Bitrate     string    `json:"bitrate"`
+	Codec       string    `json:"codec"`
 	Format      string    `json:"format"`
 	Path        string    `json:"path"`
 	Size        int64     `json:"size"`
-	SegmentSize int       `json:"segment_size"`
+	SegmentSize int       `json:"segment_size"` // Target segment duration in seconds, as written by the packager
 	CreatedAt   time.Time `json:"created_at"`
 }
 
@@ -67,6 +128,10 @@ func NewDatabase(config DbConfig) (*Database, error) {
 
 	poolConfig.MaxConns = int32(config.MaxConnections)
 
+	// Record queries as spans of the operation that issued them
+	poolConfig.ConnConfig.Logger = tracing.QueryLogger{}
+	poolConfig.ConnConfig.LogLevel = pgx.LogLevelInfo
+
 	pool, err := pgxpool.ConnectConfig(context.Background(), poolConfig)
 	if err != nil {
 		return nil, fmt.Errorf("unable to connect to database: %v", err)
@@ -75,141 +140,158 @@ func NewDatabase(config DbConfig) (*Database, error) {
 	return &Database{pool: pool}, nil
 }
 
-// NewDatabaseFromConfig creates a new database connection from Viper config
-func NewDatabaseFromConfig() (*Database, error) {
-	config := DbConfig{
-		Host:           viper.GetString("database.host"),
-		Port:           viper.GetInt("database.port"),
-		User:           viper.GetString("database.user"),
-		Password:       viper.GetString("database.password"),
-		DbName:         viper.GetString("database.dbname"),
-		SSLMode:        viper.GetString("database.sslmode"),
-		MaxConnections: viper.GetInt("database.max_connections"),
-	}
-
-	return NewDatabase(config)
-}
-
 // Close closes the database connection
 func (db *Database) Close() {
 	db.pool.Close()
 }
 
+// Ping checks that a connection can be acquired and queried
+func (db *Database) Ping(ctx context.Context) error {
+	var one int
+	return db.pool.QueryRow(ctx, "SELECT 1").Scan(&one)
+}
+
 // Pool returns the underlying connection pool
 func (db *Database) Pool() *pgxpool.Pool {
 	return db.pool
 }
 
-// CreateTables creates all required database tables
-func (db *Database) CreateTables(ctx context.Context) error {
-	// Create videos table
-	_, err := db.pool.Exec(ctx, `
-		CREATE TABLE IF NOT EXISTS videos (
-			id TEXT PRIMARY KEY,
-			title TEXT NOT NULL,
-			original_name TEXT NOT NULL,
-			original_path TEXT NOT NULL,
-			processing_state TEXT NOT NULL,
-			duration FLOAT DEFAULT 0,
-			size BIGINT NOT NULL,
-			content_type TEXT NOT NULL,
-			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
-			updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
-		)
-	`)
+// CreateVideo adds a new video to the database
+func (db *Database) CreateVideo(ctx context.Context, video *Video) error {
+	tx, err := db.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := insertVideo(ctx, tx, video); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	return nil
+}
+
+// CreateReusedVideo adds a new video that shares the transcoded streams of an existing one
+func (db *Database) CreateReusedVideo(ctx context.Context, video *Video, sourceID string) error {
+	tx, err := db.pool.Begin(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to create videos table: %v", err)
-	}
-
-	// Create video_streams table
-	_, err = db.pool.Exec(ctx, `
-		CREATE TABLE IF NOT EXISTS video_streams (
-			id TEXT PRIMARY KEY,
-			video_id TEXT NOT NULL REFERENCES videos(id) ON DELETE CASCADE,
-			resolution TEXT NOT NULL,
-			bitrate TEXT NOT NULL,
-			format TEXT NOT NULL,
-			path TEXT NOT NULL,
-			size BIGINT DEFAULT 0,
-			segment_size INTEGER DEFAULT 0,
-			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
-			UNIQUE(video_id, resolution, format)
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := insertVideo(ctx, tx, video); err != nil {
+		return err
+	}
+
+	// Stream IDs are prefixed with the video ID, keep that convention for the copies
+	_, err = tx.Exec(ctx, `
+		INSERT INTO video_streams (
+			id, video_id, version, resolution, bitrate, codec, format, path, size, segment_size,
+			created_at
 		)
-	`)
+		SELECT
+			$2 || substring(id FROM length($1) + 1), $2, version,
+			resolution, bitrate, codec, format, path, size, segment_size, NOW()
+		FROM video_streams
+		WHERE video_id = $1 AND version = $3
+	`, sourceID, video.ID, video.ActiveVersion)
 	if err != nil {
-		return fmt.Errorf("failed to create video_streams table: %v", err)
+		return fmt.Errorf("failed to copy video streams: %v", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
 	}
 
 	return nil
 }
 
-// CreateVideo adds a new video to the database
-func (db *Database) CreateVideo(ctx context.Context, video *Video) error {
-	_, err := db.pool.Exec(ctx, `
+// insertVideo inserts a video row and records its initial state as the first event
+func insertVideo(ctx context.Context, tx pgx.Tx, video *Video) error {
+	_, err := tx.Exec(ctx, `
 		INSERT INTO videos (
-			id, title, original_name, original_path, processing_state, 
-			size, content_type, created_at, updated_at
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+			id, slug, title, description, tags, metadata, original_name, original_path,
+			processing_state, duration, size, content_type, content_hash, dedupe_original,
+			renditions_from, active_version, profile, packaging, created_at, updated_at
+		) VALUES (
+			$1, NULLIF($2, ''), $3, $4, $5, $6, $7, $8,
+			$9, $10, $11, $12, NULLIF($13, ''), $14, NULLIF($15, ''),
+			$16, COALESCE(NULLIF($17, ''), '`+DefaultProfile+`'),
+			COALESCE(NULLIF($18, ''), '`+PackagingSeparate+`'), $19, $20
+		)
 	`,
 		video.ID,
+		video.Slug,
 		video.Title,
+		video.Description,
+		nonNilTags(video.Tags),
+		nonNilMetadata(video.Metadata),
 		video.OriginalName,
 		video.OriginalPath,
 		video.ProcessingState,
+		video.Duration,
 		video.Size,
 		video.ContentType,
+		video.ContentHash,
+		video.DedupeOriginal,
+		video.RenditionsFrom,
+		video.ActiveVersion,
+		video.Profile,
+		video.Packaging,
 		video.CreatedAt,
 		video.UpdatedAt,
 	)
 
 	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+			if pgErr.ConstraintName == dedupeOriginalIndex {
+				return fmt.Errorf("%w: content hash %s", ErrDuplicateContent, video.ContentHash)
+			}
+			return fmt.Errorf("%w: %s", ErrDuplicateVideo, video.ID)
+		}
 		return fmt.Errorf("failed to insert video: %v", err)
 	}
 
-	return nil
+	return insertVideoEvent(ctx, tx, &VideoEvent{
+		VideoID: video.ID,
+		ToState: video.ProcessingState,
+	})
 }
 
-// UpdateVideoStatus updates the processing state of a video
-func (db *Database) UpdateVideoStatus(ctx context.Context, videoID, status string) error {
-	_, err := db.pool.Exec(ctx, `
-		UPDATE videos 
-		SET processing_state = $1, updated_at = NOW() 
-		WHERE id = $2
-	`, status, videoID)
+// GetVideo retrieves a video by ID
+func (db *Database) GetVideo(ctx context.Context, videoID string) (*Video, error) {
+	video, err := scanVideo(db.pool.QueryRow(ctx, `
+		SELECT `+videoColumns+`
+		FROM videos
+		WHERE id = $1
+	`, videoID))
 
 	if err != nil {
-		return fmt.Errorf("failed to update video status: %v", err)
+		if err == pgx.ErrNoRows {
+			return nil, fmt.Errorf("%w: %s", ErrVideoNotFound, videoID)
+		}
+		return nil, fmt.Errorf("failed to get video: %v", err)
 	}
 
-	return nil
+	return video, nil
 }
 
-// GetVideo retrieves a video by ID
-func (db *Database) GetVideo(ctx context.Context, videoID string) (*Video, error) {
-	video := &Video{}
-
-	err := db.pool.QueryRow(ctx, `
-		SELECT 
-			id, title, original_name, original_path, processing_state,
-			duration, size, content_type, created_at, updated_at
+// GetVideoByIDOrSlug retrieves a video by its ID or public slug
+func (db *Database) GetVideoByIDOrSlug(ctx context.Context, key string) (*Video, error) {
+	video, err := scanVideo(db.pool.QueryRow(ctx, `
+		SELECT `+videoColumns+`
 		FROM videos
-		WHERE id = $1
-	`, videoID).Scan(
-		&video.ID,
-		&video.Title,
-		&video.OriginalName,
-		&video.OriginalPath,
-		&video.ProcessingState,
-		&video.Duration,
-		&video.Size,
-		&video.ContentType,
-		&video.CreatedAt,
-		&video.UpdatedAt,
-	)
+		WHERE id = $1 OR slug = $1
+	`, key))
 
 	if err != nil {
 		if err == pgx.ErrNoRows {
-			return nil, fmt.Errorf("video not found: %s", videoID)
+			return nil, fmt.Errorf("%w: %s", ErrVideoNotFound, key)
 		}
 		return nil, fmt.Errorf("failed to get video: %v", err)
 	}
@@ -217,22 +299,127 @@ func (db *Database) GetVideo(ctx context.Context, videoID string) (*Video, error
 	return video, nil
 }
 
+// FindVideoByContentHash retrieves the earliest existing video with the given content
+// hash, preferring videos that finished transcoding. Every state but error counts as
+// existing: uploaded and in-progress videos are being transcoded, and videos that
+// failed to schedule are rescheduled by the uploader.
+func (db *Database) FindVideoByContentHash(ctx context.Context, contentHash string) (*Video, error) {
+	video, err := scanVideo(db.pool.QueryRow(ctx, `
+		SELECT `+videoColumns+`
+		FROM videos
+		WHERE content_hash = $1 AND processing_state <> '`+StateError+`'
+		ORDER BY processing_state = '`+StateCompleted+`' DESC, created_at ASC
+		LIMIT 1
+	`, contentHash))
+
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, fmt.Errorf("%w: content hash %s", ErrVideoNotFound, contentHash)
+		}
+		return nil, fmt.Errorf("failed to find video by content hash: %v", err)
+	}
+
+	return video, nil
+}
+
 // AddVideoStream adds a transcoded stream for a video
 func (db *Database) AddVideoStream(ctx context.Context, stream *VideoStream) error {
-	_, err := db.pool.Exec(ctx, `
+	return insertVideoStream(ctx, db.pool, stream)
+}
+
+// StreamSet is one encode of a video, activated as a whole
+type StreamSet struct {
+	VideoID   string
+	Version   int
+	Profile   string
+	Packaging string
+	Streams   []*VideoStream
+}
+
+// ActivateStreams records a new version of a video's streams and makes it the active one
+// in a single transaction, so playback switches only once the whole set is in place.
+// A video that shared the streams of another one owns its streams from then on.
+func (db *Database) ActivateStreams(ctx context.Context, set StreamSet) error {
+	tx, err := db.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	for _, stream := range set.Streams {
+		stream.VideoID = set.VideoID
+		stream.Version = set.Version
+		if err := insertVideoStream(ctx, tx, stream); err != nil {
+			return err
+		}
+	}
+
+	tag, err := tx.Exec(ctx, `
+		UPDATE videos
+		SET active_version = $2, profile = $3, packaging = $4, renditions_from = NULL,
+			updated_at = NOW()
+		WHERE id = $1
+	`, set.VideoID, set.Version, set.Profile, set.Packaging)
+	if err != nil {
+		return fmt.Errorf("failed to activate stream version: %v", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%w: %s", ErrVideoNotFound, set.VideoID)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	return nil
+}
+
+// NextStreamVersion returns the version number for a new encode of a video
+func (db *Database) NextStreamVersion(ctx context.Context, videoID string) (int, error) {
+	var version int
+	err := db.pool.QueryRow(ctx, `
+		SELECT GREATEST(
+			v.active_version,
+			COALESCE((SELECT MAX(version) FROM video_streams WHERE video_id = v.id), 0)
+		) + 1
+		FROM videos v
+		WHERE v.id = $1
+	`, videoID).Scan(&version)
+
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return 0, fmt.Errorf("%w: %s", ErrVideoNotFound, videoID)
+		}
+		return 0, fmt.Errorf("failed to get next stream version: %v", err)
+	}
+
+	return version, nil
+}
+
+// execer is implemented by both the pool and transactions
+type execer interface {
+	Exec(ctx context.Context, sql string, arguments ...interface{}) (pgconn.CommandTag, error)
+}
+
+// insertVideoStream inserts or replaces a stream of one version of a video
+func insertVideoStream(ctx context.Context, conn execer, stream *VideoStream) error {
+	_, err := conn.Exec(ctx, `
 		INSERT INTO video_streams (
-			id, video_id, resolution, bitrate, format, path, size, segment_size, created_at
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
-		ON CONFLICT (video_id, resolution, format) 
-		DO UPDATE SET 
+			id, video_id, version, resolution, bitrate, codec, format, path, size, segment_size,
+			created_at
+		) VALUES ($1, $2, $3, $4, $5, COALESCE(NULLIF($6, ''), 'h264'), $7, $8, $9, $10, $11)
+		ON CONFLICT (video_id, version, resolution, format, codec)
+		DO UPDATE SET
 			path = EXCLUDED.path,
 			size = EXCLUDED.size,
 			segment_size = EXCLUDED.segment_size
 	`,
 		stream.ID,
 		stream.VideoID,
+		stream.Version,
 		stream.Resolution,
 		stream.Bitrate,
+		stream.Codec,
 		stream.Format,
 		stream.Path,
 		stream.Size,
@@ -247,14 +434,16 @@ func (db *Database) AddVideoStream(ctx context.Context, stream *VideoStream) err
 	return nil
 }
 
-// GetVideoStreams retrieves all streams for a video
+// GetVideoStreams retrieves the active streams of a video
 func (db *Database) GetVideoStreams(ctx context.Context, videoID string) ([]*VideoStream, error) {
 	rows, err := db.pool.Query(ctx, `
-		SELECT 
-			id, video_id, resolution, bitrate, format, path, size, segment_size, created_at
-		FROM video_streams
-		WHERE video_id = $1
-		ORDER BY resolution DESC
+		SELECT
+			s.id, s.video_id, s.version, s.resolution, s.bitrate, s.codec, s.format, s.path,
+			s.size, s.segment_size, s.created_at
+		FROM video_streams s
+		JOIN videos v ON v.id = s.video_id AND v.active_version = s.version
+		WHERE s.video_id = $1
+		ORDER BY s.resolution DESC
 	`, videoID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query video streams: %v", err)
@@ -267,8 +456,10 @@ func (db *Database) GetVideoStreams(ctx context.Context, videoID string) ([]*Vid
 		err := rows.Scan(
 			&stream.ID,
 			&stream.VideoID,
+			&stream.Version,
 			&stream.Resolution,
 			&stream.Bitrate,
+			&stream.Codec,
 			&stream.Format,
 			&stream.Path,
 			&stream.Size,
@@ -287,9 +478,7 @@ func (db *Database) GetVideoStreams(ctx context.Context, videoID string) ([]*Vid
 // ListVideos retrieves a list of videos with pagination
 func (db *Database) ListVideos(ctx context.Context, limit, offset int) ([]*Video, error) {
 	rows, err := db.pool.Query(ctx, `
-		SELECT 
-			id, title, original_name, original_path, processing_state,
-			duration, size, content_type, created_at, updated_at
+		SELECT `+videoColumns+`
 		FROM videos
 		ORDER BY created_at DESC
 		LIMIT $1 OFFSET $2
@@ -301,24 +490,96 @@ func (db *Database) ListVideos(ctx context.Context, limit, offset int) ([]*Video
 
 	var videos []*Video
 	for rows.Next() {
-		video := &Video{}
-		err := rows.Scan(
-			&video.ID,
-			&video.Title,
-			&video.OriginalName,
-			&video.OriginalPath,
-			&video.ProcessingState,
-			&video.Duration,
-			&video.Size,
-			&video.ContentType,
-			&video.CreatedAt,
-			&video.UpdatedAt,
-		)
+		video, err := scanVideo(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan video: %v", err)
+		}
+		videos = append(videos, video)
+	}
+
+	return videos, nil
+}
+
+// ListVideosByState retrieves the oldest videos in the given processing state
+func (db *Database) ListVideosByState(ctx context.Context, state string, limit int) ([]*Video, error) {
+	rows, err := db.pool.Query(ctx, `
+		SELECT `+videoColumns+`
+		FROM videos
+		WHERE processing_state = $1
+		ORDER BY updated_at ASC
+		LIMIT $2
+	`, state, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query videos: %v", err)
+	}
+	defer rows.Close()
+
+	var videos []*Video
+	for rows.Next() {
+		video, err := scanVideo(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan video: %v", err)
 		}
 		videos = append(videos, video)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read videos: %v", err)
+	}
 
 	return videos, nil
-} 
\ No newline at end of file
+}
+
+// videoColumns lists the videos columns in the order expected by scanVideo
+const videoColumns = `
+			id, COALESCE(slug, ''), title, description, tags, metadata, original_name, original_path,
+			processing_state, duration, size, content_type, COALESCE(content_hash, ''),
+			dedupe_original, COALESCE(renditions_from, ''), active_version, profile, packaging,
+			created_at, updated_at`
+
+// scanVideo scans a row selected with videoColumns into a Video
+func scanVideo(row pgx.Row) (*Video, error) {
+	video := &Video{}
+	err := row.Scan(
+		&video.ID,
+		&video.Slug,
+		&video.Title,
+		&video.Description,
+		&video.Tags,
+		&video.Metadata,
+		&video.OriginalName,
+		&video.OriginalPath,
+		&video.ProcessingState,
+		&video.Duration,
+		&video.Size,
+		&video.ContentType,
+		&video.ContentHash,
+		&video.DedupeOriginal,
+		&video.RenditionsFrom,
+		&video.ActiveVersion,
+		&video.Profile,
+		&video.Packaging,
+		&video.CreatedAt,
+		&video.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return video, nil
+}
+
+// Helper function to store empty tags instead of NULL
+func nonNilTags(tags []string) []string {
+	if tags == nil {
+		return []string{}
+	}
+	return tags
+}
+
+// Helper function to store an empty JSON object instead of null
+func nonNilMetadata(metadata map[string]string) map[string]string {
+	if metadata == nil {
+		return map[string]string{}
+	}
+	return metadata
+}
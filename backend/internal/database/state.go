@@ -0,0 +1,201 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// Processing states of a video
+const (
+	StateUploaded         = "uploaded"
+	StateFailedToSchedule = "failed_to_schedule"
+	StateProcessing       = "processing"
+	StateDownloading      = "downloading"
+	StateAnalyzing        = "analyzing"
+	StateTranscoding      = "transcoding"
+	StateCompleted        = "completed"
+	StateError            = "error"
+)
+
+// ErrIllegalTransition is returned when a video cannot move to the requested state
+var ErrIllegalTransition = errors.New("illegal state transition")
+
+// transitions lists the states each state may move to
+var transitions = map[string][]string{
+	StateUploaded:         {StateProcessing, StateFailedToSchedule, StateError},
+	StateFailedToSchedule: {StateUploaded, StateError},
+	StateProcessing:       {StateDownloading, StateError},
+	StateDownloading:      {StateAnalyzing, StateError},
+	StateAnalyzing:        {StateTranscoding, StateError},
+	StateTranscoding:      {StateCompleted, StateError},
+	StateCompleted:        {},
+	StateError:            {StateUploaded},
+}
+
+// CanTransition reports whether a video may move from one state to another
+func CanTransition(from, to string) bool {
+	for _, allowed := range transitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// IsValidState reports whether a state is known
+func IsValidState(state string) bool {
+	_, ok := transitions[state]
+	return ok
+}
+
+// Transition describes a requested state change and its context
+type Transition struct {
+	VideoID    string
+	To         string
+	Error      string
+	WorkflowID string
+	RunID      string
+}
+
+// VideoEvent records a state transition of a video
+type VideoEvent struct {
+	ID           int64     `json:"id"`
+	VideoID      string    `json:"video_id"`
+	FromState    string    `json:"from_state"`
+	ToState      string    `json:"to_state"`
+	ErrorMessage string    `json:"error_message,omitempty"`
+	WorkflowID   string    `json:"workflow_id,omitempty"`
+	RunID        string    `json:"run_id,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// UpdateVideoStatus moves a video to a new processing state, rejecting illegal transitions
+func (db *Database) UpdateVideoStatus(ctx context.Context, videoID, status string) error {
+	return db.TransitionVideo(ctx, Transition{VideoID: videoID, To: status})
+}
+
+// TransitionVideo atomically checks and applies a state transition and records it as an event.
+// Moving a video to the state it is already in is a no-op, so retried activities are safe.
+func (db *Database) TransitionVideo(ctx context.Context, t Transition) error {
+	if !IsValidState(t.To) {
+		return fmt.Errorf("unknown processing state: %s", t.To)
+	}
+
+	tx, err := db.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Lock the row so concurrent transitions are serialized
+	var from string
+	err = tx.QueryRow(ctx, `
+		SELECT processing_state FROM videos WHERE id = $1 FOR UPDATE
+	`, t.VideoID).Scan(&from)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return fmt.Errorf("%w: %s", ErrVideoNotFound, t.VideoID)
+		}
+		return fmt.Errorf("failed to get video state: %v", err)
+	}
+
+	if from == t.To {
+		return nil
+	}
+
+	if !CanTransition(from, t.To) {
+		return fmt.Errorf("%w: %s cannot move from %s to %s", ErrIllegalTransition, t.VideoID, from, t.To)
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE videos
+		SET processing_state = $1, updated_at = NOW()
+		WHERE id = $2
+	`, t.To, t.VideoID)
+	if err != nil {
+		return fmt.Errorf("failed to update video status: %v", err)
+	}
+
+	err = insertVideoEvent(ctx, tx, &VideoEvent{
+		VideoID:      t.VideoID,
+		FromState:    from,
+		ToState:      t.To,
+		ErrorMessage: t.Error,
+		WorkflowID:   t.WorkflowID,
+		RunID:        t.RunID,
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	return nil
+}
+
+// GetVideoEvents retrieves the state transitions of a video, oldest first
+func (db *Database) GetVideoEvents(ctx context.Context, videoID string) ([]*VideoEvent, error) {
+	rows, err := db.pool.Query(ctx, `
+		SELECT
+			id, video_id, from_state, to_state, error_message, workflow_id, run_id, created_at
+		FROM video_events
+		WHERE video_id = $1
+		ORDER BY id ASC
+	`, videoID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query video events: %v", err)
+	}
+	defer rows.Close()
+
+	var events []*VideoEvent
+	for rows.Next() {
+		event := &VideoEvent{}
+		err := rows.Scan(
+			&event.ID,
+			&event.VideoID,
+			&event.FromState,
+			&event.ToState,
+			&event.ErrorMessage,
+			&event.WorkflowID,
+			&event.RunID,
+			&event.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan video event: %v", err)
+		}
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read video events: %v", err)
+	}
+
+	return events, nil
+}
+
+// insertVideoEvent records a state transition inside the caller's transaction
+func insertVideoEvent(ctx context.Context, tx pgx.Tx, event *VideoEvent) error {
+	_, err := tx.Exec(ctx, `
+		INSERT INTO video_events (
+			video_id, from_state, to_state, error_message, workflow_id, run_id
+		) VALUES ($1, $2, $3, $4, $5, $6)
+	`,
+		event.VideoID,
+		event.FromState,
+		event.ToState,
+		event.ErrorMessage,
+		event.WorkflowID,
+		event.RunID,
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to insert video event: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,81 @@
+package database
+
+import "testing"
+
+func TestCanTransition(t *testing.T) {
+	tests := []struct {
+		from, to string
+		want     bool
+	}{
+		{StateUploaded, StateProcessing, true},
+		{StateUploaded, StateFailedToSchedule, true},
+		{StateUploaded, StateError, true},
+		{StateUploaded, StateCompleted, false},
+		{StateFailedToSchedule, StateUploaded, true},
+		{StateFailedToSchedule, StateProcessing, false},
+		{StateProcessing, StateDownloading, true},
+		{StateProcessing, StateTranscoding, false},
+		{StateDownloading, StateAnalyzing, true},
+		{StateAnalyzing, StateTranscoding, true},
+		{StateTranscoding, StateCompleted, true},
+		{StateTranscoding, StateProcessing, false},
+		{StateCompleted, StateError, false},
+		{StateCompleted, StateUploaded, false},
+		{StateError, StateUploaded, true},
+		{StateError, StateProcessing, false},
+		{StateUploaded, StateUploaded, false},
+		{"unknown", StateUploaded, false},
+		{StateUploaded, "unknown", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.from+"->"+tt.to, func(t *testing.T) {
+			if got := CanTransition(tt.from, tt.to); got != tt.want {
+				t.Errorf("CanTransition(%q, %q) = %v, want %v", tt.from, tt.to, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEveryStateCanFail(t *testing.T) {
+	for state := range transitions {
+		if state == StateCompleted || state == StateError {
+			continue
+		}
+		if !CanTransition(state, StateError) {
+			t.Errorf("CanTransition(%q, %q) = false, want true", state, StateError)
+		}
+	}
+}
+
+func TestTransitionsTargetKnownStates(t *testing.T) {
+	for from, targets := range transitions {
+		for _, to := range targets {
+			if !IsValidState(to) {
+				t.Errorf("transition %q -> %q targets an unknown state", from, to)
+			}
+		}
+	}
+}
+
+func TestIsValidState(t *testing.T) {
+	tests := []struct {
+		state string
+		want  bool
+	}{
+		{StateUploaded, true},
+		{StateFailedToSchedule, true},
+		{StateCompleted, true},
+		{StateError, true},
+		{"", false},
+		{"Completed", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.state, func(t *testing.T) {
+			if got := IsValidState(tt.state); got != tt.want {
+				t.Errorf("IsValidState(%q) = %v, want %v", tt.state, got, tt.want)
+			}
+		})
+	}
+}
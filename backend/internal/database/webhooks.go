@@ -0,0 +1,421 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// Webhook event types
+const (
+	EventVideoUploaded    = "video.uploaded"
+	EventVideoTranscoding = "video.transcoding"
+	EventVideoReady       = "video.ready"
+	EventVideoFailed      = "video.failed"
+)
+
+// WebhookEvents lists every event type a subscription can filter on
+var WebhookEvents = []string{EventVideoUploaded, EventVideoTranscoding, EventVideoReady, EventVideoFailed}
+
+// Webhook delivery statuses
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+)
+
+// ErrWebhookNotFound is returned when a webhook subscription does not exist
+var ErrWebhookNotFound = errors.New("webhook not found")
+
+// ErrInvalidWebhook is returned when a webhook subscription fails validation
+var ErrInvalidWebhook = errors.New("invalid webhook")
+
+// ErrDeliveryNotFound is returned when a webhook delivery does not exist
+var ErrDeliveryNotFound = errors.New("webhook delivery not found")
+
+// minSecretLength is the shortest accepted signing secret
+const minSecretLength = 16
+
+// WebhookSubscription is an endpoint notified of video lifecycle events
+type WebhookSubscription struct {
+	ID  int64  `json:"id"`
+	URL string `json:"url"`
+	// Secret signs every delivery, it is only returned when the subscription is created
+	Secret string `json:"secret,omitempty"`
+	// Events filters the event types delivered, empty for every event
+	Events      []string  `json:"events"`
+	Description string    `json:"description"`
+	Active      bool      `json:"active"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// Validate checks the URL, secret and event filter of a subscription
+func (s *WebhookSubscription) Validate() error {
+	return s.validate(true)
+}
+
+// validate checks a subscription, skipping the secret when an update keeps the stored one
+func (s *WebhookSubscription) validate(checkSecret bool) error {
+	u, err := url.Parse(s.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%w: url must be an absolute http or https URL", ErrInvalidWebhook)
+	}
+
+	if checkSecret && len(s.Secret) < minSecretLength {
+		return fmt.Errorf("%w: secret must be at least %d characters", ErrInvalidWebhook, minSecretLength)
+	}
+
+	if s.Events == nil {
+		s.Events = []string{}
+	}
+	seen := make(map[string]bool)
+	for _, event := range s.Events {
+		if !isWebhookEvent(event) {
+			return fmt.Errorf("%w: unknown event %q", ErrInvalidWebhook, event)
+		}
+		if seen[event] {
+			return fmt.Errorf("%w: duplicate event %q", ErrInvalidWebhook, event)
+		}
+		seen[event] = true
+	}
+
+	return nil
+}
+
+// Helper function to check an event type against WebhookEvents
+func isWebhookEvent(event string) bool {
+	for _, known := range WebhookEvents {
+		if event == known {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookDelivery records the delivery of one event to one subscription
+type WebhookDelivery struct {
+	ID             int64           `json:"id"`
+	SubscriptionID int64           `json:"subscription_id"`
+	EventID        string          `json:"event_id"`
+	EventType      string          `json:"event_type"`
+	VideoID        string          `json:"video_id,omitempty"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int32           `json:"attempts"`
+	ResponseStatus *int32          `json:"response_status,omitempty"`
+	ErrorMessage   string          `json:"error_message,omitempty"`
+	RedeliveryOf   *int64          `json:"redelivery_of,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	LastAttemptAt  *time.Time      `json:"last_attempt_at,omitempty"`
+	DeliveredAt    *time.Time      `json:"delivered_at,omitempty"`
+}
+
+// CreateWebhook adds a new webhook subscription
+func (db *Database) CreateWebhook(ctx context.Context, sub *WebhookSubscription) error {
+	if err := sub.Validate(); err != nil {
+		return err
+	}
+
+	err := db.pool.QueryRow(ctx, `
+		INSERT INTO webhook_subscriptions (url, secret, events, description, active)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at, updated_at
+	`, sub.URL, sub.Secret, sub.Events, sub.Description, sub.Active).Scan(&sub.ID, &sub.CreatedAt, &sub.UpdatedAt)
+
+	if err != nil {
+		return fmt.Errorf("failed to insert webhook: %v", err)
+	}
+
+	return nil
+}
+
+// UpdateWebhook replaces the settings of a subscription. An empty secret keeps the
+// current one.
+func (db *Database) UpdateWebhook(ctx context.Context, sub *WebhookSubscription) error {
+	if err := sub.validate(sub.Secret != ""); err != nil {
+		return err
+	}
+
+	err := db.pool.QueryRow(ctx, `
+		UPDATE webhook_subscriptions
+		SET url = $2, secret = COALESCE(NULLIF($3, ''), secret), events = $4,
+			description = $5, active = $6, updated_at = NOW()
+		WHERE id = $1
+		RETURNING created_at, updated_at
+	`, sub.ID, sub.URL, sub.Secret, sub.Events, sub.Description, sub.Active).Scan(&sub.CreatedAt, &sub.UpdatedAt)
+
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return fmt.Errorf("%w: %d", ErrWebhookNotFound, sub.ID)
+		}
+		return fmt.Errorf("failed to update webhook: %v", err)
+	}
+
+	return nil
+}
+
+// DeleteWebhook removes a subscription and its delivery log
+func (db *Database) DeleteWebhook(ctx context.Context, id int64) error {
+	tag, err := db.pool.Exec(ctx, `DELETE FROM webhook_subscriptions WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook: %v", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%w: %d", ErrWebhookNotFound, id)
+	}
+
+	return nil
+}
+
+// GetWebhook retrieves a subscription by ID, including its secret
+func (db *Database) GetWebhook(ctx context.Context, id int64) (*WebhookSubscription, error) {
+	sub, err := scanWebhook(db.pool.QueryRow(ctx, `
+		SELECT `+webhookColumns+`
+		FROM webhook_subscriptions
+		WHERE id = $1
+	`, id))
+
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, fmt.Errorf("%w: %d", ErrWebhookNotFound, id)
+		}
+		return nil, fmt.Errorf("failed to get webhook: %v", err)
+	}
+
+	return sub, nil
+}
+
+// ListWebhooks retrieves all subscriptions ordered by ID, including their secrets
+func (db *Database) ListWebhooks(ctx context.Context) ([]*WebhookSubscription, error) {
+	rows, err := db.pool.Query(ctx, `
+		SELECT `+webhookColumns+`
+		FROM webhook_subscriptions
+		ORDER BY id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query webhooks: %v", err)
+	}
+	defer rows.Close()
+
+	var subs []*WebhookSubscription
+	for rows.Next() {
+		sub, err := scanWebhook(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook: %v", err)
+		}
+		subs = append(subs, sub)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read webhooks: %v", err)
+	}
+
+	return subs, nil
+}
+
+// CreateWebhookDeliveries records a pending delivery of an event for every active
+// subscription whose filter matches it, and returns the IDs of the deliveries.
+// Recording the same event again returns the existing deliveries.
+func (db *Database) CreateWebhookDeliveries(ctx context.Context, eventID, eventType, videoID string, payload json.RawMessage) ([]int64, error) {
+	_, err := db.pool.Exec(ctx, `
+		INSERT INTO webhook_deliveries (subscription_id, event_id, event_type, video_id, payload, status)
+		SELECT id, $1, $2, $3, $4, $5
+		FROM webhook_subscriptions
+		WHERE active AND (cardinality(events) = 0 OR $2 = ANY(events))
+		ON CONFLICT (event_id, subscription_id) WHERE redelivery_of IS NULL DO NOTHING
+	`, eventID, eventType, videoID, string(payload), DeliveryPending)
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert webhook deliveries: %v", err)
+	}
+
+	rows, err := db.pool.Query(ctx, `
+		SELECT id FROM webhook_deliveries
+		WHERE event_id = $1 AND redelivery_of IS NULL
+		ORDER BY id
+	`, eventID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query webhook deliveries: %v", err)
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan webhook delivery: %v", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read webhook deliveries: %v", err)
+	}
+
+	return ids, nil
+}
+
+// GetWebhookDelivery retrieves a delivery by ID
+func (db *Database) GetWebhookDelivery(ctx context.Context, id int64) (*WebhookDelivery, error) {
+	delivery, err := scanWebhookDelivery(db.pool.QueryRow(ctx, `
+		SELECT `+deliveryColumns+`
+		FROM webhook_deliveries
+		WHERE id = $1
+	`, id))
+
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, fmt.Errorf("%w: %d", ErrDeliveryNotFound, id)
+		}
+		return nil, fmt.Errorf("failed to get webhook delivery: %v", err)
+	}
+
+	return delivery, nil
+}
+
+// ListWebhookDeliveries retrieves the latest deliveries of a subscription, newest first
+func (db *Database) ListWebhookDeliveries(ctx context.Context, subscriptionID int64, limit int) ([]*WebhookDelivery, error) {
+	rows, err := db.pool.Query(ctx, `
+		SELECT `+deliveryColumns+`
+		FROM webhook_deliveries
+		WHERE subscription_id = $1
+		ORDER BY id DESC
+		LIMIT $2
+	`, subscriptionID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query webhook deliveries: %v", err)
+	}
+	defer rows.Close()
+
+	var deliveries []*WebhookDelivery
+	for rows.Next() {
+		delivery, err := scanWebhookDelivery(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook delivery: %v", err)
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read webhook deliveries: %v", err)
+	}
+
+	return deliveries, nil
+}
+
+// RecordWebhookAttempt stores the outcome of a delivery attempt. A response status
+// of 0 means no response was received. Attempts of a delivery that already
+// succeeded do not change it.
+func (db *Database) RecordWebhookAttempt(ctx context.Context, id int64, attempt int32, responseStatus int, errMsg string, delivered bool) error {
+	var status *int
+	if responseStatus != 0 {
+		status = &responseStatus
+	}
+
+	_, err := db.pool.Exec(ctx, `
+		UPDATE webhook_deliveries
+		SET attempts = GREATEST(attempts, $2), response_status = $3, error_message = $4,
+			last_attempt_at = NOW(),
+			status = CASE WHEN $5 THEN $6 ELSE status END,
+			delivered_at = CASE WHEN $5 THEN NOW() ELSE delivered_at END
+		WHERE id = $1 AND status <> $6
+	`, id, attempt, status, errMsg, delivered, DeliverySucceeded)
+
+	if err != nil {
+		return fmt.Errorf("failed to record webhook attempt: %v", err)
+	}
+
+	return nil
+}
+
+// FailWebhookDelivery marks a delivery as failed once its retries are exhausted
+func (db *Database) FailWebhookDelivery(ctx context.Context, id int64) error {
+	_, err := db.pool.Exec(ctx, `
+		UPDATE webhook_deliveries SET status = $2 WHERE id = $1 AND status = $3
+	`, id, DeliveryFailed, DeliveryPending)
+
+	if err != nil {
+		return fmt.Errorf("failed to fail webhook delivery: %v", err)
+	}
+
+	return nil
+}
+
+// RedeliverWebhook records a new pending delivery of the same payload to the same
+// subscription as an earlier delivery
+func (db *Database) RedeliverWebhook(ctx context.Context, id int64) (*WebhookDelivery, error) {
+	delivery, err := scanWebhookDelivery(db.pool.QueryRow(ctx, `
+		INSERT INTO webhook_deliveries (
+			subscription_id, event_id, event_type, video_id, payload, status, redelivery_of
+		)
+		SELECT subscription_id, event_id, event_type, video_id, payload, $2, id
+		FROM webhook_deliveries
+		WHERE id = $1
+		RETURNING `+deliveryColumns, id, DeliveryPending))
+
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, fmt.Errorf("%w: %d", ErrDeliveryNotFound, id)
+		}
+		return nil, fmt.Errorf("failed to insert webhook redelivery: %v", err)
+	}
+
+	return delivery, nil
+}
+
+// webhookColumns lists the webhook_subscriptions columns in the order expected by scanWebhook
+const webhookColumns = `
+			id, url, secret, events, description, active, created_at, updated_at`
+
+// scanWebhook scans a row selected with webhookColumns into a WebhookSubscription
+func scanWebhook(row pgx.Row) (*WebhookSubscription, error) {
+	sub := &WebhookSubscription{}
+	err := row.Scan(
+		&sub.ID,
+		&sub.URL,
+		&sub.Secret,
+		&sub.Events,
+		&sub.Description,
+		&sub.Active,
+		&sub.CreatedAt,
+		&sub.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return sub, nil
+}
+
+// deliveryColumns lists the webhook_deliveries columns in the order expected by scanWebhookDelivery
+const deliveryColumns = `
+			id, subscription_id, event_id, event_type, video_id, payload, status, attempts,
+			response_status, error_message, redelivery_of, created_at, last_attempt_at, delivered_at`
+
+// scanWebhookDelivery scans a row selected with deliveryColumns into a WebhookDelivery
+func scanWebhookDelivery(row pgx.Row) (*WebhookDelivery, error) {
+	delivery := &WebhookDelivery{}
+	err := row.Scan(
+		&delivery.ID,
+		&delivery.SubscriptionID,
+		&delivery.EventID,
+		&delivery.EventType,
+		&delivery.VideoID,
+		&delivery.Payload,
+		&delivery.Status,
+		&delivery.Attempts,
+		&delivery.ResponseStatus,
+		&delivery.ErrorMessage,
+		&delivery.RedeliveryOf,
+		&delivery.CreatedAt,
+		&delivery.LastAttemptAt,
+		&delivery.DeliveredAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return delivery, nil
+}
@@ -0,0 +1,21 @@
+package dedupe
+
+// Policies for uploads of content that was uploaded before, selectable per upload
+// with the dedupe form field
+const (
+	// None always creates and transcodes a new video
+	None = "none"
+	// ReturnExisting returns the existing video instead of creating a new one
+	ReturnExisting = "return_existing"
+	// ReuseRenditions creates a new video that shares the existing transcoded streams
+	ReuseRenditions = "reuse_renditions"
+)
+
+// IsValid reports whether a policy is known
+func IsValid(policy string) bool {
+	switch policy {
+	case None, ReturnExisting, ReuseRenditions:
+		return true
+	}
+	return false
+}
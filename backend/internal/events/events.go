@@ -0,0 +1,62 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Channel is the Redis pub/sub channel carrying the updates of every video
+const Channel = "falcon:video-updates"
+
+// Update types
+const (
+	// TypeState reports a processing state transition
+	TypeState = "state"
+	// TypeProgress reports the percent of the encoding work done
+	TypeProgress = "progress"
+)
+
+// Update is a change in the processing of a video
+type Update struct {
+	Type    string  `json:"type"`
+	VideoID string  `json:"video_id"`
+	State   string  `json:"state,omitempty"`
+	Percent float64 `json:"percent,omitempty"`
+	Error   string  `json:"error,omitempty"`
+	// Reprocess marks updates of a re-encode, which leaves the state of the video unchanged
+	Reprocess bool      `json:"reprocess,omitempty"`
+	Time      time.Time `json:"time"`
+}
+
+// Publisher sends video updates to Redis. Updates are not stored, so only
+// subscribers connected at the time receive them.
+type Publisher struct {
+	redis *redis.Client
+}
+
+// NewPublisher creates a publisher on the given Redis client
+func NewPublisher(redisClient *redis.Client) *Publisher {
+	return &Publisher{redis: redisClient}
+}
+
+// Publish sends an update to every subscriber, stamping it with the current time
+func (p *Publisher) Publish(ctx context.Context, update Update) error {
+	if update.Time.IsZero() {
+		update.Time = time.Now().UTC()
+	}
+
+	payload, err := json.Marshal(update)
+	if err != nil {
+		return err
+	}
+
+	if err := p.redis.Publish(ctx, Channel, payload).Err(); err != nil {
+		return fmt.Errorf("failed to publish %s update: %v", update.Type, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,122 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"sync"
+
+	"github.com/falcon/backend/internal/logging"
+	"github.com/go-redis/redis/v8"
+)
+
+// subscriberBuffer is the number of updates queued for a subscriber before
+// further updates are dropped
+const subscriberBuffer = 64
+
+// Hub shares one Redis subscription between every local subscriber, so the number
+// of connected clients does not change the load on Redis
+type Hub struct {
+	redis *redis.Client
+
+	mu     sync.Mutex
+	subs   map[*subscription]struct{}
+	closed bool
+}
+
+// subscription receives the updates of one video, or of every video when videoID is empty
+type subscription struct {
+	videoID string
+	updates chan Update
+}
+
+// NewHub creates a hub on the given Redis client. Run must be called to receive updates.
+func NewHub(redisClient *redis.Client) *Hub {
+	return &Hub{
+		redis: redisClient,
+		subs:  make(map[*subscription]struct{}),
+	}
+}
+
+// Run forwards the updates published on Channel to the subscribers until the
+// context is canceled, then closes their channels so event streams end. The
+// Redis client reconnects on its own after failures.
+func (h *Hub) Run(ctx context.Context) {
+	pubsub := h.redis.Subscribe(ctx, Channel)
+	defer pubsub.Close()
+	defer h.close()
+
+	messages := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-messages:
+			if !ok {
+				return
+			}
+
+			var update Update
+			if err := json.Unmarshal([]byte(msg.Payload), &update); err != nil {
+				slog.Warn("Ignoring malformed video update", "error", err)
+				continue
+			}
+			h.broadcast(update)
+		}
+	}
+}
+
+// Subscribe returns the updates of a video, or of every video when videoID is
+// empty, and a function ending the subscription. Updates are dropped for
+// subscribers that fall behind rather than blocking the others. The channel is
+// closed when the hub stops.
+func (h *Hub) Subscribe(videoID string) (<-chan Update, func()) {
+	sub := &subscription{
+		videoID: videoID,
+		updates: make(chan Update, subscriberBuffer),
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		close(sub.updates)
+		return sub.updates, func() {}
+	}
+	h.subs[sub] = struct{}{}
+
+	return sub.updates, func() {
+		h.mu.Lock()
+		delete(h.subs, sub)
+		h.mu.Unlock()
+	}
+}
+
+// close ends every subscription and rejects new ones
+func (h *Hub) close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.closed = true
+	for sub := range h.subs {
+		close(sub.updates)
+		delete(h.subs, sub)
+	}
+}
+
+// broadcast queues an update for every matching subscriber
+func (h *Hub) broadcast(update Update) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for sub := range h.subs {
+		if sub.videoID != "" && sub.videoID != update.VideoID {
+			continue
+		}
+		select {
+		case sub.updates <- update:
+		default:
+			slog.Debug("Dropping video update for a slow subscriber", logging.KeyVideoID, update.VideoID, "type", update.Type)
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package ffmpeg
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Video codecs a rendition can be encoded with
+const (
+	CodecH264 = "h264"
+	CodecHEVC = "hevc"
+	CodecVP9  = "vp9"
+	CodecAV1  = "av1"
+)
+
+// AV1 encoders supported for CodecAV1
+const (
+	EncoderSVTAV1 = "libsvtav1"
+	EncoderAOMAV1 = "libaom-av1"
+)
+
+// VideoCodecs lists the supported video codecs
+var VideoCodecs = []string{CodecH264, CodecHEVC, CodecVP9, CodecAV1}
+
+// IsSupportedVideoCodec reports whether a video codec can be encoded
+func IsSupportedVideoCodec(codec string) bool {
+	for _, c := range VideoCodecs {
+		if c == codec {
+			return true
+		}
+	}
+	return false
+}
+
+// NeedsFMP4 reports whether a codec must be packaged in fragmented MP4 segments,
+// since only H.264 is widely supported in MPEG-TS
+func NeedsFMP4(codec string) bool {
+	return codecOrDefault(codec) != CodecH264
+}
+
+// Helper function to treat an empty codec as H.264
+func codecOrDefault(codec string) string {
+	if codec == "" {
+		return CodecH264
+	}
+	return codec
+}
+
+// videoCodecArgs returns the encoder arguments of a rendition. The specifier selects the
+// video stream within the output, such as ":1", and is empty for single-stream outputs.
+// Keyframes are forced at every segment boundary and scene-cut keyframes are disabled,
+// so the segments of all renditions start at the same frame and players can switch
+// between them at any boundary.
+func (f *FFmpeg) videoCodecArgs(res Resolution, opts EncodeOptions, specifier string) []string {
+	gop := opts.gopSize()
+
+	var args []string
+	switch codecOrDefault(res.Codec) {
+	case CodecHEVC:
+		params := "level-idc=" + levelNumber(hevcLevel(res.Height)) + ":scenecut=0"
+		if gop > 0 {
+			params += fmt.Sprintf(":keyint=%d:min-keyint=%d", gop, gop)
+		}
+		args = []string{
+			"-c:v" + specifier, "libx265",
+			"-preset:v" + specifier, f.Preset,
+			// Apple players only accept HEVC tagged as hvc1
+			"-tag:v" + specifier, "hvc1",
+			"-x265-params:v" + specifier, params,
+		}
+	case CodecVP9:
+		args = []string{
+			"-c:v" + specifier, "libvpx-vp9",
+			"-deadline:v" + specifier, "good",
+			"-cpu-used:v" + specifier, "2",
+			"-row-mt:v" + specifier, "1",
+		}
+		args = append(args, fixedGOPArgs(gop, specifier)...)
+	case CodecAV1:
+		if f.AV1Encoder == EncoderAOMAV1 {
+			args = []string{
+				"-c:v" + specifier, EncoderAOMAV1,
+				"-cpu-used:v" + specifier, "6",
+				"-row-mt:v" + specifier, "1",
+			}
+		} else {
+			args = []string{
+				"-c:v" + specifier, EncoderSVTAV1,
+				"-preset:v" + specifier, "8",
+			}
+		}
+		args = append(args, fixedGOPArgs(gop, specifier)...)
+	default:
+		args = []string{
+			"-c:v" + specifier, "libx264",
+			"-preset:v" + specifier, f.Preset,
+			"-profile:v" + specifier, "high",
+			"-level:v" + specifier, levelNumber(h264Level(res.Height)),
+			"-sc_threshold:v" + specifier, "0",
+		}
+		args = append(args, fixedGOPArgs(gop, specifier)...)
+	}
+
+	return append(args,
+		"-force_key_frames:v"+specifier, fmt.Sprintf("expr:gte(t,n_forced*%d)", opts.SegmentDuration),
+	)
+}
+
+// Helper function to set a fixed GOP length, when the frame rate is known
+func fixedGOPArgs(gop int, specifier string) []string {
+	if gop <= 0 {
+		return nil
+	}
+	return []string{
+		"-g:v" + specifier, strconv.Itoa(gop),
+		"-keyint_min:v" + specifier, strconv.Itoa(gop),
+	}
+}
+
+// CodecString returns the RFC 6381 codecs value of a rendition, as used in the
+// CODECS attribute of HLS playlists and the codecs attribute of DASH manifests
+func CodecString(res Resolution) string {
+	switch codecOrDefault(res.Codec) {
+	case CodecHEVC:
+		// Main profile, main tier, level as 30 times the level number
+		return fmt.Sprintf("hvc1.1.6.L%d.B0", hevcLevel(res.Height)*3)
+	case CodecVP9:
+		// Profile 0, 8 bit
+		return fmt.Sprintf("vp09.00.%s.08", vp9Level(res.Height))
+	case CodecAV1:
+		// Main profile, main tier, 8 bit
+		return fmt.Sprintf("av01.0.%sM.08", av1Level(res.Height))
+	default:
+		// High profile with the level in hex
+		return fmt.Sprintf("avc1.6400%02x", h264Level(res.Height))
+	}
+}
+
+// AudioCodecString returns the RFC 6381 codecs value of an audio codec
+func AudioCodecString(codec string) string {
+	switch codec {
+	case "libopus", "opus":
+		return "Opus"
+	case "libmp3lame", "mp3":
+		return "mp4a.40.34"
+	case "ac3":
+		return "ac-3"
+	case "eac3":
+		return "ec-3"
+	default:
+		// AAC-LC
+		return "mp4a.40.2"
+	}
+}
+
+// Helper function to format a level such as 31 as "3.1"
+func levelNumber(level int) string {
+	return fmt.Sprintf("%d.%d", level/10, level%10)
+}
+
+// Helper function to pick the lowest H.264 level for a picture height, times ten
+func h264Level(height int) int {
+	switch {
+	case height <= 480:
+		return 30
+	case height <= 720:
+		return 31
+	case height <= 1080:
+		return 40
+	case height <= 1440:
+		return 50
+	default:
+		return 51
+	}
+}
+
+// Helper function to pick the lowest HEVC level for a picture height, times ten
+func hevcLevel(height int) int {
+	switch {
+	case height <= 540:
+		return 30
+	case height <= 720:
+		return 31
+	case height <= 1080:
+		return 40
+	case height <= 2160:
+		return 50
+	default:
+		return 60
+	}
+}
+
+// Helper function to pick the lowest VP9 level for a picture height
+func vp9Level(height int) string {
+	switch {
+	case height <= 540:
+		return "30"
+	case height <= 720:
+		return "31"
+	case height <= 1080:
+		return "40"
+	case height <= 2160:
+		return "50"
+	default:
+		return "60"
+	}
+}
+
+// Helper function to pick the lowest AV1 sequence level index for a picture height
+func av1Level(height int) string {
+	switch {
+	case height <= 540:
+		return "04"
+	case height <= 720:
+		return "05"
+	case height <= 1080:
+		return "08"
+	case height <= 2160:
+		return "12"
+	default:
+		return "16"
+	}
+}
@@ -0,0 +1,137 @@
+package ffmpeg
+
+import "testing"
+
+func TestCodecString(t *testing.T) {
+	tests := []struct {
+		codec  string
+		height int
+		want   string
+	}{
+		{"", 360, "avc1.64001e"},
+		{CodecH264, 480, "avc1.64001e"},
+		{CodecH264, 720, "avc1.64001f"},
+		{CodecH264, 1080, "avc1.640028"},
+		{CodecH264, 1440, "avc1.640032"},
+		{CodecH264, 2160, "avc1.640033"},
+		{CodecHEVC, 540, "hvc1.1.6.L90.B0"},
+		{CodecHEVC, 720, "hvc1.1.6.L93.B0"},
+		{CodecHEVC, 1080, "hvc1.1.6.L120.B0"},
+		{CodecHEVC, 2160, "hvc1.1.6.L150.B0"},
+		{CodecHEVC, 4320, "hvc1.1.6.L180.B0"},
+		{CodecVP9, 360, "vp09.00.30.08"},
+		{CodecVP9, 720, "vp09.00.31.08"},
+		{CodecVP9, 1080, "vp09.00.40.08"},
+		{CodecVP9, 2160, "vp09.00.50.08"},
+		{CodecVP9, 4320, "vp09.00.60.08"},
+		{CodecAV1, 480, "av01.0.04M.08"},
+		{CodecAV1, 720, "av01.0.05M.08"},
+		{CodecAV1, 1080, "av01.0.08M.08"},
+		{CodecAV1, 2160, "av01.0.12M.08"},
+		{CodecAV1, 4320, "av01.0.16M.08"},
+	}
+
+	for _, tt := range tests {
+		res := Resolution{Width: tt.height * 16 / 9, Height: tt.height, Codec: tt.codec}
+		if got := CodecString(res); got != tt.want {
+			t.Errorf("CodecString(%q, %dp) = %q, want %q", tt.codec, tt.height, got, tt.want)
+		}
+	}
+}
+
+func TestLevelTables(t *testing.T) {
+	tests := []struct {
+		height int
+		h264   int
+		hevc   int
+		vp9    string
+		av1    string
+	}{
+		{240, 30, 30, "30", "04"},
+		{480, 30, 30, "30", "04"},
+		{481, 31, 30, "30", "04"},
+		{540, 31, 30, "30", "04"},
+		{541, 31, 31, "31", "05"},
+		{720, 31, 31, "31", "05"},
+		{721, 40, 40, "40", "08"},
+		{1080, 40, 40, "40", "08"},
+		{1081, 50, 50, "50", "12"},
+		{1440, 50, 50, "50", "12"},
+		{1441, 51, 50, "50", "12"},
+		{2160, 51, 50, "50", "12"},
+		{2161, 51, 60, "60", "16"},
+	}
+
+	for _, tt := range tests {
+		if got := h264Level(tt.height); got != tt.h264 {
+			t.Errorf("h264Level(%d) = %d, want %d", tt.height, got, tt.h264)
+		}
+		if got := hevcLevel(tt.height); got != tt.hevc {
+			t.Errorf("hevcLevel(%d) = %d, want %d", tt.height, got, tt.hevc)
+		}
+		if got := vp9Level(tt.height); got != tt.vp9 {
+			t.Errorf("vp9Level(%d) = %q, want %q", tt.height, got, tt.vp9)
+		}
+		if got := av1Level(tt.height); got != tt.av1 {
+			t.Errorf("av1Level(%d) = %q, want %q", tt.height, got, tt.av1)
+		}
+	}
+}
+
+func TestLevelNumber(t *testing.T) {
+	tests := []struct {
+		level int
+		want  string
+	}{
+		{30, "3.0"},
+		{31, "3.1"},
+		{40, "4.0"},
+		{51, "5.1"},
+	}
+
+	for _, tt := range tests {
+		if got := levelNumber(tt.level); got != tt.want {
+			t.Errorf("levelNumber(%d) = %q, want %q", tt.level, got, tt.want)
+		}
+	}
+}
+
+func TestAudioCodecString(t *testing.T) {
+	tests := []struct {
+		codec string
+		want  string
+	}{
+		{"aac", "mp4a.40.2"},
+		{"", "mp4a.40.2"},
+		{"libopus", "Opus"},
+		{"opus", "Opus"},
+		{"libmp3lame", "mp4a.40.34"},
+		{"ac3", "ac-3"},
+		{"eac3", "ec-3"},
+	}
+
+	for _, tt := range tests {
+		if got := AudioCodecString(tt.codec); got != tt.want {
+			t.Errorf("AudioCodecString(%q) = %q, want %q", tt.codec, got, tt.want)
+		}
+	}
+}
+
+func TestNeedsFMP4(t *testing.T) {
+	tests := []struct {
+		codec string
+		want  bool
+	}{
+		{"", false},
+		{CodecH264, false},
+		{CodecHEVC, true},
+		{CodecVP9, true},
+		{CodecAV1, true},
+	}
+
+	for _, tt := range tests {
+		if got := NeedsFMP4(tt.codec); got != tt.want {
+			t.Errorf("NeedsFMP4(%q) = %v, want %v", tt.codec, got, tt.want)
+		}
+	}
+}
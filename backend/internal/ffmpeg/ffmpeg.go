@@ -2,63 +2,206 @@ package ffmpeg
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
+	"math"
+	"os"
 	"os/exec"
+	"path/filepath"
+	"strconv"
 	"strings"
 
-	"github.com/golang/glog"
+	"github.com/falcon/backend/internal/tracing"
 )
 
+// stderrTailSize is the number of trailing stderr bytes kept on failures
+const stderrTailSize = 4096
+
+// ExecError is returned when an ffmpeg process fails
+type ExecError struct {
+	ExitCode   int
+	StderrTail string
+	Err        error
+}
+
+// Error implements the error interface
+func (e *ExecError) Error() string {
+	return fmt.Sprintf("ffmpeg exited with code %d: %s", e.ExitCode, lastLine(e.StderrTail))
+}
+
+// Unwrap returns the underlying process error
+func (e *ExecError) Unwrap() error {
+	return e.Err
+}
+
+// newExecError builds an ExecError from a failed command and its stderr output
+func newExecError(err error, stderr string) *ExecError {
+	exitCode := -1
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		exitCode = exitErr.ExitCode()
+	}
+
+	if len(stderr) > stderrTailSize {
+		stderr = stderr[len(stderr)-stderrTailSize:]
+	}
+
+	return &ExecError{
+		ExitCode:   exitCode,
+		StderrTail: stderr,
+		Err:        err,
+	}
+}
+
+// Helper function to return the last non-empty line of output
+func lastLine(output string) string {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	return lines[len(lines)-1]
+}
+
 // FFmpeg represents an FFmpeg processor
 type FFmpeg struct {
-	BinaryPath string
+	BinaryPath  string
+	ProbePath   string
 	ThreadCount int
-	Preset string
+	Preset      string
+	AV1Encoder  string // EncoderSVTAV1 or EncoderAOMAV1
 }
 
 // NewFFmpeg creates a new FFmpeg processor
 func NewFFmpeg(binaryPath string, threadCount int, preset string) *FFmpeg {
 	return &FFmpeg{
-		BinaryPath: binaryPath,
+		BinaryPath:  binaryPath,
+		ProbePath:   filepath.Join(filepath.Dir(binaryPath), "ffprobe"),
 		ThreadCount: threadCount,
-		Preset: preset,
+		Preset:      preset,
+		AV1Encoder:  EncoderSVTAV1,
 	}
 }
 
-// TranscodeToHLS transcodes a video file to HLS format with multiple resolutions
-func (f *FFmpeg) TranscodeToHLS(inputFile, outputDir, segmentFilename string, resolutions []Resolution) error {
+// EncodeOptions controls how a video is encoded and packaged
+type EncodeOptions struct {
+	Renditions      []Resolution
+	SegmentDuration int     // Target segment duration in seconds
+	AudioCodec      string  // e.g., "aac"
+	AudioBitrate    string  // e.g., "128k"
+	AudioChannels   int     // 0 keeps the source layout
+	HasAudio        bool    // Whether the input has an audio stream to encode
+	FrameRate       float64 // Source frame rate, 0 when unknown
+}
+
+// gopSize returns the number of frames per segment, or 0 when the frame rate is unknown
+func (o EncodeOptions) gopSize() int {
+	if o.FrameRate <= 0 {
+		return 0
+	}
+	return int(math.Round(o.FrameRate * float64(o.SegmentDuration)))
+}
+
+// audioMap returns the mapping of the input audio stream, or nothing for silent inputs
+func (o EncodeOptions) audioMap() []string {
+	if !o.HasAudio {
+		return nil
+	}
+	return []string{"-map", "0:a:0"}
+}
+
+// audioArgs returns the audio encoding arguments, with stream specifiers for the given
+// output stream, or nothing for silent inputs
+func (o EncodeOptions) audioArgs(specifier string) []string {
+	if !o.HasAudio {
+		return nil
+	}
+	args := []string{
+		"-c:a" + specifier, o.AudioCodec,
+		"-b:a" + specifier, o.AudioBitrate,
+	}
+	if o.AudioChannels > 0 {
+		args = append(args, "-ac"+specifier, strconv.Itoa(o.AudioChannels))
+	}
+	return args
+}
+
+// bandwidth returns the peak bitrate advertised for a rendition and its audio
+func (o EncodeOptions) bandwidth(res Resolution) int {
+	bandwidth := ParseBitrate(res.Bitrate)
+	if o.HasAudio {
+		bandwidth += ParseBitrate(o.AudioBitrate)
+	}
+	return bandwidth
+}
+
+// codecs returns the CODECS attribute of a rendition and its audio
+func (o EncodeOptions) codecs(res Resolution) string {
+	if !o.HasAudio {
+		return CodecString(res)
+	}
+	return CodecString(res) + "," + AudioCodecString(o.AudioCodec)
+}
+
+// TranscodeToHLS transcodes a video file to HLS format with multiple resolutions. H.264
+// renditions use MPEG-TS segments, other codecs fragmented MP4 segments. The master
+// playlist lists every rendition with its CODECS so clients skip codecs they cannot decode.
+func (f *FFmpeg) TranscodeToHLS(ctx context.Context, inputFile, outputDir, segmentFilename string, opts EncodeOptions) error {
 	args := []string{
 		"-i", inputFile,
 		"-threads", fmt.Sprintf("%d", f.ThreadCount),
 	}
 
+	// I-frame playlists need version 4, fragmented MP4 segments version 7
+	playlistVersion := 4
+	for _, res := range opts.Renditions {
+		if NeedsFMP4(res.Codec) {
+			playlistVersion = 7
+		}
+	}
+
 	var variantArgs []string
+	var playlists []string
 	var masterPlaylistContent strings.Builder
 	masterPlaylistContent.WriteString("#EXTM3U\n")
-	masterPlaylistContent.WriteString("#EXT-X-VERSION:3\n")
+	masterPlaylistContent.WriteString(fmt.Sprintf("#EXT-X-VERSION:%d\n", playlistVersion))
 
 	// Add each resolution variant
-	for i, res := range resolutions {
+	for i, res := range opts.Renditions {
 		variantName := fmt.Sprintf("v%d", i)
 		playlistFile := fmt.Sprintf("%s_%s.m3u8", segmentFilename, variantName)
-		segmentFile := fmt.Sprintf("%s_%s_%%03d.ts", segmentFilename, variantName)
-		
-		masterPlaylistContent.WriteString(fmt.Sprintf("#EXT-X-STREAM-INF:BANDWIDTH=%d,RESOLUTION=%dx%d\n", 
-			res.Bitrate, res.Width, res.Height))
+
+		masterPlaylistContent.WriteString(fmt.Sprintf("#EXT-X-STREAM-INF:BANDWIDTH=%d,RESOLUTION=%dx%d,CODECS=\"%s\"\n",
+			opts.bandwidth(res), res.Width, res.Height, opts.codecs(res)))
 		masterPlaylistContent.WriteString(fmt.Sprintf("%s\n", playlistFile))
-		
+		playlists = append(playlists, playlistFile)
+
 		// Add variant arguments
+		variantArgs = append(variantArgs, "-map", "0:v:0")
+		variantArgs = append(variantArgs, opts.audioMap()...)
+		variantArgs = append(variantArgs, f.videoCodecArgs(res, opts, "")...)
 		variantArgs = append(variantArgs,
-			"-map", "0:v:0",
-			"-map", "0:a:0",
-			"-c:v", "libx264",
-			"-preset", f.Preset,
 			"-b:v", res.Bitrate,
 			"-s", fmt.Sprintf("%dx%d", res.Width, res.Height),
-			"-c:a", "aac",
-			"-b:a", "128k",
-			"-hls_time", "10",
+		)
+		variantArgs = append(variantArgs, opts.audioArgs("")...)
+		variantArgs = append(variantArgs,
+			"-hls_time", strconv.Itoa(opts.SegmentDuration),
 			"-hls_list_size", "0",
+			"-hls_flags", "independent_segments",
+		)
+
+		var segmentFile string
+		if NeedsFMP4(res.Codec) {
+			segmentFile = fmt.Sprintf("%s_%s_%%03d.m4s", segmentFilename, variantName)
+			variantArgs = append(variantArgs,
+				"-hls_segment_type", "fmp4",
+				"-hls_fmp4_init_filename", fmt.Sprintf("%s_%s_init.mp4", segmentFilename, variantName),
+			)
+		} else {
+			segmentFile = fmt.Sprintf("%s_%s_%%03d.ts", segmentFilename, variantName)
+		}
+
+		variantArgs = append(variantArgs,
 			"-hls_segment_filename", fmt.Sprintf("%s/%s", outputDir, segmentFile),
 			fmt.Sprintf("%s/%s", outputDir, playlistFile),
 		)
@@ -66,54 +209,379 @@ func (f *FFmpeg) TranscodeToHLS(inputFile, outputDir, segmentFilename string, re
 
 	// Execute the FFmpeg command
 	args = append(args, variantArgs...)
-	
-	cmd := exec.Command(f.BinaryPath, args...)
+	if err := f.run(ctx, "hls", args); err != nil {
+		return err
+	}
+
+	masterPlaylistContent.WriteString(f.writeIFramePlaylists(ctx, outputDir, playlists, opts.Renditions))
+
+	// Create master playlist file
+	masterPath := filepath.Join(outputDir, "master.m3u8")
+	if err := os.WriteFile(masterPath, []byte(masterPlaylistContent.String()), 0644); err != nil {
+		return fmt.Errorf("failed to write master playlist: %v", err)
+	}
+
+	return nil
+}
+
+// TranscodeToDASH transcodes a video file to a DASH presentation with one representation
+// per resolution, written as manifest.mpd in the output directory
+func (f *FFmpeg) TranscodeToDASH(ctx context.Context, inputFile, outputDir string, opts EncodeOptions) error {
+	args := f.dashArgs(inputFile, opts)
+	args = append(args, filepath.Join(outputDir, "manifest.mpd"))
+
+	return f.run(ctx, "dash", args)
+}
+
+// TranscodeToCMAF transcodes a video file to a single set of fragmented MP4 segments
+// referenced by both a DASH manifest (manifest.mpd) and HLS playlists (master.m3u8 and
+// media_N.m3u8 per representation), so both protocols serve the same bytes
+func (f *FFmpeg) TranscodeToCMAF(ctx context.Context, inputFile, outputDir string, opts EncodeOptions) error {
+	args := f.dashArgs(inputFile, opts)
+	args = append(args,
+		"-hls_playlist", "1",
+		"-hls_master_name", "master.m3u8",
+		filepath.Join(outputDir, "manifest.mpd"),
+	)
+
+	if err := f.run(ctx, "cmaf", args); err != nil {
+		return err
+	}
+
+	playlists := make([]string, len(opts.Renditions))
+	for i := range opts.Renditions {
+		playlists[i] = CMAFPlaylistName(i)
+	}
+	iframes := f.writeIFramePlaylists(ctx, outputDir, playlists, opts.Renditions)
+
+	// Replace the generated master playlist with one that carries CODECS for every rendition
+	masterPath := filepath.Join(outputDir, "master.m3u8")
+	if err := os.WriteFile(masterPath, []byte(cmafMasterPlaylist(opts)+iframes), 0644); err != nil {
+		return fmt.Errorf("failed to write master playlist: %v", err)
+	}
+
+	return nil
+}
+
+// TranscodeToMP4 transcodes a video file to a single progressive MP4 rendition. The moov
+// atom is moved to the front so players can start before the whole file is downloaded
+// and seek with range requests.
+func (f *FFmpeg) TranscodeToMP4(ctx context.Context, inputFile, outputFile string, res Resolution, opts EncodeOptions) error {
+	args := []string{
+		"-i", inputFile,
+		"-threads", fmt.Sprintf("%d", f.ThreadCount),
+		"-map", "0:v:0",
+	}
+	args = append(args, opts.audioMap()...)
+	args = append(args, f.videoCodecArgs(res, opts, "")...)
+	args = append(args,
+		"-b:v", res.Bitrate,
+		"-s", fmt.Sprintf("%dx%d", res.Width, res.Height),
+	)
+	args = append(args, opts.audioArgs("")...)
+	args = append(args,
+		"-movflags", "+faststart",
+		"-y", outputFile,
+	)
+
+	return f.run(ctx, "mp4", args)
+}
+
+// CMAFPlaylistName returns the HLS media playlist of the rendition at the given index
+// in a CMAF output
+func CMAFPlaylistName(index int) string {
+	return fmt.Sprintf("media_%d.m3u8", index)
+}
+
+// cmafMasterPlaylist builds the HLS master playlist of a CMAF output. Representation IDs
+// follow the stream order, so video renditions come first and audio last. Silent
+// inputs have no audio group.
+func cmafMasterPlaylist(opts EncodeOptions) string {
+	var playlist strings.Builder
+	playlist.WriteString("#EXTM3U\n")
+	playlist.WriteString("#EXT-X-VERSION:7\n")
+	playlist.WriteString("#EXT-X-INDEPENDENT-SEGMENTS\n")
+
+	audioGroup := ""
+	if opts.HasAudio {
+		playlist.WriteString(fmt.Sprintf("#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID=\"audio\",NAME=\"audio\",DEFAULT=YES,AUTOSELECT=YES,URI=\"%s\"\n",
+			CMAFPlaylistName(len(opts.Renditions))))
+		audioGroup = ",AUDIO=\"audio\""
+	}
+
+	for i, res := range opts.Renditions {
+		playlist.WriteString(fmt.Sprintf("#EXT-X-STREAM-INF:BANDWIDTH=%d,RESOLUTION=%dx%d,CODECS=\"%s\"%s\n",
+			opts.bandwidth(res), res.Width, res.Height, opts.codecs(res), audioGroup))
+		playlist.WriteString(CMAFPlaylistName(i) + "\n")
+	}
+
+	return playlist.String()
+}
+
+// dashArgs returns the arguments of a DASH encode without the output path
+func (f *FFmpeg) dashArgs(inputFile string, opts EncodeOptions) []string {
+	args := []string{
+		"-i", inputFile,
+		"-threads", fmt.Sprintf("%d", f.ThreadCount),
+	}
+
+	// One video stream per resolution followed by a single audio stream
+	for range opts.Renditions {
+		args = append(args, "-map", "0:v:0")
+	}
+	args = append(args, opts.audioMap()...)
+
+	// Renditions sharing a codec form one adaptation set, so players switch
+	// bitrates within a codec they support
+	var codecs []string
+	streamsByCodec := map[string][]string{}
+	for i, res := range opts.Renditions {
+		specifier := fmt.Sprintf(":%d", i)
+		args = append(args, f.videoCodecArgs(res, opts, specifier)...)
+		args = append(args,
+			"-b:v"+specifier, res.Bitrate,
+			"-s:v"+specifier, fmt.Sprintf("%dx%d", res.Width, res.Height),
+		)
+
+		codec := codecOrDefault(res.Codec)
+		if _, ok := streamsByCodec[codec]; !ok {
+			codecs = append(codecs, codec)
+		}
+		streamsByCodec[codec] = append(streamsByCodec[codec], strconv.Itoa(i))
+	}
+	args = append(args, opts.audioArgs(":0")...)
+
+	var adaptationSets []string
+	for i, codec := range codecs {
+		adaptationSets = append(adaptationSets, fmt.Sprintf("id=%d,streams=%s", i, strings.Join(streamsByCodec[codec], ",")))
+	}
+	if opts.HasAudio {
+		adaptationSets = append(adaptationSets, fmt.Sprintf("id=%d,streams=a", len(codecs)))
+	}
+
+	return append(args,
+		"-f", "dash",
+		"-seg_duration", strconv.Itoa(opts.SegmentDuration),
+		"-use_template", "1",
+		"-use_timeline", "1",
+		"-init_seg_name", "init_v$RepresentationID$.m4s",
+		"-media_seg_name", "chunk_v$RepresentationID$_$Number%05d$.m4s",
+		"-adaptation_sets", strings.Join(adaptationSets, " "),
+	)
+}
+
+// GenerateThumbnail writes a JPEG poster frame taken at the given offset in seconds,
+// scaled to the given width
+func (f *FFmpeg) GenerateThumbnail(ctx context.Context, inputFile, outputFile string, offset float64, width int) error {
+	args := []string{
+		"-ss", strconv.FormatFloat(offset, 'f', 3, 64),
+		"-i", inputFile,
+		"-frames:v", "1",
+		"-vf", fmt.Sprintf("scale=%d:-2", width),
+		"-q:v", "3",
+		"-y",
+		outputFile,
+	}
+
+	return f.run(ctx, "thumbnail", args)
+}
+
+// Version returns the first line of ffmpeg -version, failing when the binary
+// cannot be run
+func (f *FFmpeg) Version(ctx context.Context) (string, error) {
+	output, err := exec.CommandContext(ctx, f.BinaryPath, "-version").Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to run %s: %v", f.BinaryPath, err)
+	}
+	version, _, _ := strings.Cut(string(output), "\n")
+	return version, nil
+}
+
+// run executes ffmpeg with the given arguments in a span named after the operation,
+// returning an ExecError on failure. Progress is reported to the ProgressFunc of
+// the context, if any.
+func (f *FFmpeg) run(ctx context.Context, operation string, args []string) (err error) {
+	ctx, span := tracing.Start(ctx, "ffmpeg."+operation)
+	defer tracing.End(span, &err)
+
+	// Progress replaces the regular stdout output, which encodes never use
 	var stdout, stderr bytes.Buffer
+	progress := progressFrom(ctx)
+	if progress != nil {
+		args = append(append([]string{}, progressArgs...), args...)
+	}
+
+	cmd := exec.CommandContext(ctx, f.BinaryPath, args...)
 	cmd.Stdout = &stdout
+	if progress != nil {
+		cmd.Stdout = &progressWriter{fn: progress}
+	}
 	cmd.Stderr = &stderr
-	
-	glog.Infof("Executing FFmpeg command: %s %s", f.BinaryPath, strings.Join(args, " "))
-	
-	err := cmd.Run()
+
+	slog.InfoContext(ctx, "Executing ffmpeg", "operation", operation, "args", strings.Join(args, " "))
+
+	err = cmd.Run()
 	if err != nil {
-		glog.Errorf("FFmpeg error: %v\nStderr: %s", err, stderr.String())
-		return fmt.Errorf("failed to transcode: %v - %s", err, stderr.String())
+		slog.ErrorContext(ctx, "ffmpeg failed", "operation", operation, "error", err, "stderr", stderr.String())
+		return newExecError(err, stderr.String())
 	}
-	
-	glog.Infof("FFmpeg output: %s", stdout.String())
-	
-	// Create master playlist file
-	// In a real implementation, you would write the masterPlaylistContent to a file
-	
+
+	slog.DebugContext(ctx, "ffmpeg completed", "operation", operation, "stdout", stdout.String())
+
 	return nil
 }
 
 // Resolution represents a video resolution and bitrate
 type Resolution struct {
-	Width  int
-	Height int
+	Width   int
+	Height  int
 	Bitrate string // e.g., "2500k"
+	Codec   string // One of VideoCodecs, empty for H.264
+}
+
+// ParseBitrate converts an ffmpeg bitrate such as "2500k" or "5M" into bits per second
+func ParseBitrate(bitrate string) int {
+	multiplier := 1
+	value := strings.TrimSpace(bitrate)
+
+	switch {
+	case strings.HasSuffix(value, "k"), strings.HasSuffix(value, "K"):
+		multiplier = 1000
+		value = value[:len(value)-1]
+	case strings.HasSuffix(value, "M"), strings.HasSuffix(value, "m"):
+		multiplier = 1000000
+		value = value[:len(value)-1]
+	}
+
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0
+	}
+
+	return int(n * float64(multiplier))
 }
 
 // GetMediaInfo returns information about a media file
-func (f *FFmpeg) GetMediaInfo(inputFile string) (map[string]string, error) {
-	cmd := exec.Command(f.BinaryPath, 
-		"-i", inputFile,
-		"-hide_banner",
+func (f *FFmpeg) GetMediaInfo(ctx context.Context, inputFile string) (map[string]string, error) {
+	probe, err := f.Probe(ctx, inputFile)
+	if err != nil {
+		return nil, err
+	}
+
+	info := map[string]string{
+		"format":   probe.Format.FormatName,
+		"duration": probe.Format.Duration,
+	}
+
+	if video := probe.VideoStream(); video != nil {
+		info["video_codec"] = video.CodecName
+		info["width"] = strconv.Itoa(video.Width)
+		info["height"] = strconv.Itoa(video.Height)
+		info["frame_rate"] = strconv.FormatFloat(video.FrameRate(), 'f', 3, 64)
+	}
+	if audio := probe.AudioStream(); audio != nil {
+		info["audio_codec"] = audio.CodecName
+	}
+
+	slog.InfoContext(ctx, "Read media info", "file", inputFile, "info", info)
+
+	return info, nil
+}
+
+// ProbeStream describes a single stream reported by ffprobe
+type ProbeStream struct {
+	Index        int    `json:"index"`
+	CodecType    string `json:"codec_type"`
+	CodecName    string `json:"codec_name"`
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
+	Duration     string `json:"duration"`
+	AvgFrameRate string `json:"avg_frame_rate"` // e.g., "30000/1001"
+}
+
+// FrameRate returns the average frame rate of the stream, or 0 when unknown
+func (s *ProbeStream) FrameRate() float64 {
+	num, den, ok := strings.Cut(s.AvgFrameRate, "/")
+	if !ok {
+		den = "1"
+	}
+
+	n, err := strconv.ParseFloat(num, 64)
+	if err != nil {
+		return 0
+	}
+	d, err := strconv.ParseFloat(den, 64)
+	if err != nil || d == 0 {
+		return 0
+	}
+
+	return n / d
+}
+
+// ProbeFormat describes the container reported by ffprobe
+type ProbeFormat struct {
+	FormatName string `json:"format_name"`
+	Duration   string `json:"duration"`
+	Size       string `json:"size"`
+	BitRate    string `json:"bit_rate"`
+}
+
+// ProbeResult is the parsed output of ffprobe
+type ProbeResult struct {
+	Streams []ProbeStream `json:"streams"`
+	Format  ProbeFormat   `json:"format"`
+}
+
+// VideoStream returns the first video stream, or nil if there is none
+func (p *ProbeResult) VideoStream() *ProbeStream {
+	return p.firstStream("video")
+}
+
+// AudioStream returns the first audio stream, or nil if there is none
+func (p *ProbeResult) AudioStream() *ProbeStream {
+	return p.firstStream("audio")
+}
+
+// DurationSeconds returns the container duration in seconds
+func (p *ProbeResult) DurationSeconds() float64 {
+	duration, _ := strconv.ParseFloat(p.Format.Duration, 64)
+	return duration
+}
+
+func (p *ProbeResult) firstStream(codecType string) *ProbeStream {
+	for i := range p.Streams {
+		if p.Streams[i].CodecType == codecType {
+			return &p.Streams[i]
+		}
+	}
+	return nil
+}
+
+// Probe runs ffprobe on a media file and returns its streams and container format
+func (f *FFmpeg) Probe(ctx context.Context, inputFile string) (_ *ProbeResult, err error) {
+	ctx, span := tracing.Start(ctx, "ffmpeg.probe")
+	defer tracing.End(span, &err)
+
+	cmd := exec.CommandContext(ctx, f.ProbePath,
+		"-v", "error",
+		"-print_format", "json",
+		"-show_format",
+		"-show_streams",
+		inputFile,
 	)
-	
-	var stderr bytes.Buffer
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
-	
-	_ = cmd.Run() // FFmpeg will return an error code, but we still want the output
-	
-	info := make(map[string]string)
-	output := stderr.String()
-	
-	// Parse the output
-	// In a real implementation, you would properly parse the FFmpeg output
-	
-	glog.Infof("Media info for %s: %v", inputFile, info)
-	
-	return info, nil
-} 
\ No newline at end of file
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("failed to probe: %v - %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	var result ProbeResult
+	if err := json.Unmarshal(stdout.Bytes(), &result); err != nil {
+		return nil, fmt.Errorf("failed to parse ffprobe output: %v", err)
+	}
+
+	return &result, nil
+}
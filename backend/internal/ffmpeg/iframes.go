@@ -0,0 +1,311 @@
+package ffmpeg
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/falcon/backend/internal/tracing"
+)
+
+// mediaSegment is a segment listed in an HLS media playlist
+type mediaSegment struct {
+	URI      string
+	Duration float64
+}
+
+// iframeEntry is a single I-frame referenced by byte range
+type iframeEntry struct {
+	URI      string
+	Offset   int64
+	Length   int64
+	Duration float64
+}
+
+// videoPacket is a video packet reported by ffprobe
+type videoPacket struct {
+	PTS      float64
+	Pos      int64
+	Size     int64
+	Keyframe bool
+}
+
+// IFramePlaylistName returns the I-frame-only playlist written next to a media playlist
+func IFramePlaylistName(mediaPlaylist string) string {
+	return strings.TrimSuffix(mediaPlaylist, ".m3u8") + "_iframes.m3u8"
+}
+
+// WriteIFramePlaylist writes an I-frame-only playlist for an HLS media playlist, with
+// byte ranges into the existing segments so no extra media is stored. It returns the
+// peak bandwidth of the I-frame playlist for its EXT-X-I-FRAME-STREAM-INF tag.
+func (f *FFmpeg) WriteIFramePlaylist(ctx context.Context, mediaPlaylist string) (int, error) {
+	dir := filepath.Dir(mediaPlaylist)
+
+	initURI, segments, err := parseMediaPlaylist(mediaPlaylist)
+	if err != nil {
+		return 0, err
+	}
+
+	var entries []iframeEntry
+	for _, segment := range segments {
+		var segmentEntries []iframeEntry
+		if initURI != "" {
+			segmentEntries, err = f.fmp4IFrames(ctx, filepath.Join(dir, initURI), dir, segment)
+		} else {
+			segmentEntries, err = f.tsIFrames(ctx, dir, segment)
+		}
+		if err != nil {
+			return 0, err
+		}
+		entries = append(entries, segmentEntries...)
+	}
+
+	if len(entries) == 0 {
+		return 0, fmt.Errorf("no keyframes found for %s", mediaPlaylist)
+	}
+
+	// Byte ranges need version 4, fragmented MP4 needs version 7
+	version := 4
+	if initURI != "" {
+		version = 7
+	}
+
+	var bandwidth int
+	var maxDuration float64
+	for _, entry := range entries {
+		if entry.Duration > 0 {
+			bandwidth = max(bandwidth, int(float64(entry.Length*8)/entry.Duration))
+		}
+		maxDuration = math.Max(maxDuration, entry.Duration)
+	}
+
+	var playlist strings.Builder
+	playlist.WriteString("#EXTM3U\n")
+	playlist.WriteString(fmt.Sprintf("#EXT-X-VERSION:%d\n", version))
+	playlist.WriteString(fmt.Sprintf("#EXT-X-TARGETDURATION:%d\n", int(math.Ceil(maxDuration))))
+	playlist.WriteString("#EXT-X-MEDIA-SEQUENCE:0\n")
+	playlist.WriteString("#EXT-X-PLAYLIST-TYPE:VOD\n")
+	playlist.WriteString("#EXT-X-I-FRAMES-ONLY\n")
+	if initURI != "" {
+		playlist.WriteString(fmt.Sprintf("#EXT-X-MAP:URI=\"%s\"\n", initURI))
+	}
+	for _, entry := range entries {
+		playlist.WriteString(fmt.Sprintf("#EXTINF:%.6f,\n", entry.Duration))
+		playlist.WriteString(fmt.Sprintf("#EXT-X-BYTERANGE:%d@%d\n", entry.Length, entry.Offset))
+		playlist.WriteString(entry.URI + "\n")
+	}
+	playlist.WriteString("#EXT-X-ENDLIST\n")
+
+	if err := os.WriteFile(IFramePlaylistName(mediaPlaylist), []byte(playlist.String()), 0644); err != nil {
+		return 0, fmt.Errorf("failed to write I-frame playlist: %v", err)
+	}
+
+	return bandwidth, nil
+}
+
+// writeIFramePlaylists writes an I-frame playlist next to each rendition's media playlist
+// and returns the master playlist tags referencing them. Trick play is optional, so a
+// rendition whose I-frames cannot be indexed is logged and left out.
+func (f *FFmpeg) writeIFramePlaylists(ctx context.Context, outputDir string, playlists []string, renditions []Resolution) string {
+	var tags strings.Builder
+	for i, playlist := range playlists {
+		bandwidth, err := f.WriteIFramePlaylist(ctx, filepath.Join(outputDir, playlist))
+		if err != nil {
+			slog.Warn("Skipping I-frame playlist", "playlist", playlist, "error", err)
+			continue
+		}
+		tags.WriteString(IFrameStreamInf(renditions[i], bandwidth, IFramePlaylistName(playlist)))
+	}
+	return tags.String()
+}
+
+// IFrameStreamInf returns the master playlist tag referencing an I-frame playlist
+func IFrameStreamInf(res Resolution, bandwidth int, uri string) string {
+	return fmt.Sprintf("#EXT-X-I-FRAME-STREAM-INF:BANDWIDTH=%d,RESOLUTION=%dx%d,CODECS=\"%s\",URI=\"%s\"\n",
+		bandwidth, res.Width, res.Height, CodecString(res), uri)
+}
+
+// tsIFrames lists every keyframe of an MPEG-TS segment. Each range runs from the TS
+// packet holding the start of the keyframe to the start of the next video packet.
+func (f *FFmpeg) tsIFrames(ctx context.Context, dir string, segment mediaSegment) ([]iframeEntry, error) {
+	path := filepath.Join(dir, segment.URI)
+	packets, err := f.probeVideoPackets(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat segment: %v", err)
+	}
+
+	var entries []iframeEntry
+	var segmentStart float64
+	for i, packet := range packets {
+		if !packet.Keyframe {
+			continue
+		}
+
+		end := info.Size()
+		if i+1 < len(packets) {
+			end = packets[i+1].Pos
+		}
+
+		if len(entries) == 0 {
+			segmentStart = packet.PTS
+		} else {
+			previous := &entries[len(entries)-1]
+			previous.Duration = packet.PTS - (segmentStart + sumDurations(entries[:len(entries)-1]))
+		}
+
+		entries = append(entries, iframeEntry{
+			URI:    segment.URI,
+			Offset: packet.Pos,
+			Length: end - packet.Pos,
+		})
+	}
+
+	// The last keyframe lasts until the end of the segment
+	if len(entries) > 0 {
+		last := &entries[len(entries)-1]
+		last.Duration = segment.Duration - sumDurations(entries[:len(entries)-1])
+	}
+
+	return entries, nil
+}
+
+// fmp4IFrames returns the keyframe at the start of a fragmented MP4 segment. The range
+// starts at the segment's moof box so players can parse the fragment.
+func (f *FFmpeg) fmp4IFrames(ctx context.Context, initPath, dir string, segment mediaSegment) ([]iframeEntry, error) {
+	initInfo, err := os.Stat(initPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat init segment: %v", err)
+	}
+
+	// Fragments cannot be probed without their init segment
+	packets, err := f.probeVideoPackets(ctx, "concat:"+initPath+"|"+filepath.Join(dir, segment.URI))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, packet := range packets {
+		if packet.Keyframe {
+			return []iframeEntry{{
+				URI:      segment.URI,
+				Offset:   0,
+				Length:   packet.Pos - initInfo.Size() + packet.Size,
+				Duration: segment.Duration,
+			}}, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// probeVideoPackets lists the packets of the first video stream in file order
+func (f *FFmpeg) probeVideoPackets(ctx context.Context, input string) (_ []videoPacket, err error) {
+	ctx, span := tracing.Start(ctx, "ffmpeg.probe")
+	defer tracing.End(span, &err)
+
+	cmd := exec.CommandContext(ctx, f.ProbePath,
+		"-v", "error",
+		"-select_streams", "v:0",
+		"-show_entries", "packet=pts_time,pos,size,flags",
+		"-print_format", "json",
+		input,
+	)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("failed to probe packets: %v - %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	var result struct {
+		Packets []struct {
+			PTSTime string `json:"pts_time"`
+			Pos     string `json:"pos"`
+			Size    string `json:"size"`
+			Flags   string `json:"flags"`
+		} `json:"packets"`
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &result); err != nil {
+		return nil, fmt.Errorf("failed to parse ffprobe output: %v", err)
+	}
+
+	packets := make([]videoPacket, 0, len(result.Packets))
+	for _, p := range result.Packets {
+		pts, _ := strconv.ParseFloat(p.PTSTime, 64)
+		pos, err := strconv.ParseInt(p.Pos, 10, 64)
+		if err != nil {
+			// Packets without a known position cannot be addressed by byte range
+			continue
+		}
+		size, _ := strconv.ParseInt(p.Size, 10, 64)
+
+		packets = append(packets, videoPacket{
+			PTS:      pts,
+			Pos:      pos,
+			Size:     size,
+			Keyframe: strings.HasPrefix(p.Flags, "K"),
+		})
+	}
+
+	return packets, nil
+}
+
+// parseMediaPlaylist returns the init segment and the segments of an HLS media playlist
+func parseMediaPlaylist(path string) (string, []mediaSegment, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to open playlist: %v", err)
+	}
+	defer file.Close()
+
+	var initURI string
+	var segments []mediaSegment
+	var duration float64
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case strings.HasPrefix(line, "#EXT-X-MAP:"):
+			if _, rest, ok := strings.Cut(line, `URI="`); ok {
+				initURI, _, _ = strings.Cut(rest, `"`)
+			}
+		case strings.HasPrefix(line, "#EXTINF:"):
+			value, _, _ := strings.Cut(strings.TrimPrefix(line, "#EXTINF:"), ",")
+			duration, _ = strconv.ParseFloat(value, 64)
+		case line == "" || strings.HasPrefix(line, "#"):
+			continue
+		default:
+			segments = append(segments, mediaSegment{URI: line, Duration: duration})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", nil, fmt.Errorf("failed to read playlist: %v", err)
+	}
+
+	return initURI, segments, nil
+}
+
+// Helper function to sum the durations of I-frame entries
+func sumDurations(entries []iframeEntry) float64 {
+	var total float64
+	for _, entry := range entries {
+		total += entry.Duration
+	}
+	return total
+}
@@ -0,0 +1,89 @@
+package ffmpeg
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// maxSegmentDurationPattern extracts the maxSegmentDuration attribute of a DASH manifest
+var maxSegmentDurationPattern = regexp.MustCompile(`maxSegmentDuration="([^"]+)"`)
+
+// isoDurationPattern matches ISO 8601 durations as written by ffmpeg, such as PT1M4.0S
+var isoDurationPattern = regexp.MustCompile(`^PT(?:([0-9.]+)H)?(?:([0-9.]+)M)?(?:([0-9.]+)S)?$`)
+
+// PlaylistTargetDuration returns the EXT-X-TARGETDURATION of an HLS media playlist,
+// the upper bound of its segment durations in whole seconds
+func PlaylistTargetDuration(path string) (int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open playlist: %v", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		value, ok := strings.CutPrefix(strings.TrimSpace(scanner.Text()), "#EXT-X-TARGETDURATION:")
+		if !ok {
+			continue
+		}
+
+		duration, err := strconv.Atoi(value)
+		if err != nil {
+			return 0, fmt.Errorf("invalid target duration %q in %s", value, path)
+		}
+		return duration, nil
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, fmt.Errorf("failed to read playlist: %v", err)
+	}
+
+	return 0, fmt.Errorf("no target duration in %s", path)
+}
+
+// ManifestTargetDuration returns the maxSegmentDuration of a DASH manifest rounded up
+// to whole seconds
+func ManifestTargetDuration(path string) (int, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read manifest: %v", err)
+	}
+
+	match := maxSegmentDurationPattern.FindSubmatch(content)
+	if match == nil {
+		return 0, fmt.Errorf("no maxSegmentDuration in %s", path)
+	}
+
+	seconds, err := parseISODuration(string(match[1]))
+	if err != nil {
+		return 0, err
+	}
+
+	return int(math.Ceil(seconds)), nil
+}
+
+// Helper function to convert an ISO 8601 duration into seconds
+func parseISODuration(value string) (float64, error) {
+	match := isoDurationPattern.FindStringSubmatch(value)
+	if match == nil {
+		return 0, fmt.Errorf("invalid duration %q", value)
+	}
+
+	var seconds float64
+	for i, unit := range []float64{3600, 60, 1} {
+		if match[i+1] == "" {
+			continue
+		}
+		n, err := strconv.ParseFloat(match[i+1], 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", value)
+		}
+		seconds += n * unit
+	}
+
+	return seconds, nil
+}
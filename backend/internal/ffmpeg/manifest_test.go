@@ -0,0 +1,155 @@
+package ffmpeg
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeFile writes content to a file in a test's temporary directory
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestParseISODuration(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    float64
+		wantErr bool
+	}{
+		{value: "PT4S", want: 4},
+		{value: "PT4.004S", want: 4.004},
+		{value: "PT1M4.0S", want: 64},
+		{value: "PT2H", want: 7200},
+		{value: "PT1H2M3S", want: 3723},
+		{value: "PT0.5M", want: 30},
+		{value: "PT", want: 0},
+		{value: "P1DT4S", wantErr: true},
+		{value: "4S", wantErr: true},
+		{value: "PT4", wantErr: true},
+		{value: "PT1.2.3S", wantErr: true},
+		{value: "PT-4S", wantErr: true},
+		{value: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := parseISODuration(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseISODuration(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseISODuration(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlaylistTargetDuration(t *testing.T) {
+	tests := []struct {
+		name     string
+		playlist string
+		want     int
+		wantErr  bool
+	}{
+		{
+			name:     "media playlist",
+			playlist: "#EXTM3U\n#EXT-X-VERSION:3\n#EXT-X-TARGETDURATION:4\n#EXT-X-MEDIA-SEQUENCE:0\n#EXTINF:4.004000,\nseg0.ts\n#EXT-X-ENDLIST\n",
+			want:     4,
+		},
+		{
+			name:     "crlf line endings",
+			playlist: "#EXTM3U\r\n#EXT-X-TARGETDURATION:6\r\n#EXTINF:6.0,\r\nseg0.ts\r\n",
+			want:     6,
+		},
+		{
+			name:     "first tag wins",
+			playlist: "#EXTM3U\n#EXT-X-TARGETDURATION:2\n#EXT-X-TARGETDURATION:10\n",
+			want:     2,
+		},
+		{
+			name:     "fractional value",
+			playlist: "#EXTM3U\n#EXT-X-TARGETDURATION:4.5\n",
+			wantErr:  true,
+		},
+		{
+			name:     "missing tag",
+			playlist: "#EXTM3U\n#EXTINF:4.0,\nseg0.ts\n",
+			wantErr:  true,
+		},
+		{
+			name:     "empty",
+			playlist: "",
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := PlaylistTargetDuration(writeFile(t, "playlist.m3u8", tt.playlist))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("PlaylistTargetDuration() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("PlaylistTargetDuration() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := PlaylistTargetDuration(filepath.Join(t.TempDir(), "missing.m3u8")); err == nil {
+		t.Error("PlaylistTargetDuration() of a missing file succeeded")
+	}
+}
+
+func TestManifestTargetDuration(t *testing.T) {
+	tests := []struct {
+		name     string
+		manifest string
+		want     int
+		wantErr  bool
+	}{
+		{
+			name:     "whole seconds",
+			manifest: `<MPD type="static" maxSegmentDuration="PT4.0S" minBufferTime="PT4.0S">`,
+			want:     4,
+		},
+		{
+			name:     "rounded up",
+			manifest: `<MPD type="static" maxSegmentDuration="PT4.004S">`,
+			want:     5,
+		},
+		{
+			name:     "minutes",
+			manifest: `<MPD maxSegmentDuration="PT1M0.5S">`,
+			want:     61,
+		},
+		{
+			name:     "missing attribute",
+			manifest: `<MPD type="static" minBufferTime="PT4.0S">`,
+			wantErr:  true,
+		},
+		{
+			name:     "invalid duration",
+			manifest: `<MPD maxSegmentDuration="4 seconds">`,
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ManifestTargetDuration(writeFile(t, "manifest.mpd", tt.manifest))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ManifestTargetDuration() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ManifestTargetDuration() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
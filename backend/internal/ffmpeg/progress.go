@@ -0,0 +1,70 @@
+package ffmpeg
+
+import (
+	"bytes"
+	"context"
+	"strconv"
+	"time"
+)
+
+// ProgressFunc receives how much of the input an ffmpeg run has encoded so far
+type ProgressFunc func(encoded time.Duration)
+
+// progressKey is the context key of the ProgressFunc of ffmpeg runs
+type progressKey struct{}
+
+// WithProgress returns a context whose ffmpeg runs report their progress to fn.
+// A nil fn leaves the context unchanged.
+func WithProgress(ctx context.Context, fn ProgressFunc) context.Context {
+	if fn == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, progressKey{}, fn)
+}
+
+// progressFrom returns the ProgressFunc of a context, or nil
+func progressFrom(ctx context.Context) ProgressFunc {
+	fn, _ := ctx.Value(progressKey{}).(ProgressFunc)
+	return fn
+}
+
+// progressArgs makes ffmpeg write key=value progress blocks to stdout instead of
+// the interactive stats line on stderr
+var progressArgs = []string{"-progress", "pipe:1", "-nostats"}
+
+// progressWriter parses the progress blocks written by ffmpeg, reporting the
+// encoded position of each block
+type progressWriter struct {
+	fn      ProgressFunc
+	partial []byte
+}
+
+// Write implements io.Writer, buffering incomplete lines between calls
+func (w *progressWriter) Write(p []byte) (int, error) {
+	w.partial = append(w.partial, p...)
+
+	for {
+		i := bytes.IndexByte(w.partial, '\n')
+		if i < 0 {
+			break
+		}
+		w.parseLine(bytes.TrimSpace(w.partial[:i]))
+		w.partial = w.partial[i+1:]
+	}
+
+	return len(p), nil
+}
+
+// parseLine reports the out_time_us of a block, which is "N/A" until the first frame
+func (w *progressWriter) parseLine(line []byte) {
+	key, value, ok := bytes.Cut(line, []byte("="))
+	if !ok || string(key) != "out_time_us" {
+		return
+	}
+
+	us, err := strconv.ParseInt(string(value), 10, 64)
+	if err != nil || us < 0 {
+		return
+	}
+	w.fn(time.Duration(us) * time.Microsecond)
+}
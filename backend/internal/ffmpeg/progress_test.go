@@ -0,0 +1,113 @@
+package ffmpeg
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+)
+
+// progressBlock is a progress block as written by ffmpeg with -progress
+const progressBlock = "frame=120\nfps=60.00\nstream_0_0_q=23.0\nbitrate=1024.0kbits/s\ntotal_size=524288\n" +
+	"out_time_us=4000000\nout_time_ms=4000000\nout_time=00:00:04.000000\ndup_frames=0\ndrop_frames=0\nspeed=2.0x\nprogress=continue\n"
+
+func TestProgressWriter(t *testing.T) {
+	tests := []struct {
+		name   string
+		writes []string
+		want   []time.Duration
+	}{
+		{
+			name:   "single block",
+			writes: []string{progressBlock},
+			want:   []time.Duration{4 * time.Second},
+		},
+		{
+			name:   "block split across writes",
+			writes: []string{progressBlock[:80], progressBlock[80:90], progressBlock[90:]},
+			want:   []time.Duration{4 * time.Second},
+		},
+		{
+			name:   "one byte at a time",
+			writes: splitBytes("out_time_us=1500000\n"),
+			want:   []time.Duration{1500 * time.Millisecond},
+		},
+		{
+			name:   "several blocks",
+			writes: []string{"out_time_us=1000000\nprogress=continue\nout_time_us=2000000\nprogress=end\n"},
+			want:   []time.Duration{time.Second, 2 * time.Second},
+		},
+		{
+			name:   "not available before the first frame",
+			writes: []string{"out_time_us=N/A\nprogress=continue\nout_time_us=40000\n"},
+			want:   []time.Duration{40 * time.Millisecond},
+		},
+		{
+			name:   "crlf line endings",
+			writes: []string{"out_time_us=3000000\r\n"},
+			want:   []time.Duration{3 * time.Second},
+		},
+		{
+			name:   "negative position",
+			writes: []string{"out_time_us=-23220\n"},
+		},
+		{
+			name:   "incomplete line",
+			writes: []string{"out_time_us=5000000"},
+		},
+		{
+			name:   "out_time_ms alone",
+			writes: []string{"out_time_ms=5000000\n"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []time.Duration
+			w := &progressWriter{fn: func(encoded time.Duration) {
+				got = append(got, encoded)
+			}}
+
+			for _, p := range tt.writes {
+				n, err := w.Write([]byte(p))
+				if err != nil || n != len(p) {
+					t.Fatalf("Write(%q) = %d, %v", p, n, err)
+				}
+			}
+
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("reported %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithProgress(t *testing.T) {
+	ctx := context.Background()
+
+	if progressFrom(ctx) != nil {
+		t.Error("progressFrom() of a plain context is not nil")
+	}
+	if WithProgress(ctx, nil) != ctx {
+		t.Error("WithProgress() with a nil func changed the context")
+	}
+
+	var reported time.Duration
+	fn := progressFrom(WithProgress(ctx, func(encoded time.Duration) { reported = encoded }))
+	if fn == nil {
+		t.Fatal("progressFrom() did not return the func")
+	}
+	fn(time.Second)
+	if reported != time.Second {
+		t.Errorf("func reported %s, want %s", reported, time.Second)
+	}
+}
+
+// splitBytes splits a string into single byte strings
+func splitBytes(s string) []string {
+	parts := make([]string, len(s))
+	for i := range s {
+		parts[i] = s[i : i+1]
+	}
+	return parts
+}
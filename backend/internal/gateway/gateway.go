@@ -0,0 +1,188 @@
+package gateway
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"time"
+
+	"github.com/falcon/backend/internal/tracing"
+	"github.com/gorilla/mux"
+)
+
+// Config holds the upstreams and per-route timeouts of the gateway
+type Config struct {
+	UploaderURL string `mapstructure:"uploader_url"`
+	StreamerURL string `mapstructure:"streamer_url"`
+
+	// UploadTimeout bounds a whole upload, including streaming the request body
+	UploadTimeout time.Duration `mapstructure:"upload_timeout"`
+	// APITimeout bounds JSON API requests
+	APITimeout time.Duration `mapstructure:"api_timeout"`
+	// StreamTimeout bounds playlist, manifest and segment requests
+	StreamTimeout time.Duration `mapstructure:"stream_timeout"`
+	// EventsTimeout bounds Server-Sent Event streams, which clients reopen when cut
+	EventsTimeout time.Duration `mapstructure:"events_timeout"`
+}
+
+// DefaultConfig returns the gateway configuration used for unset values
+func DefaultConfig() Config {
+	return Config{
+		UploaderURL:   "http://localhost:8001",
+		StreamerURL:   "http://localhost:8002",
+		UploadTimeout: 15 * time.Minute,
+		APITimeout:    30 * time.Second,
+		StreamTimeout: time.Minute,
+		EventsTimeout: time.Hour,
+	}
+}
+
+// Gateway routes client requests to the uploader and streamer services, giving
+// clients a single origin
+type Gateway struct {
+	config   Config
+	uploader *url.URL
+	streamer *url.URL
+}
+
+// New creates a gateway, filling unset values from DefaultConfig
+func New(config Config) (*Gateway, error) {
+	defaults := DefaultConfig()
+	if config.UploaderURL == "" {
+		config.UploaderURL = defaults.UploaderURL
+	}
+	if config.StreamerURL == "" {
+		config.StreamerURL = defaults.StreamerURL
+	}
+	if config.UploadTimeout <= 0 {
+		config.UploadTimeout = defaults.UploadTimeout
+	}
+	if config.APITimeout <= 0 {
+		config.APITimeout = defaults.APITimeout
+	}
+	if config.StreamTimeout <= 0 {
+		config.StreamTimeout = defaults.StreamTimeout
+	}
+	if config.EventsTimeout <= 0 {
+		config.EventsTimeout = defaults.EventsTimeout
+	}
+
+	uploaderURL, err := parseUpstream(config.UploaderURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid uploader upstream: %v", err)
+	}
+	streamerURL, err := parseUpstream(config.StreamerURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid streamer upstream: %v", err)
+	}
+
+	return &Gateway{
+		config:   config,
+		uploader: uploaderURL,
+		streamer: streamerURL,
+	}, nil
+}
+
+// Register adds the proxied routes to a router. Routes are matched by method, so the
+// uploader and streamer can share the /videos/{videoId} paths.
+func (g *Gateway) Register(router *mux.Router) {
+	uploads := g.proxy("uploader", g.uploader, g.config.UploadTimeout)
+	uploaderAPI := g.proxy("uploader", g.uploader, g.config.APITimeout)
+	streamerAPI := g.proxy("streamer", g.streamer, g.config.APITimeout)
+	streams := g.proxy("streamer", g.streamer, g.config.StreamTimeout)
+	eventStreams := g.proxy("streamer", g.streamer, g.config.EventsTimeout)
+
+	// Uploader
+	router.Handle("/upload", uploads).Methods("POST")
+	router.Handle("/videos/{videoId}/schedule", uploaderAPI).Methods("POST")
+	router.Handle("/videos/{videoId}/transcode", uploaderAPI).Methods("POST")
+	router.Handle("/profiles", uploaderAPI).Methods("GET", "POST")
+	router.Handle("/profiles/{name}", uploaderAPI).Methods("GET", "PUT", "DELETE")
+	router.Handle("/webhooks", uploaderAPI).Methods("GET", "POST")
+	router.Handle("/webhooks/{webhookId}", uploaderAPI).Methods("GET", "PUT", "DELETE")
+	router.Handle("/webhooks/{webhookId}/deliveries", uploaderAPI).Methods("GET")
+	router.Handle("/webhooks/{webhookId}/deliveries/{deliveryId}/redeliver", uploaderAPI).Methods("POST")
+
+	// Streamer
+	router.Handle("/videos", streamerAPI).Methods("GET")
+	router.Handle("/videos/{videoId}", streamerAPI).Methods("GET")
+	router.Handle("/videos/{videoId}/history", streamerAPI).Methods("GET")
+	router.Handle("/videos/{videoId}/jobs", streamerAPI).Methods("GET")
+	router.Handle("/videos/{videoId}/events", eventStreams).Methods("GET")
+	router.Handle("/events", eventStreams).Methods("GET")
+	router.Handle("/videos/{videoId}/hls/{filename}", streams).Methods("GET")
+	router.Handle("/videos/{videoId}/dash/{filename}", streams).Methods("GET")
+	router.Handle("/videos/{videoId}/mp4/{filename}", streams).Methods("GET", "HEAD")
+}
+
+// proxy returns a handler forwarding requests to an upstream within a timeout.
+// Request bodies are streamed to the upstream and responses are flushed as they
+// arrive, so uploads are never buffered in the gateway.
+func (g *Gateway) proxy(name string, upstream *url.URL, timeout time.Duration) http.Handler {
+	proxy := &httputil.ReverseProxy{
+		Rewrite: func(r *httputil.ProxyRequest) {
+			r.SetURL(upstream)
+			r.SetXForwarded()
+		},
+		// Forward the trace context so upstream spans join the gateway's trace
+		Transport: tracing.Transport(&http.Transport{
+			Proxy: http.ProxyFromEnvironment,
+			DialContext: (&net.Dialer{
+				Timeout:   5 * time.Second,
+				KeepAlive: 30 * time.Second,
+			}).DialContext,
+			MaxIdleConnsPerHost:   32,
+			IdleConnTimeout:       90 * time.Second,
+			ResponseHeaderTimeout: timeout,
+		}),
+		FlushInterval: -1,
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			slog.ErrorContext(r.Context(), "Gateway request failed", "method", r.Method, "path", r.URL.Path, "upstream", name, "error", err)
+
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadGateway)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error":   "Upstream unavailable",
+				"details": fmt.Sprintf("%s service: %v", name, err),
+			})
+		},
+	}
+
+	return Deadline(timeout)(proxy)
+}
+
+// Deadline returns middleware bounding the time to read a request and write its
+// response, so routes can use longer timeouts than the server defaults
+func Deadline(timeout time.Duration) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			deadline := time.Now().Add(timeout)
+
+			rc := http.NewResponseController(w)
+			if err := rc.SetReadDeadline(deadline); err != nil {
+				slog.WarnContext(r.Context(), "Failed to set read deadline", "error", err)
+			}
+			if err := rc.SetWriteDeadline(deadline); err != nil {
+				slog.WarnContext(r.Context(), "Failed to set write deadline", "error", err)
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Helper function to validate an upstream base URL
+func parseUpstream(raw string) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return nil, fmt.Errorf("%q must be an absolute http(s) URL", raw)
+	}
+	return u, nil
+}
@@ -0,0 +1,56 @@
+package health
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/falcon/backend/internal/database"
+	"github.com/falcon/backend/internal/ffmpeg"
+	"github.com/falcon/backend/internal/storage"
+	"github.com/go-redis/redis/v8"
+	"go.temporal.io/sdk/client"
+)
+
+// Names of the dependency checks
+const (
+	CheckPostgres = "postgres"
+	CheckRedis    = "redis"
+	CheckStorage  = "storage"
+	CheckTemporal = "temporal"
+	CheckFFmpeg   = "ffmpeg"
+)
+
+// Postgres checks that the database answers a query
+func Postgres(db *database.Database) Check {
+	return db.Ping
+}
+
+// Redis checks that Redis answers a PING
+func Redis(rdb *redis.Client) Check {
+	return func(ctx context.Context) error {
+		return rdb.Ping(ctx).Err()
+	}
+}
+
+// Storage checks that the video bucket is reachable
+func Storage(s *storage.StorageService) Check {
+	return s.Ping
+}
+
+// Temporal checks that the Temporal frontend reports itself healthy
+func Temporal(c client.Client) Check {
+	return func(ctx context.Context) error {
+		if _, err := c.CheckHealth(ctx, &client.CheckHealthRequest{}); err != nil {
+			return fmt.Errorf("temporal health check failed: %v", err)
+		}
+		return nil
+	}
+}
+
+// FFmpeg checks that the ffmpeg binary can be run
+func FFmpeg(ff *ffmpeg.FFmpeg) Check {
+	return func(ctx context.Context) error {
+		_, err := ff.Version(ctx)
+		return err
+	}
+}
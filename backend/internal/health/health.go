@@ -0,0 +1,253 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Statuses reported for checks, services and whole reports
+const (
+	StatusOK          = "ok"
+	StatusUnavailable = "unavailable"
+)
+
+// DefaultTimeout bounds each check when the checker is created without a timeout
+const DefaultTimeout = 3 * time.Second
+
+// Check probes a dependency, returning an error when it is unusable
+type Check func(ctx context.Context) error
+
+// Result is the outcome of a single check
+type Result struct {
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration"`
+}
+
+// Report is the readiness of a service, its dependency checks and the services
+// it aggregates
+type Report struct {
+	Service  string             `json:"service"`
+	Status   string             `json:"status"`
+	Error    string             `json:"error,omitempty"`
+	Checks   map[string]Result  `json:"checks,omitempty"`
+	Services map[string]*Report `json:"services,omitempty"`
+	Time     time.Time          `json:"time"`
+}
+
+// OK reports whether the service and everything it depends on is ready
+func (r *Report) OK() bool {
+	return r.Status == StatusOK
+}
+
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+type remoteService struct {
+	name string
+	url  string
+}
+
+// Checker runs the readiness checks of a service. Checks run concurrently, each
+// bounded by the checker's timeout, so one hung dependency cannot stall the report.
+type Checker struct {
+	service  string
+	timeout  time.Duration
+	client   *http.Client
+	checks   []namedCheck
+	services []remoteService
+}
+
+// NewChecker creates a checker for a service with a per-check timeout
+func NewChecker(service string, timeout time.Duration) *Checker {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	return &Checker{
+		service: service,
+		timeout: timeout,
+		client:  &http.Client{},
+	}
+}
+
+// Add registers a dependency check
+func (c *Checker) Add(name string, check Check) *Checker {
+	c.checks = append(c.checks, namedCheck{name: name, check: check})
+	return c
+}
+
+// AddService registers another service whose readiness report at
+// baseURL/health/ready is included in this one
+func (c *Checker) AddService(name, baseURL string) *Checker {
+	c.services = append(c.services, remoteService{
+		name: name,
+		url:  strings.TrimSuffix(baseURL, "/") + "/health/ready",
+	})
+	return c
+}
+
+// Run executes every check and fetches every service report, the report is
+// unavailable if any of them failed
+func (c *Checker) Run(ctx context.Context) *Report {
+	report := &Report{
+		Service: c.service,
+		Status:  StatusOK,
+		Time:    time.Now().UTC(),
+	}
+	if len(c.checks) > 0 {
+		report.Checks = make(map[string]Result, len(c.checks))
+	}
+	if len(c.services) > 0 {
+		report.Services = make(map[string]*Report, len(c.services))
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for _, nc := range c.checks {
+		wg.Add(1)
+		go func(nc namedCheck) {
+			defer wg.Done()
+			result := c.runCheck(ctx, nc.check)
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[nc.name] = result
+			if result.Status != StatusOK {
+				report.Status = StatusUnavailable
+			}
+		}(nc)
+	}
+
+	for _, svc := range c.services {
+		wg.Add(1)
+		go func(svc remoteService) {
+			defer wg.Done()
+			sub := c.fetchService(ctx, svc)
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Services[svc.name] = sub
+			if !sub.OK() {
+				report.Status = StatusUnavailable
+			}
+		}(svc)
+	}
+
+	wg.Wait()
+	return report
+}
+
+// Helper function to run a check within the checker's timeout
+func (c *Checker) runCheck(ctx context.Context, check Check) Result {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	start := time.Now()
+	errc := make(chan error, 1)
+	go func() {
+		errc <- check(ctx)
+	}()
+
+	// Checks that ignore their context are abandoned at the deadline
+	var err error
+	select {
+	case err = <-errc:
+	case <-ctx.Done():
+		err = fmt.Errorf("timed out after %s", c.timeout)
+	}
+
+	result := Result{
+		Status:   StatusOK,
+		Duration: time.Since(start).Round(time.Millisecond).String(),
+	}
+	if err != nil {
+		result.Status = StatusUnavailable
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// Helper function to fetch the readiness report of another service
+func (c *Checker) fetchService(ctx context.Context, svc remoteService) *Report {
+	// Sub-services bound their own checks, allow for one check plus the round trip
+	ctx, cancel := context.WithTimeout(ctx, c.timeout+time.Second)
+	defer cancel()
+
+	unavailable := func(err error) *Report {
+		return &Report{
+			Service: svc.name,
+			Status:  StatusUnavailable,
+			Error:   err.Error(),
+			Time:    time.Now().UTC(),
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, svc.url, nil)
+	if err != nil {
+		return unavailable(err)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return unavailable(err)
+	}
+	defer resp.Body.Close()
+
+	var sub Report
+	if err := json.NewDecoder(resp.Body).Decode(&sub); err != nil {
+		return unavailable(fmt.Errorf("invalid readiness response (HTTP %d): %v", resp.StatusCode, err))
+	}
+	if sub.Status == StatusOK && resp.StatusCode != http.StatusOK {
+		sub.Status = StatusUnavailable
+	}
+	return &sub
+}
+
+// Register adds the health routes to a router: /health/live reports that the
+// process is serving requests, /health/ready runs the checks and answers 503 when
+// any fails, and /health is kept as an alias of /health/ready
+func Register(router *mux.Router, checker *Checker) {
+	ready := ReadyHandler(checker)
+	router.HandleFunc("/health/live", LiveHandler(checker.service)).Methods("GET")
+	router.HandleFunc("/health/ready", ready).Methods("GET")
+	router.HandleFunc("/health", ready).Methods("GET")
+}
+
+// LiveHandler answers liveness probes without touching any dependency, so a
+// dependency outage does not get the process restarted
+func LiveHandler(service string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]string{
+			"service": service,
+			"status":  StatusOK,
+		})
+	}
+}
+
+// ReadyHandler answers readiness probes with the checker's report
+func ReadyHandler(checker *Checker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		report := checker.Run(r.Context())
+
+		status := http.StatusOK
+		if !report.OK() {
+			status = http.StatusServiceUnavailable
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(report)
+	}
+}
@@ -0,0 +1,47 @@
+package id
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+
+	"github.com/google/uuid"
+)
+
+// SlugLength is the number of characters in a public slug
+const SlugLength = 10
+
+// slugAlphabet contains the characters used in public slugs
+const slugAlphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// NewVideoID returns a time-ordered UUIDv7 for a new video
+func NewVideoID() string {
+	// NewV7 only fails if the system random source is unavailable
+	return uuid.Must(uuid.NewV7()).String()
+}
+
+// NewSlug returns a short random base62 slug for public video URLs
+func NewSlug() string {
+	max := big.NewInt(int64(len(slugAlphabet)))
+	slug := make([]byte, SlugLength)
+
+	for i := range slug {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			panic(fmt.Sprintf("failed to read random source: %v", err))
+		}
+		slug[i] = slugAlphabet[n.Int64()]
+	}
+
+	return string(slug)
+}
+
+// NewRequestID returns a random UUID identifying a request across services
+func NewRequestID() string {
+	return uuid.NewString()
+}
+
+// NewEventID returns a random UUID identifying a webhook event
+func NewEventID() string {
+	return uuid.NewString()
+}
@@ -0,0 +1,71 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+
+	"go.opentelemetry.io/otel/trace"
+	"go.temporal.io/sdk/activity"
+)
+
+type contextKey int
+
+const (
+	requestIDKey contextKey = iota
+	videoIDKey
+)
+
+// WithRequestID returns a context whose log lines carry the request ID
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey, requestID)
+}
+
+// RequestID returns the request ID of the context, or an empty string
+func RequestID(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey).(string)
+	return requestID
+}
+
+// WithVideoID returns a context whose log lines carry the video ID
+func WithVideoID(ctx context.Context, videoID string) context.Context {
+	return context.WithValue(ctx, videoIDKey, videoID)
+}
+
+// contextHandler adds the correlation IDs of the context to every record: the
+// request and video IDs, the trace ID, and the workflow, activity and attempt of
+// a running activity
+type contextHandler struct {
+	slog.Handler
+}
+
+// Handle implements slog.Handler
+func (h *contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if requestID, ok := ctx.Value(requestIDKey).(string); ok {
+		r.AddAttrs(slog.String(KeyRequestID, requestID))
+	}
+	if videoID, ok := ctx.Value(videoIDKey).(string); ok {
+		r.AddAttrs(slog.String(KeyVideoID, videoID))
+	}
+	if activity.IsActivity(ctx) {
+		info := activity.GetInfo(ctx)
+		r.AddAttrs(
+			slog.String(KeyWorkflowID, info.WorkflowExecution.ID),
+			slog.String(KeyActivity, info.ActivityType.Name),
+			slog.Int(KeyAttempt, int(info.Attempt)),
+		)
+	}
+	if span := trace.SpanContextFromContext(ctx); span.IsValid() {
+		r.AddAttrs(slog.String(KeyTraceID, span.TraceID().String()))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+// WithAttrs implements slog.Handler
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+// WithGroup implements slog.Handler
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithGroup(name)}
+}
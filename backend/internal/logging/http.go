@@ -0,0 +1,35 @@
+package logging
+
+import (
+	"net/http"
+
+	"github.com/falcon/backend/internal/id"
+	"github.com/gorilla/mux"
+)
+
+// RequestIDHeader carries the request ID between clients, the gateway and the services
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength bounds request IDs accepted from clients
+const maxRequestIDLength = 128
+
+// Middleware tags the log lines of every request with its request ID and video ID.
+// The ID sent by the client or the gateway is kept, otherwise a new one is created
+// and set on the request so proxied requests carry it to the upstream.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(RequestIDHeader)
+		if requestID == "" || len(requestID) > maxRequestIDLength {
+			requestID = id.NewRequestID()
+			r.Header.Set(RequestIDHeader, requestID)
+		}
+		w.Header().Set(RequestIDHeader, requestID)
+
+		ctx := WithRequestID(r.Context(), requestID)
+		if videoID := mux.Vars(r)["videoId"]; videoID != "" {
+			ctx = WithVideoID(ctx, videoID)
+		}
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
@@ -0,0 +1,94 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+)
+
+// Keys of the correlation attributes added to log lines
+const (
+	KeyService    = "service"
+	KeyRequestID  = "request_id"
+	KeyVideoID    = "video_id"
+	KeyWorkflowID = "workflow_id"
+	KeyRunID      = "run_id"
+	KeyActivity   = "activity"
+	KeyAttempt    = "attempt"
+	KeyTraceID    = "trace_id"
+	KeyError      = "error"
+)
+
+// level is shared by every logger so it can be changed once the config is loaded
+var level = new(slog.LevelVar)
+
+// output receives the JSON log lines of every logger
+var output io.Writer = os.Stderr
+
+// Init makes a JSON logger for the service the default of log/slog
+func Init(service string) {
+	slog.SetDefault(New(service))
+}
+
+// New returns a JSON logger tagging every line with the service and the correlation
+// IDs found in the context of the call
+func New(service string) *slog.Logger {
+	handler := slog.NewJSONHandler(output, &slog.HandlerOptions{
+		Level:       level,
+		ReplaceAttr: replaceAttr,
+	})
+	return slog.New(&contextHandler{Handler: handler}).With(KeyService, service)
+}
+
+// ParseLevel parses a level name: debug, info, warn or error
+func ParseLevel(name string) (slog.Level, error) {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(name)); err != nil {
+		return 0, fmt.Errorf("unknown log level %q", name)
+	}
+	return l, nil
+}
+
+// SetLevel sets the minimum level of every logger
+func SetLevel(name string) error {
+	l, err := ParseLevel(name)
+	if err != nil {
+		return err
+	}
+	level.Set(l)
+	return nil
+}
+
+// Fatal logs an error and exits the process
+func Fatal(msg string, args ...any) {
+	slog.Default().Log(context.Background(), slog.LevelError, msg, args...)
+	os.Exit(1)
+}
+
+// temporalKeys renames the attributes added by the Temporal SDK's loggers so
+// workflow and activity lines use the same keys as the rest of Falcon
+var temporalKeys = map[string]string{
+	"Namespace":    "namespace",
+	"TaskQueue":    "task_queue",
+	"WorkerID":     "worker_id",
+	"WorkflowType": "workflow_type",
+	"WorkflowID":   KeyWorkflowID,
+	"RunID":        KeyRunID,
+	"ActivityID":   "activity_id",
+	"ActivityType": KeyActivity,
+	"Attempt":      KeyAttempt,
+	"Error":        KeyError,
+}
+
+// replaceAttr normalizes the keys of top-level attributes
+func replaceAttr(groups []string, a slog.Attr) slog.Attr {
+	if len(groups) > 0 {
+		return a
+	}
+	if key, ok := temporalKeys[a.Key]; ok {
+		a.Key = key
+	}
+	return a
+}
@@ -0,0 +1,29 @@
+package logging
+
+import (
+	"log/slog"
+
+	"go.temporal.io/sdk/log"
+	"go.temporal.io/sdk/workflow"
+)
+
+// Temporal returns a logger for the Temporal client and its workers. It also backs
+// workflow.GetLogger, which skips lines while a workflow replays its history.
+func Temporal() log.Logger {
+	return log.NewStructuredLogger(slog.Default())
+}
+
+// WithWorkflowVideoID returns a workflow context whose logger carries the video ID
+func WithWorkflowVideoID(ctx workflow.Context, videoID string) workflow.Context {
+	return workflow.WithValue(ctx, videoIDKey, videoID)
+}
+
+// WorkflowLogger returns the replay-safe logger of a workflow, tagged with the video
+// ID of the context. Workflow code must log through it rather than log/slog.
+func WorkflowLogger(ctx workflow.Context) log.Logger {
+	logger := workflow.GetLogger(ctx)
+	if videoID, ok := ctx.Value(videoIDKey).(string); ok {
+		return log.With(logger, KeyVideoID, videoID)
+	}
+	return logger
+}
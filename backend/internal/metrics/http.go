@@ -0,0 +1,112 @@
+package metrics
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Middleware records request counts and latency for a service, labelled with the
+// matched route template so path parameters do not create new series
+func Middleware(service string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+			next.ServeHTTP(rec, r)
+
+			route := "unmatched"
+			if current := mux.CurrentRoute(r); current != nil {
+				if tmpl, err := current.GetPathTemplate(); err == nil {
+					route = tmpl
+				}
+			}
+
+			HTTPRequests.WithLabelValues(service, route, r.Method, strconv.Itoa(rec.status)).Inc()
+			HTTPDuration.WithLabelValues(service, route, r.Method).Observe(time.Since(start).Seconds())
+		})
+	}
+}
+
+// statusRecorder captures the status code written by a handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+// WriteHeader records the status code before writing it
+func (r *statusRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Write marks the header as written with the default status
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	return r.ResponseWriter.Write(b)
+}
+
+// Flush forwards flushes so streamed and proxied responses are not buffered
+func (r *statusRecorder) Flush() {
+	r.wroteHeader = true
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// Upload results by status class
+const (
+	UploadAccepted = "accepted"
+	UploadRejected = "rejected"
+	UploadFailed   = "failed"
+)
+
+// Uploads records the bytes received and the duration of upload requests, with the
+// result derived from the response status: 2xx accepted, 4xx rejected, else failed
+func Uploads(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		body := &countingReader{ReadCloser: r.Body}
+		r.Body = body
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		next.ServeHTTP(rec, r)
+
+		result := UploadFailed
+		switch {
+		case rec.status < 300:
+			result = UploadAccepted
+		case rec.status < 500:
+			result = UploadRejected
+		}
+
+		UploadBytes.Add(float64(body.n))
+		UploadDuration.WithLabelValues(result).Observe(time.Since(start).Seconds())
+	})
+}
+
+// countingReader counts the bytes read from a request body
+type countingReader struct {
+	io.ReadCloser
+	n int64
+}
+
+// Read counts the bytes read through it
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,133 @@
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Namespace prefixes every Falcon metric
+const Namespace = "falcon"
+
+// Result label values
+const (
+	ResultOK    = "ok"
+	ResultError = "error"
+)
+
+// Cache label values
+const (
+	CacheHit  = "hit"
+	CacheMiss = "miss"
+)
+
+var (
+	// HTTPRequests counts requests by service, route template, method and status code
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by service, route, method and status code.",
+	}, []string{"service", "route", "method", "code"})
+
+	// HTTPDuration observes request latency by service, route template and method
+	HTTPDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by service, route and method.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"service", "route", "method"})
+
+	// UploadBytes counts the request body bytes received by upload requests
+	UploadBytes = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "upload_bytes_total",
+		Help:      "Bytes received in video upload requests.",
+	})
+
+	// UploadDuration observes how long uploads take from request to response, by result
+	UploadDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Name:      "upload_duration_seconds",
+		Help:      "Time to receive, validate and store an upload, by result (accepted, rejected or failed).",
+		Buckets:   []float64{0.5, 1, 2.5, 5, 10, 30, 60, 120, 300, 600, 900},
+	}, []string{"result"})
+
+	// TranscodeDuration observes ffmpeg encoding time by profile, format and rendition.
+	// Adaptive formats encode the whole ladder in one run and use rendition "ladder".
+	TranscodeDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Name:      "transcode_duration_seconds",
+		Help:      "ffmpeg encoding time by profile, format and rendition.",
+		Buckets:   []float64{1, 5, 15, 30, 60, 120, 300, 600, 1200, 1800, 3600, 7200},
+	}, []string{"profile", "format", "rendition"})
+
+	// FFmpegFailures counts failed ffmpeg runs by operation
+	FFmpegFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "ffmpeg_failures_total",
+		Help:      "Failed ffmpeg runs by operation.",
+	}, []string{"operation"})
+
+	// TaskQueueBacklog reports the approximate backlog of Temporal task queues
+	TaskQueueBacklog = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Name:      "temporal_task_queue_backlog",
+		Help:      "Approximate number of tasks waiting in a Temporal task queue, by queue and task type.",
+	}, []string{"task_queue", "type"})
+
+	// TaskQueuePollers reports the pollers seen recently on Temporal task queues
+	TaskQueuePollers = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Name:      "temporal_task_queue_pollers",
+		Help:      "Workers recently polling a Temporal task queue, by queue and task type.",
+	}, []string{"task_queue", "type"})
+
+	// CacheRequests counts cache lookups by cache and hit or miss
+	CacheRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "cache_requests_total",
+		Help:      "Cache lookups by cache and result (hit or miss).",
+	}, []string{"cache", "result"})
+
+	// StorageDuration observes object storage calls by operation and result
+	StorageDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Name:      "storage_operation_duration_seconds",
+		Help:      "Object storage call latency by operation and result.",
+		Buckets:   []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60, 300},
+	}, []string{"operation", "result"})
+
+	// WebhookDeliveries counts webhook delivery attempts by event type and result
+	WebhookDeliveries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "webhook_deliveries_total",
+		Help:      "Webhook delivery attempts by event type and result.",
+	}, []string{"event", "result"})
+)
+
+// Handler serves the registered metrics in the Prometheus text format
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// ObserveStorage records the latency of a storage operation started at start. It
+// takes the address of the operation's error so it can be deferred.
+func ObserveStorage(operation string, start time.Time, err *error) {
+	StorageDuration.WithLabelValues(operation, result(*err)).Observe(time.Since(start).Seconds())
+}
+
+// ObserveWebhookDelivery records the result of a webhook delivery attempt
+func ObserveWebhookDelivery(event string, err error) {
+	WebhookDeliveries.WithLabelValues(event, result(err)).Inc()
+}
+
+// Helper function to map an error to a result label
+func result(err error) string {
+	if err != nil {
+		return ResultError
+	}
+	return ResultOK
+}
@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"log/slog"
+	"net/url"
 	"os"
 	"path/filepath"
 	"time"
@@ -14,17 +16,19 @@ import (
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
-	"github.com/golang/glog"
+	"github.com/falcon/backend/internal/metrics"
+	"github.com/falcon/backend/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // Config holds storage configuration
 type Config struct {
-	Endpoint  string
-	Region    string
-	Bucket    string
-	AccessKey string
-	SecretKey string
-	UseSSL    bool
+	Endpoint  string `mapstructure:"endpoint"`
+	Region    string `mapstructure:"region"`
+	Bucket    string `mapstructure:"bucket"`
+	AccessKey string `mapstructure:"access_key"`
+	SecretKey string `mapstructure:"secret_key"`
+	UseSSL    bool   `mapstructure:"use_ssl"`
 }
 
 // StorageService provides methods to interact with S3-compatible storage
@@ -58,7 +62,7 @@ func NewStorageService(config Config) (*StorageService, error) {
 	})
 
 	if err != nil {
-		glog.Infof("Bucket %s does not exist, creating it...", config.Bucket)
+		slog.Info("Bucket does not exist, creating it", "bucket", config.Bucket)
 		_, err = s3Client.CreateBucket(&s3.CreateBucketInput{
 			Bucket: aws.String(config.Bucket),
 		})
@@ -73,8 +77,40 @@ func NewStorageService(config Config) (*StorageService, error) {
 	}, nil
 }
 
+// observe starts a span for a storage operation on an object key and returns the
+// context to run it with and a function that ends the span and records its latency.
+// The function takes the address of the operation's error so it can be deferred.
+func (s *StorageService) observe(ctx context.Context, operation, key string) (context.Context, func(*error)) {
+	start := time.Now()
+	ctx, span := tracing.Start(ctx, "storage."+operation,
+		attribute.String("storage.bucket", s.bucket),
+		attribute.String("storage.key", key),
+	)
+	return ctx, func(err *error) {
+		metrics.ObserveStorage(operation, start, err)
+		tracing.End(span, err)
+	}
+}
+
+// Ping checks that the bucket is reachable with the configured credentials
+func (s *StorageService) Ping(ctx context.Context) (err error) {
+	ctx, done := s.observe(ctx, "head_bucket", "")
+	defer done(&err)
+
+	_, err = s.s3Client.HeadBucketWithContext(ctx, &s3.HeadBucketInput{
+		Bucket: aws.String(s.bucket),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to reach bucket %s: %v", s.bucket, err)
+	}
+	return nil
+}
+
 // UploadFile uploads a file to S3 storage
-func (s *StorageService) UploadFile(ctx context.Context, localFilePath, objectKey string) (string, error) {
+func (s *StorageService) UploadFile(ctx context.Context, localFilePath, objectKey string) (_ string, err error) {
+	ctx, done := s.observe(ctx, "put_object", objectKey)
+	defer done(&err)
+
 	// Read file content
 	fileContent, err := ioutil.ReadFile(localFilePath)
 	if err != nil {
@@ -98,7 +134,10 @@ func (s *StorageService) UploadFile(ctx context.Context, localFilePath, objectKe
 }
 
 // DownloadFile downloads a file from S3 storage
-func (s *StorageService) DownloadFile(ctx context.Context, objectKey, localFilePath string) error {
+func (s *StorageService) DownloadFile(ctx context.Context, objectKey, localFilePath string) (err error) {
+	ctx, done := s.observe(ctx, "get_object", objectKey)
+	defer done(&err)
+
 	// Get object from S3
 	resp, err := s.s3Client.GetObjectWithContext(ctx, &s3.GetObjectInput{
 		Bucket: aws.String(s.bucket),
@@ -148,6 +187,178 @@ func (s *StorageService) GetSignedURL(ctx context.Context, objectKey string, exp
 	return url, nil
 }
 
+// GetSignedDownloadURL generates a pre-signed URL that makes browsers save the object
+// under the given file name instead of playing it inline
+func (s *StorageService) GetSignedDownloadURL(ctx context.Context, objectKey, filename string, expiration time.Duration) (string, error) {
+	req, _ := s.s3Client.GetObjectRequest(&s3.GetObjectInput{
+		Bucket:                     aws.String(s.bucket),
+		Key:                        aws.String(objectKey),
+		ResponseContentDisposition: aws.String(fmt.Sprintf("attachment; filename=%q", filename)),
+	})
+
+	// Generate signed URL
+	url, err := req.Presign(expiration)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign request: %v", err)
+	}
+
+	return url, nil
+}
+
+// ObjectInfo describes an object stored in S3
+type ObjectInfo struct {
+	Key          string
+	Size         int64
+	ETag         string
+	LastModified time.Time
+}
+
+// ListObjects lists all objects stored under the given prefix
+func (s *StorageService) ListObjects(ctx context.Context, prefix string) (_ []ObjectInfo, err error) {
+	ctx, done := s.observe(ctx, "list_objects", prefix)
+	defer done(&err)
+
+	var objects []ObjectInfo
+
+	err = s.s3Client.ListObjectsV2PagesWithContext(ctx, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(prefix),
+	}, func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+		for _, obj := range page.Contents {
+			objects = append(objects, ObjectInfo{
+				Key:          aws.StringValue(obj.Key),
+				Size:         aws.Int64Value(obj.Size),
+				ETag:         aws.StringValue(obj.ETag),
+				LastModified: aws.TimeValue(obj.LastModified),
+			})
+		}
+		return true
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to list objects: %v", err)
+	}
+
+	return objects, nil
+}
+
+// UploadBytes uploads an in-memory payload to S3 storage
+func (s *StorageService) UploadBytes(ctx context.Context, data []byte, objectKey string) (_ string, err error) {
+	ctx, done := s.observe(ctx, "put_object", objectKey)
+	defer done(&err)
+
+	_, err = s.s3Client.PutObjectWithContext(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(s.bucket),
+		Key:         aws.String(objectKey),
+		Body:        bytes.NewReader(data),
+		ContentType: aws.String(getContentType(objectKey)),
+	})
+
+	if err != nil {
+		return "", fmt.Errorf("failed to upload object to S3: %v", err)
+	}
+
+	return fmt.Sprintf("s3://%s/%s", s.bucket, objectKey), nil
+}
+
+// ReadObject reads the full content of an object into memory
+func (s *StorageService) ReadObject(ctx context.Context, objectKey string) (_ []byte, err error) {
+	ctx, done := s.observe(ctx, "get_object", objectKey)
+	defer done(&err)
+
+	resp, err := s.s3Client.GetObjectWithContext(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(objectKey),
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get object from S3: %v", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read object: %v", err)
+	}
+
+	return data, nil
+}
+
+// CopyObject copies an object to a new key within the bucket
+func (s *StorageService) CopyObject(ctx context.Context, srcKey, dstKey string) (err error) {
+	ctx, done := s.observe(ctx, "copy_object", dstKey)
+	defer done(&err)
+
+	_, err = s.s3Client.CopyObjectWithContext(ctx, &s3.CopyObjectInput{
+		Bucket:     aws.String(s.bucket),
+		CopySource: aws.String(url.PathEscape(s.bucket + "/" + srcKey)),
+		Key:        aws.String(dstKey),
+	})
+
+	if err != nil {
+		return fmt.Errorf("failed to copy object: %v", err)
+	}
+
+	return nil
+}
+
+// DeleteObject removes an object from the bucket
+func (s *StorageService) DeleteObject(ctx context.Context, objectKey string) (err error) {
+	ctx, done := s.observe(ctx, "delete_object", objectKey)
+	defer done(&err)
+
+	_, err = s.s3Client.DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(objectKey),
+	})
+
+	if err != nil {
+		return fmt.Errorf("failed to delete object: %v", err)
+	}
+
+	return nil
+}
+
+// MoveObject moves an object to a new key within the bucket
+func (s *StorageService) MoveObject(ctx context.Context, srcKey, dstKey string) error {
+	if err := s.CopyObject(ctx, srcKey, dstKey); err != nil {
+		return err
+	}
+
+	return s.DeleteObject(ctx, srcKey)
+}
+
+// UploadDirectory uploads every file below a local directory under the given key prefix,
+// keeping the relative paths. It returns the total number of bytes uploaded.
+func (s *StorageService) UploadDirectory(ctx context.Context, localDir, prefix string) (int64, error) {
+	var total int64
+	err := filepath.Walk(localDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(localDir, path)
+		if err != nil {
+			return err
+		}
+
+		if _, err := s.UploadFile(ctx, path, prefix+"/"+filepath.ToSlash(rel)); err != nil {
+			return err
+		}
+		total += info.Size()
+		return nil
+	})
+
+	if err != nil {
+		return 0, fmt.Errorf("failed to upload directory %s: %v", localDir, err)
+	}
+
+	return total, nil
+}
+
 // Helper function to determine content type
 func getContentType(filePath string) string {
 	ext := filepath.Ext(filePath)
@@ -160,7 +371,13 @@ func getContentType(filePath string) string {
 		return "application/x-mpegURL"
 	case ".mpd":
 		return "application/dash+xml"
+	case ".m4s":
+		return "video/iso.segment"
+	case ".jpg":
+		return "image/jpeg"
+	case ".json":
+		return "application/json"
 	default:
 		return "application/octet-stream"
 	}
-} 
\ No newline at end of file
+}
@@ -0,0 +1,117 @@
+package streamer
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/falcon/backend/internal/events"
+	"github.com/gorilla/mux"
+)
+
+// keepAliveInterval is how often an idle event stream sends a comment, so proxies
+// and load balancers do not close it
+const keepAliveInterval = 15 * time.Second
+
+// retryInterval tells EventSource clients how long to wait before reconnecting
+const retryInterval = 3 * time.Second
+
+// StreamVideoEvents streams the state transitions and transcode progress of a
+// video as Server-Sent Events, starting with its current state
+func (h *StreamerHandler) StreamVideoEvents(w http.ResponseWriter, r *http.Request) {
+	video, err := h.DB.GetVideoByIDOrSlug(r.Context(), mux.Vars(r)["videoId"])
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error retrieving video: %v", err), http.StatusNotFound)
+		return
+	}
+
+	// Read the state again after subscribing so no transition falls in between
+	updates, unsubscribe := h.Events.Subscribe(video.ID)
+	defer unsubscribe()
+
+	video, err = h.DB.GetVideo(r.Context(), video.ID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error retrieving video: %v", err), http.StatusNotFound)
+		return
+	}
+
+	streamEvents(w, r, updates, &events.Update{
+		Type:    events.TypeState,
+		VideoID: video.ID,
+		State:   video.ProcessingState,
+		Time:    video.UpdatedAt.UTC(),
+	})
+}
+
+// StreamEvents streams the state transitions and transcode progress of every
+// video as Server-Sent Events
+func (h *StreamerHandler) StreamEvents(w http.ResponseWriter, r *http.Request) {
+	updates, unsubscribe := h.Events.Subscribe("")
+	defer unsubscribe()
+
+	streamEvents(w, r, updates, nil)
+}
+
+// streamEvents writes updates as they arrive until the client disconnects. Each
+// update is an event named after its type with the update as JSON data.
+func streamEvents(w http.ResponseWriter, r *http.Request, updates <-chan events.Update, initial *events.Update) {
+	// Event streams outlive the server's write timeout
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		slog.WarnContext(r.Context(), "Failed to clear write deadline, the event stream will be cut at the write timeout", "error", err)
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	fmt.Fprintf(w, "retry: %d\n\n", retryInterval.Milliseconds())
+	if initial != nil {
+		if err := writeEvent(w, *initial); err != nil {
+			return
+		}
+	}
+	if err := rc.Flush(); err != nil {
+		slog.WarnContext(r.Context(), "Event stream cannot be flushed", "error", err)
+		return
+	}
+
+	keepAlive := time.NewTicker(keepAliveInterval)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case update, ok := <-updates:
+			if !ok {
+				return
+			}
+			if err := writeEvent(w, update); err != nil {
+				return
+			}
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		}
+
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// writeEvent writes an update as a Server-Sent Event
+func writeEvent(w http.ResponseWriter, update events.Update) error {
+	data, err := json.Marshal(update)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", update.Type, data)
+	return err
+}
@@ -0,0 +1,409 @@
+package streamer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/falcon/backend/internal/database"
+	"github.com/falcon/backend/internal/events"
+	"github.com/falcon/backend/internal/metrics"
+	"github.com/falcon/backend/internal/storage"
+	"github.com/go-redis/redis/v8"
+	"github.com/gorilla/mux"
+)
+
+// VideoStreamInfo represents information about a video stream
+type VideoStreamInfo struct {
+	VideoID    string                  `json:"videoId"`
+	Slug       string                  `json:"slug,omitempty"`
+	Title      string                  `json:"title"`
+	Duration   float64                 `json:"duration"`
+	Status     string                  `json:"status"`
+	Formats    []string                `json:"formats"`
+	HLSMaster  string                  `json:"hlsMaster,omitempty"`
+	DASHMaster string                  `json:"dashMaster,omitempty"`
+	Downloads  []MP4Download           `json:"downloads,omitempty"`
+	Streams    []*database.VideoStream `json:"streams"`
+	CreatedAt  time.Time               `json:"createdAt"`
+}
+
+// MP4Download describes a progressive MP4 rendition of a video
+type MP4Download struct {
+	Resolution string `json:"resolution"`
+	Codec      string `json:"codec"`
+	Size       int64  `json:"size"`
+	URL        string `json:"url"`
+}
+
+// StreamerHandler handles video streaming requests
+type StreamerHandler struct {
+	DB      *database.Database
+	Storage *storage.StorageService
+	Redis   *redis.Client
+	Events  *events.Hub
+}
+
+// GetVideoInfo returns metadata about a video
+func (h *StreamerHandler) GetVideoInfo(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	// Set response headers
+	w.Header().Set("Content-Type", "application/json")
+
+	// Get video from database by ID or public slug
+	video, err := h.DB.GetVideoByIDOrSlug(r.Context(), vars["videoId"])
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error retrieving video: %v", err), http.StatusNotFound)
+		return
+	}
+	videoID := video.ID
+
+	// Get video streams
+	streams, err := h.DB.GetVideoStreams(r.Context(), videoID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error retrieving video streams: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	// Build response
+	var formats []string
+	var hlsMaster, dashMaster string
+
+	// Determine available formats, CMAF streams are served as both HLS and DASH
+	for _, stream := range streams {
+		streamFormats := []string{stream.Format}
+		if stream.Format == database.FormatCMAF {
+			streamFormats = []string{database.FormatHLS, database.FormatDASH}
+		}
+		for _, format := range streamFormats {
+			if !contains(formats, format) {
+				formats = append(formats, format)
+			}
+		}
+	}
+
+	// Generate signed URLs for the master playlist and manifest
+	if contains(formats, database.FormatHLS) {
+		url, err := h.Storage.GetSignedURL(r.Context(), video.FormatPrefix(database.FormatHLS)+"/master.m3u8", 24*time.Hour)
+		if err == nil {
+			hlsMaster = url
+		}
+	}
+	if contains(formats, database.FormatDASH) {
+		url, err := h.Storage.GetSignedURL(r.Context(), video.FormatPrefix(database.FormatDASH)+"/manifest.mpd", 24*time.Hour)
+		if err == nil {
+			dashMaster = url
+		}
+	}
+
+	// List progressive downloads through the streamer, which handles the file name
+	var downloads []MP4Download
+	for _, stream := range streams {
+		if stream.Format != database.FormatMP4 {
+			continue
+		}
+		downloads = append(downloads, MP4Download{
+			Resolution: stream.Resolution,
+			Codec:      stream.Codec,
+			Size:       stream.Size,
+			URL:        fmt.Sprintf("/videos/%s/mp4/%s", videoID, path.Base(stream.Path)),
+		})
+	}
+
+	// Create response
+	response := VideoStreamInfo{
+		VideoID:    videoID,
+		Slug:       video.Slug,
+		Title:      video.Title,
+		Duration:   video.Duration,
+		Status:     video.ProcessingState,
+		Formats:    formats,
+		HLSMaster:  hlsMaster,
+		DASHMaster: dashMaster,
+		Downloads:  downloads,
+		Streams:    streams,
+		CreatedAt:  video.CreatedAt,
+	}
+
+	// Return response
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetVideoHistory returns the processing state transitions of a video
+func (h *StreamerHandler) GetVideoHistory(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	// Set response headers
+	w.Header().Set("Content-Type", "application/json")
+
+	video, err := h.DB.GetVideoByIDOrSlug(r.Context(), vars["videoId"])
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error retrieving video: %v", err), http.StatusNotFound)
+		return
+	}
+
+	events, err := h.DB.GetVideoEvents(r.Context(), video.ID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error retrieving video history: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"videoId": video.ID,
+		"status":  video.ProcessingState,
+		"events":  events,
+	})
+}
+
+// GetVideoJobs returns the transcode jobs of a video with their failure diagnostics
+func (h *StreamerHandler) GetVideoJobs(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	// Set response headers
+	w.Header().Set("Content-Type", "application/json")
+
+	video, err := h.DB.GetVideoByIDOrSlug(r.Context(), vars["videoId"])
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error retrieving video: %v", err), http.StatusNotFound)
+		return
+	}
+
+	jobs, err := h.DB.GetTranscodeJobs(r.Context(), video.ID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error retrieving transcode jobs: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"videoId": video.ID,
+		"jobs":    jobs,
+	})
+}
+
+// ServeHLSFile serves an HLS file (playlist or segment)
+func (h *StreamerHandler) ServeHLSFile(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	filename := vars["filename"]
+
+	prefix, err := h.resolveFormatPrefix(r.Context(), vars["videoId"], database.FormatHLS)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error retrieving video: %v", err), http.StatusNotFound)
+		return
+	}
+
+	// Determine the object key in storage
+	objectKey := prefix + "/" + filename
+
+	// Check if the file is cached in Redis
+	cacheKey := "hls:" + objectKey
+	cachedContent, err := h.Redis.Get(r.Context(), cacheKey).Result()
+	hit := err == nil && cachedContent != ""
+	recordCacheLookup("hls", hit)
+
+	if hit {
+		// Serve from cache
+		switch {
+		case isM3U8File(filename):
+			w.Header().Set("Content-Type", "application/x-mpegURL")
+		case strings.HasSuffix(filename, ".m4s"), strings.HasSuffix(filename, ".mp4"):
+			// Fragmented MP4 segments of HEVC, VP9 and AV1 renditions
+			w.Header().Set("Content-Type", "video/mp4")
+		default:
+			w.Header().Set("Content-Type", "video/MP2T")
+		}
+		w.Write([]byte(cachedContent))
+		return
+	}
+
+	// Generate a signed URL for the file
+	signedURL, err := h.Storage.GetSignedURL(r.Context(), objectKey, 1*time.Hour)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error generating URL: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	// Redirect to the signed URL
+	http.Redirect(w, r, signedURL, http.StatusTemporaryRedirect)
+}
+
+// ServeDASHFile serves a DASH file (manifest or segment)
+func (h *StreamerHandler) ServeDASHFile(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	filename := vars["filename"]
+
+	prefix, err := h.resolveFormatPrefix(r.Context(), vars["videoId"], database.FormatDASH)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error retrieving video: %v", err), http.StatusNotFound)
+		return
+	}
+
+	// Determine the object key in storage
+	objectKey := prefix + "/" + filename
+
+	// Check if the file is cached in Redis
+	cacheKey := "dash:" + objectKey
+	cachedContent, err := h.Redis.Get(r.Context(), cacheKey).Result()
+	hit := err == nil && cachedContent != ""
+	recordCacheLookup("dash", hit)
+
+	if hit {
+		// Serve from cache
+		if filename == "manifest.mpd" {
+			w.Header().Set("Content-Type", "application/dash+xml")
+		} else {
+			w.Header().Set("Content-Type", "video/mp4")
+		}
+		w.Write([]byte(cachedContent))
+		return
+	}
+
+	// Generate a signed URL for the file
+	signedURL, err := h.Storage.GetSignedURL(r.Context(), objectKey, 1*time.Hour)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error generating URL: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	// Redirect to the signed URL
+	http.Redirect(w, r, signedURL, http.StatusTemporaryRedirect)
+}
+
+// ServeMP4File serves a progressive MP4 rendition. Storage answers range requests, so
+// players can seek within the file. With ?download=1 the file is sent as an attachment
+// named after the video.
+func (h *StreamerHandler) ServeMP4File(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	filename := vars["filename"]
+
+	if path.Ext(filename) != ".mp4" {
+		http.Error(w, "Not an MP4 file", http.StatusNotFound)
+		return
+	}
+
+	prefix, err := h.resolveFormatPrefix(r.Context(), vars["videoId"], database.FormatMP4)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error retrieving video: %v", err), http.StatusNotFound)
+		return
+	}
+
+	// Determine the object key in storage
+	objectKey := prefix + "/" + filename
+
+	// Generate a signed URL for the file, never cached since MP4 files are large and
+	// storage serves the byte ranges directly
+	var signedURL string
+	if r.URL.Query().Get("download") == "1" {
+		signedURL, err = h.Storage.GetSignedDownloadURL(r.Context(), objectKey, vars["videoId"]+"-"+filename, 1*time.Hour)
+	} else {
+		signedURL, err = h.Storage.GetSignedURL(r.Context(), objectKey, 1*time.Hour)
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error generating URL: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	// Redirect to the signed URL, clients repeat the Range header against it
+	http.Redirect(w, r, signedURL, http.StatusTemporaryRedirect)
+}
+
+// resolveFormatPrefix maps a video ID or public slug to the key prefix of its active
+// files of a format, which follows re-encodes, reused renditions and CMAF packaging
+func (h *StreamerHandler) resolveFormatPrefix(ctx context.Context, key, format string) (string, error) {
+	// Cache briefly to keep segment requests off the database. Older versions stay in
+	// storage, so players holding a stale prefix keep working until it expires.
+	cacheKey := "storage-prefix:" + format + ":" + key
+	if prefix, err := h.Redis.Get(ctx, cacheKey).Result(); err == nil && prefix != "" {
+		recordCacheLookup("storage_prefix", true)
+		return prefix, nil
+	}
+	recordCacheLookup("storage_prefix", false)
+
+	video, err := h.DB.GetVideoByIDOrSlug(ctx, key)
+	if err != nil {
+		return "", err
+	}
+
+	prefix := video.FormatPrefix(format)
+	h.Redis.Set(ctx, cacheKey, prefix, time.Minute)
+	return prefix, nil
+}
+
+// recordCacheLookup counts a Redis cache lookup, the hit ratio per cache is
+// hits / (hits + misses)
+func recordCacheLookup(cache string, hit bool) {
+	result := metrics.CacheMiss
+	if hit {
+		result = metrics.CacheHit
+	}
+	metrics.CacheRequests.WithLabelValues(cache, result).Inc()
+}
+
+// ListVideos returns a paginated list of videos
+func (h *StreamerHandler) ListVideos(w http.ResponseWriter, r *http.Request) {
+	// Parse query parameters
+	limit := 10
+	offset := 0
+
+	if r.URL.Query().Get("limit") != "" {
+		fmt.Sscanf(r.URL.Query().Get("limit"), "%d", &limit)
+	}
+
+	if r.URL.Query().Get("offset") != "" {
+		fmt.Sscanf(r.URL.Query().Get("offset"), "%d", &offset)
+	}
+
+	// Set response headers
+	w.Header().Set("Content-Type", "application/json")
+
+	// Get videos from database
+	videos, err := h.DB.ListVideos(r.Context(), limit, offset)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error retrieving videos: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	// Return response
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"videos": videos,
+		"pagination": map[string]int{
+			"limit":  limit,
+			"offset": offset,
+		},
+	})
+}
+
+// CORS middleware
+func corsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, HEAD, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, Range")
+
+		if r.Method == "OPTIONS" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Helper function to check if a value is in a slice
+func contains(slice []string, value string) bool {
+	for _, item := range slice {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Helper function to check if a file is an M3U8 playlist
+func isM3U8File(filename string) bool {
+	return len(filename) > 5 && filename[len(filename)-5:] == ".m3u8"
+}
@@ -0,0 +1,58 @@
+package streamer
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/falcon/backend/internal/database"
+	"github.com/falcon/backend/internal/health"
+	"github.com/falcon/backend/internal/logging"
+	"github.com/falcon/backend/internal/metrics"
+	"github.com/falcon/backend/internal/storage"
+	"github.com/falcon/backend/internal/tracing"
+	"github.com/go-redis/redis/v8"
+	"github.com/gorilla/mux"
+)
+
+// NewRouter defines the routes of the streaming API, with health routes reporting
+// the checker's dependencies
+func NewRouter(streamerHandler *StreamerHandler, checker *health.Checker) *mux.Router {
+	router := mux.NewRouter()
+
+	health.Register(router, checker)
+	router.Handle("/metrics", metrics.Handler()).Methods("GET")
+	router.HandleFunc("/videos/{videoId}", streamerHandler.GetVideoInfo).Methods("GET")
+	router.HandleFunc("/videos/{videoId}/history", streamerHandler.GetVideoHistory).Methods("GET")
+	router.HandleFunc("/videos/{videoId}/jobs", streamerHandler.GetVideoJobs).Methods("GET")
+	router.HandleFunc("/videos/{videoId}/events", streamerHandler.StreamVideoEvents).Methods("GET")
+	router.HandleFunc("/videos/{videoId}/hls/{filename}", streamerHandler.ServeHLSFile).Methods("GET")
+	router.HandleFunc("/videos/{videoId}/dash/{filename}", streamerHandler.ServeDASHFile).Methods("GET")
+	router.HandleFunc("/videos/{videoId}/mp4/{filename}", streamerHandler.ServeMP4File).Methods("GET", "HEAD")
+	router.HandleFunc("/videos", streamerHandler.ListVideos).Methods("GET")
+	router.HandleFunc("/events", streamerHandler.StreamEvents).Methods("GET")
+
+	// Add metrics and CORS middleware
+	router.Use(tracing.Middleware("streamer"), logging.Middleware, metrics.Middleware("streamer"), corsMiddleware)
+
+	return router
+}
+
+// NewChecker creates the readiness checks of the streamer: video metadata comes
+// from Postgres, playlists from the bucket and Redis caches them
+func NewChecker(timeout time.Duration, db *database.Database, storageService *storage.StorageService, redisClient *redis.Client) *health.Checker {
+	return health.NewChecker("streamer", timeout).
+		Add(health.CheckPostgres, health.Postgres(db)).
+		Add(health.CheckStorage, health.Storage(storageService)).
+		Add(health.CheckRedis, health.Redis(redisClient))
+}
+
+// NewServer creates the HTTP server of the streaming API with the given read and
+// write timeouts
+func NewServer(addr string, handler http.Handler, readTimeout, writeTimeout time.Duration) *http.Server {
+	return &http.Server{
+		Handler:      handler,
+		Addr:         addr,
+		ReadTimeout:  readTimeout,
+		WriteTimeout: writeTimeout,
+	}
+}
@@ -0,0 +1,491 @@
+package supervisor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/falcon/backend/internal/logging"
+)
+
+// Restart policies of a service
+const (
+	RestartAlways    = "always"
+	RestartOnFailure = "on-failure"
+	RestartNever     = "never"
+)
+
+// Service states reported by Status
+const (
+	StateStarting  = "starting"
+	StateRunning   = "running"
+	StateBackoff   = "backoff"
+	StateCrashLoop = "crash_loop"
+	StateExited    = "exited"
+	StateStopping  = "stopping"
+	StateStopped   = "stopped"
+)
+
+// Policy controls how services are restarted and stopped
+type Policy struct {
+	Restart           string        `mapstructure:"restart"`             // RestartAlways, RestartOnFailure or RestartNever
+	InitialBackoff    time.Duration `mapstructure:"initial_backoff"`     // Delay before the first restart
+	MaxBackoff        time.Duration `mapstructure:"max_backoff"`         // Upper bound of the doubling restart delay
+	StableAfter       time.Duration `mapstructure:"stable_after"`        // Uptime after which the backoff is reset
+	CrashLoopRestarts int           `mapstructure:"crash_loop_restarts"` // Restarts within CrashLoopWindow that count as a crash loop
+	CrashLoopWindow   time.Duration `mapstructure:"crash_loop_window"`
+	CrashLoopCooldown time.Duration `mapstructure:"crash_loop_cooldown"` // Pause before trying a crash looping service again
+	StopGracePeriod   time.Duration `mapstructure:"stop_grace_period"`   // Time between SIGTERM and SIGKILL
+}
+
+// DefaultPolicy returns the policy used for unset fields
+func DefaultPolicy() Policy {
+	return Policy{
+		Restart:           RestartAlways,
+		InitialBackoff:    time.Second,
+		MaxBackoff:        time.Minute,
+		StableAfter:       30 * time.Second,
+		CrashLoopRestarts: 5,
+		CrashLoopWindow:   2 * time.Minute,
+		CrashLoopCooldown: 5 * time.Minute,
+		StopGracePeriod:   10 * time.Second,
+	}
+}
+
+// withDefaults fills the unset fields of a policy from DefaultPolicy
+func (p Policy) withDefaults() Policy {
+	d := DefaultPolicy()
+	if p.Restart == "" {
+		p.Restart = d.Restart
+	}
+	if p.InitialBackoff <= 0 {
+		p.InitialBackoff = d.InitialBackoff
+	}
+	if p.MaxBackoff < p.InitialBackoff {
+		p.MaxBackoff = max(d.MaxBackoff, p.InitialBackoff)
+	}
+	if p.StableAfter <= 0 {
+		p.StableAfter = d.StableAfter
+	}
+	if p.CrashLoopRestarts <= 0 {
+		p.CrashLoopRestarts = d.CrashLoopRestarts
+	}
+	if p.CrashLoopWindow <= 0 {
+		p.CrashLoopWindow = d.CrashLoopWindow
+	}
+	if p.CrashLoopCooldown <= 0 {
+		p.CrashLoopCooldown = d.CrashLoopCooldown
+	}
+	if p.StopGracePeriod <= 0 {
+		p.StopGracePeriod = d.StopGracePeriod
+	}
+	return p
+}
+
+// Spec describes a service binary to supervise
+type Spec struct {
+	Name string
+	Path string
+	Args []string
+	Env  []string // Added to the environment of the supervisor
+}
+
+// Exit describes how a service process ended
+type Exit struct {
+	Code   int       `json:"code"`
+	Signal string    `json:"signal,omitempty"`
+	Error  string    `json:"error,omitempty"`
+	At     time.Time `json:"at"`
+}
+
+// Status is a snapshot of a supervised service
+type Status struct {
+	Name        string     `json:"name"`
+	State       string     `json:"state"`
+	PID         int        `json:"pid,omitempty"`
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	Uptime      string     `json:"uptime,omitempty"`
+	Restarts    int        `json:"restarts"`
+	NextRestart *time.Time `json:"next_restart,omitempty"`
+	LastExit    *Exit      `json:"last_exit,omitempty"`
+}
+
+// Supervisor runs service binaries and restarts them according to a policy
+type Supervisor struct {
+	policy   Policy
+	services []*service
+	wg       sync.WaitGroup
+}
+
+// service is the runtime state of a supervised process
+type service struct {
+	spec   Spec
+	policy Policy
+
+	mu          sync.Mutex
+	state       string
+	cmd         *exec.Cmd
+	startedAt   time.Time
+	restarts    int
+	nextRestart time.Time
+	lastExit    *Exit
+	crashes     []time.Time
+	backoff     time.Duration // Delay before the next restart, only used by run
+}
+
+// New creates a supervisor for the given services
+func New(policy Policy, specs []Spec) *Supervisor {
+	policy = policy.withDefaults()
+
+	s := &Supervisor{policy: policy}
+	for _, spec := range specs {
+		s.services = append(s.services, &service{
+			spec:    spec,
+			policy:  policy,
+			state:   StateStopped,
+			backoff: policy.InitialBackoff,
+		})
+	}
+	return s
+}
+
+// Start launches every service and keeps them running until the context is cancelled,
+// at which point the services are stopped gracefully. Wait blocks until they are down.
+func (s *Supervisor) Start(ctx context.Context) {
+	for _, svc := range s.services {
+		s.wg.Add(1)
+		go func(svc *service) {
+			defer s.wg.Done()
+			svc.run(ctx)
+		}(svc)
+	}
+}
+
+// Wait blocks until every service has stopped
+func (s *Supervisor) Wait() {
+	s.wg.Wait()
+	slog.Info("All services stopped")
+}
+
+// Status returns a snapshot of every service ordered by name
+func (s *Supervisor) Status() []Status {
+	statuses := make([]Status, 0, len(s.services))
+	for _, svc := range s.services {
+		statuses = append(statuses, svc.status())
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Name < statuses[j].Name
+	})
+	return statuses
+}
+
+// run starts the service and restarts it until the context is cancelled or the
+// restart policy gives up
+func (svc *service) run(ctx context.Context) {
+	for {
+		exit, uptime := svc.runOnce(ctx)
+		if ctx.Err() != nil {
+			svc.setState(StateStopped)
+			return
+		}
+
+		if !svc.shouldRestart(exit) {
+			slog.Info("Service exited and will not be restarted", "child", svc.spec.Name)
+			svc.setState(StateExited)
+			return
+		}
+
+		delay, state := svc.scheduleRestart(exit, uptime)
+
+		svc.mu.Lock()
+		svc.state = state
+		svc.nextRestart = time.Now().Add(delay)
+		svc.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			svc.setState(StateStopped)
+			return
+		case <-time.After(delay):
+		}
+
+		svc.mu.Lock()
+		svc.restarts++
+		svc.nextRestart = time.Time{}
+		svc.mu.Unlock()
+	}
+}
+
+// runOnce runs the service process until it exits or the context is cancelled, and
+// returns how it ended and how long it was up
+func (svc *service) runOnce(ctx context.Context) (Exit, time.Duration) {
+	svc.setState(StateStarting)
+	slog.Info("Starting service", "child", svc.spec.Name)
+
+	// Services log JSON lines that are forwarded as they are, anything else is
+	// wrapped in a JSON line attributed to the service
+	logger := logging.New(svc.spec.Name)
+	stdout := newLineLogger(os.Stdout, logger.With("stream", "stdout"), slog.LevelInfo)
+	stderr := newLineLogger(os.Stderr, logger.With("stream", "stderr"), slog.LevelError)
+	defer stdout.Flush()
+	defer stderr.Flush()
+
+	cmd := exec.Command(svc.spec.Path, svc.spec.Args...)
+	cmd.Env = append(os.Environ(), svc.spec.Env...)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	// Run in its own process group so signals reach any children it spawns
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	// Do not wait forever for output of children that outlive the service
+	cmd.WaitDelay = svc.policy.StopGracePeriod
+
+	if err := cmd.Start(); err != nil {
+		slog.Error("Failed to start service", "child", svc.spec.Name, "error", err)
+		return svc.recordExit(Exit{Code: -1, Error: err.Error(), At: time.Now()}), 0
+	}
+
+	exited := make(chan struct{})
+	startedAt := time.Now()
+
+	svc.mu.Lock()
+	svc.cmd = cmd
+	svc.startedAt = startedAt
+	svc.state = StateRunning
+	svc.mu.Unlock()
+
+	// Stop the process when the supervisor shuts down
+	go func() {
+		select {
+		case <-ctx.Done():
+			svc.stop(cmd, exited)
+		case <-exited:
+		}
+	}()
+
+	err := cmd.Wait()
+	close(exited)
+
+	exit := exitFromError(err)
+	svc.mu.Lock()
+	svc.cmd = nil
+	svc.mu.Unlock()
+
+	if exit.Code == 0 && exit.Signal == "" {
+		slog.Info("Service completed", "child", svc.spec.Name)
+	} else {
+		slog.Error("Service exited", "child", svc.spec.Name, "code", exit.Code, "signal", exit.Signal)
+	}
+
+	return svc.recordExit(exit), exit.At.Sub(startedAt)
+}
+
+// stop sends SIGTERM to the process and SIGKILL when it is still running after the
+// grace period
+func (svc *service) stop(cmd *exec.Cmd, exited <-chan struct{}) {
+	svc.setState(StateStopping)
+	slog.Info("Stopping service", "child", svc.spec.Name)
+
+	pgid := -cmd.Process.Pid
+	if err := syscall.Kill(pgid, syscall.SIGTERM); err != nil {
+		slog.Error("Failed to signal service", "child", svc.spec.Name, "error", err)
+	}
+
+	select {
+	case <-exited:
+	case <-time.After(svc.policy.StopGracePeriod):
+		slog.Warn("Service did not stop in time, killing it", "child", svc.spec.Name, "grace_period", svc.policy.StopGracePeriod)
+		if err := syscall.Kill(pgid, syscall.SIGKILL); err != nil {
+			slog.Error("Failed to kill service", "child", svc.spec.Name, "error", err)
+		}
+	}
+}
+
+// scheduleRestart returns the delay before restarting after an exit and the state
+// to report meanwhile. The delay doubles with every restart up to MaxBackoff, and
+// a crash looping service waits for CrashLoopCooldown instead.
+func (svc *service) scheduleRestart(exit Exit, uptime time.Duration) (time.Duration, string) {
+	// A run that stayed up long enough is not part of a crash streak
+	if uptime >= svc.policy.StableAfter {
+		svc.backoff = svc.policy.InitialBackoff
+	}
+
+	if svc.recordCrash(exit.At) {
+		delay := svc.policy.CrashLoopCooldown
+		slog.Error("Service is crash looping", "child", svc.spec.Name,
+			"restarts", svc.policy.CrashLoopRestarts, "window", svc.policy.CrashLoopWindow, "retry_in", delay)
+		return delay, StateCrashLoop
+	}
+
+	delay := svc.backoff
+	slog.Warn("Service exited, restarting", "child", svc.spec.Name, "retry_in", delay)
+	svc.backoff = min(svc.backoff*2, svc.policy.MaxBackoff)
+	return delay, StateBackoff
+}
+
+// shouldRestart applies the restart policy to an exit
+func (svc *service) shouldRestart(exit Exit) bool {
+	switch svc.policy.Restart {
+	case RestartNever:
+		return false
+	case RestartOnFailure:
+		return exit.Code != 0 || exit.Signal != "" || exit.Error != ""
+	default:
+		return true
+	}
+}
+
+// recordCrash remembers an exit and reports whether the service is crash looping,
+// that is whether it exited CrashLoopRestarts times within CrashLoopWindow
+func (svc *service) recordCrash(at time.Time) bool {
+	svc.mu.Lock()
+	defer svc.mu.Unlock()
+
+	cutoff := at.Add(-svc.policy.CrashLoopWindow)
+	crashes := svc.crashes[:0]
+	for _, crash := range svc.crashes {
+		if crash.After(cutoff) {
+			crashes = append(crashes, crash)
+		}
+	}
+	svc.crashes = append(crashes, at)
+
+	if len(svc.crashes) < svc.policy.CrashLoopRestarts {
+		return false
+	}
+
+	// Start counting again after the cooldown
+	svc.crashes = svc.crashes[:0]
+	return true
+}
+
+// recordExit stores the last exit of the service
+func (svc *service) recordExit(exit Exit) Exit {
+	svc.mu.Lock()
+	svc.lastExit = &exit
+	svc.startedAt = time.Time{}
+	svc.mu.Unlock()
+	return exit
+}
+
+// setState updates the state of the service
+func (svc *service) setState(state string) {
+	svc.mu.Lock()
+	svc.state = state
+	svc.mu.Unlock()
+}
+
+// status returns a snapshot of the service
+func (svc *service) status() Status {
+	svc.mu.Lock()
+	defer svc.mu.Unlock()
+
+	status := Status{
+		Name:     svc.spec.Name,
+		State:    svc.state,
+		Restarts: svc.restarts,
+		LastExit: svc.lastExit,
+	}
+	if svc.cmd != nil && svc.cmd.Process != nil {
+		status.PID = svc.cmd.Process.Pid
+	}
+	if !svc.startedAt.IsZero() {
+		startedAt := svc.startedAt
+		status.StartedAt = &startedAt
+		status.Uptime = time.Since(startedAt).Round(time.Second).String()
+	}
+	if !svc.nextRestart.IsZero() {
+		nextRestart := svc.nextRestart
+		status.NextRestart = &nextRestart
+	}
+	return status
+}
+
+// exitFromError converts the result of cmd.Wait into an Exit
+func exitFromError(err error) Exit {
+	exit := Exit{At: time.Now()}
+	if err == nil {
+		return exit
+	}
+
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		exit.Code = -1
+		exit.Error = err.Error()
+		return exit
+	}
+
+	exit.Code = exitErr.ExitCode()
+	if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		exit.Signal = status.Signal().String()
+	}
+	return exit
+}
+
+// lineLogger writes the output of a service line by line, copying JSON log lines
+// to out and logging other lines at the given level
+type lineLogger struct {
+	mu     sync.Mutex
+	out    io.Writer
+	logger *slog.Logger
+	level  slog.Level
+	buf    bytes.Buffer
+}
+
+// newLineLogger creates a writer that forwards every complete line
+func newLineLogger(out io.Writer, logger *slog.Logger, level slog.Level) *lineLogger {
+	return &lineLogger{out: out, logger: logger, level: level}
+}
+
+// emit forwards a single line without its line ending
+func (l *lineLogger) emit(line string) {
+	if strings.HasPrefix(line, "{") && json.Valid([]byte(line)) {
+		l.out.Write([]byte(line + "\n"))
+		return
+	}
+	l.logger.Log(context.Background(), l.level, line)
+}
+
+// Write forwards the complete lines of p and keeps the rest for the next write
+func (l *lineLogger) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.buf.Write(p)
+	for {
+		line, err := l.buf.ReadString('\n')
+		if err != nil {
+			// Incomplete line, put it back
+			l.buf.WriteString(line)
+			return len(p), nil
+		}
+		l.emit(strings.TrimRight(line, "\r\n"))
+	}
+}
+
+// Flush forwards a trailing line without a newline
+func (l *lineLogger) Flush() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.buf.Len() > 0 {
+		l.emit(l.buf.String())
+		l.buf.Reset()
+	}
+}
+
+// String describes the policy for logging
+func (p Policy) String() string {
+	return fmt.Sprintf("restart=%s backoff=%s..%s crash_loop=%d/%s stop_grace=%s",
+		p.Restart, p.InitialBackoff, p.MaxBackoff, p.CrashLoopRestarts, p.CrashLoopWindow, p.StopGracePeriod)
+}
@@ -0,0 +1,286 @@
+package supervisor
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"os/exec"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testPolicy is a policy with round numbers that tests can reason about
+var testPolicy = Policy{
+	Restart:           RestartAlways,
+	InitialBackoff:    time.Second,
+	MaxBackoff:        8 * time.Second,
+	StableAfter:       30 * time.Second,
+	CrashLoopRestarts: 5,
+	CrashLoopWindow:   2 * time.Minute,
+	CrashLoopCooldown: 5 * time.Minute,
+	StopGracePeriod:   time.Second,
+}
+
+func TestWithDefaults(t *testing.T) {
+	d := DefaultPolicy()
+
+	tests := []struct {
+		name   string
+		policy Policy
+		want   Policy
+	}{
+		{
+			name:   "empty",
+			policy: Policy{},
+			want:   d,
+		},
+		{
+			name:   "complete",
+			policy: testPolicy,
+			want:   testPolicy,
+		},
+		{
+			name:   "max backoff below initial backoff",
+			policy: Policy{InitialBackoff: 2 * time.Minute, MaxBackoff: time.Second},
+			want: func() Policy {
+				p := d
+				p.InitialBackoff = 2 * time.Minute
+				p.MaxBackoff = 2 * time.Minute
+				return p
+			}(),
+		},
+		{
+			name:   "negative durations",
+			policy: Policy{Restart: RestartNever, InitialBackoff: -time.Second, StableAfter: -time.Second, StopGracePeriod: -time.Second},
+			want: func() Policy {
+				p := d
+				p.Restart = RestartNever
+				return p
+			}(),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.withDefaults(); got != tt.want {
+				t.Errorf("withDefaults() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestShouldRestart(t *testing.T) {
+	success := Exit{Code: 0}
+	failure := Exit{Code: 1}
+	signaled := Exit{Code: -1, Signal: "killed"}
+	startFailure := Exit{Code: -1, Error: "no such file or directory"}
+
+	tests := []struct {
+		restart string
+		exit    Exit
+		want    bool
+	}{
+		{RestartAlways, success, true},
+		{RestartAlways, failure, true},
+		{RestartOnFailure, success, false},
+		{RestartOnFailure, failure, true},
+		{RestartOnFailure, signaled, true},
+		{RestartOnFailure, startFailure, true},
+		{RestartNever, success, false},
+		{RestartNever, failure, false},
+	}
+
+	for _, tt := range tests {
+		svc := &service{policy: Policy{Restart: tt.restart}}
+		if got := svc.shouldRestart(tt.exit); got != tt.want {
+			t.Errorf("shouldRestart(%s, %+v) = %v, want %v", tt.restart, tt.exit, got, tt.want)
+		}
+	}
+}
+
+func TestScheduleRestart(t *testing.T) {
+	type step struct {
+		after     time.Duration // Time since the previous exit
+		uptime    time.Duration
+		wantDelay time.Duration
+		wantState string
+	}
+
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "backoff doubles up to the maximum",
+			steps: []step{
+				{time.Minute, time.Second, time.Second, StateBackoff},
+				{time.Minute, time.Second, 2 * time.Second, StateBackoff},
+				{time.Minute, time.Second, 4 * time.Second, StateBackoff},
+				{time.Minute, time.Second, 8 * time.Second, StateBackoff},
+				{time.Minute, time.Second, 8 * time.Second, StateBackoff},
+			},
+		},
+		{
+			name: "stable run resets the backoff",
+			steps: []step{
+				{time.Minute, time.Second, time.Second, StateBackoff},
+				{time.Minute, time.Second, 2 * time.Second, StateBackoff},
+				{time.Minute, time.Second, 4 * time.Second, StateBackoff},
+				{time.Minute, 30 * time.Second, time.Second, StateBackoff},
+				{time.Minute, time.Second, 2 * time.Second, StateBackoff},
+			},
+		},
+		{
+			name: "crash loop pauses for the cooldown",
+			steps: []step{
+				{time.Second, 0, time.Second, StateBackoff},
+				{time.Second, 0, 2 * time.Second, StateBackoff},
+				{time.Second, 0, 4 * time.Second, StateBackoff},
+				{time.Second, 0, 8 * time.Second, StateBackoff},
+				{time.Second, 0, 5 * time.Minute, StateCrashLoop},
+				// The crash count starts again after the cooldown
+				{5 * time.Minute, 0, 8 * time.Second, StateBackoff},
+				{time.Second, 0, 8 * time.Second, StateBackoff},
+				{time.Second, 0, 8 * time.Second, StateBackoff},
+				{time.Second, 0, 8 * time.Second, StateBackoff},
+				{time.Second, 0, 5 * time.Minute, StateCrashLoop},
+			},
+		},
+		{
+			name: "crashes outside the window do not count",
+			steps: []step{
+				{time.Minute, 0, time.Second, StateBackoff},
+				{time.Minute, 0, 2 * time.Second, StateBackoff},
+				{time.Minute, 0, 4 * time.Second, StateBackoff},
+				{time.Minute, 0, 8 * time.Second, StateBackoff},
+				{time.Minute, 0, 8 * time.Second, StateBackoff},
+				{time.Minute, 0, 8 * time.Second, StateBackoff},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := &service{
+				spec:    Spec{Name: "test"},
+				policy:  testPolicy,
+				backoff: testPolicy.InitialBackoff,
+			}
+
+			at := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+			for i, s := range tt.steps {
+				at = at.Add(s.after)
+				delay, state := svc.scheduleRestart(Exit{Code: 1, At: at}, s.uptime)
+				if delay != s.wantDelay || state != s.wantState {
+					t.Errorf("step %d: scheduleRestart() = %s, %s, want %s, %s", i+1, delay, state, s.wantDelay, s.wantState)
+				}
+			}
+		})
+	}
+}
+
+func TestExitFromError(t *testing.T) {
+	tests := []struct {
+		name       string
+		cmd        *exec.Cmd
+		wantCode   int
+		wantSignal string
+		wantError  bool
+	}{
+		{"success", exec.Command("sh", "-c", "exit 0"), 0, "", false},
+		{"exit code", exec.Command("sh", "-c", "exit 3"), 3, "", false},
+		{"signal", exec.Command("sh", "-c", "kill -TERM $$"), -1, "terminated", false},
+		{"start failure", exec.Command("/nonexistent/binary"), -1, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exit := exitFromError(tt.cmd.Run())
+			if exit.Code != tt.wantCode || exit.Signal != tt.wantSignal || (exit.Error != "") != tt.wantError {
+				t.Errorf("exitFromError() = %+v, want code %d, signal %q, error %v", exit, tt.wantCode, tt.wantSignal, tt.wantError)
+			}
+			if exit.At.IsZero() {
+				t.Error("exitFromError() did not set the exit time")
+			}
+		})
+	}
+}
+
+func TestLineLogger(t *testing.T) {
+	tests := []struct {
+		name       string
+		writes     []string
+		wantOut    []string // Lines copied as they are
+		wantLogged []string // Messages of lines wrapped in a log record
+	}{
+		{
+			name:       "json and plain lines",
+			writes:     []string{"{\"msg\":\"ready\"}\nplain text\n"},
+			wantOut:    []string{`{"msg":"ready"}`},
+			wantLogged: []string{"plain text"},
+		},
+		{
+			name:    "line split across writes",
+			writes:  []string{"{\"msg\":", "\"split\"}", "\n"},
+			wantOut: []string{`{"msg":"split"}`},
+		},
+		{
+			name:       "crlf line endings",
+			writes:     []string{"first\r\nsecond\r\n"},
+			wantLogged: []string{"first", "second"},
+		},
+		{
+			name:       "invalid json",
+			writes:     []string{"{not json}\n"},
+			wantLogged: []string{"{not json}"},
+		},
+		{
+			name:       "trailing line is flushed",
+			writes:     []string{"done\npartial"},
+			wantLogged: []string{"done", "partial"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out, logged bytes.Buffer
+			l := newLineLogger(&out, slog.New(slog.NewJSONHandler(&logged, nil)), slog.LevelInfo)
+
+			for _, w := range tt.writes {
+				n, err := l.Write([]byte(w))
+				if err != nil || n != len(w) {
+					t.Fatalf("Write(%q) = %d, %v", w, n, err)
+				}
+			}
+			l.Flush()
+
+			if got := lines(out.String()); !slices.Equal(got, tt.wantOut) {
+				t.Errorf("copied lines = %q, want %q", got, tt.wantOut)
+			}
+
+			var messages []string
+			for _, line := range lines(logged.String()) {
+				var record struct {
+					Msg string `json:"msg"`
+				}
+				if err := json.Unmarshal([]byte(line), &record); err != nil {
+					t.Fatalf("invalid log record %q: %v", line, err)
+				}
+				messages = append(messages, record.Msg)
+			}
+			if !slices.Equal(messages, tt.wantLogged) {
+				t.Errorf("logged messages = %q, want %q", messages, tt.wantLogged)
+			}
+		})
+	}
+}
+
+// lines splits output into lines, ignoring the final line ending
+func lines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
@@ -0,0 +1,46 @@
+package tracing
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Middleware starts a server span for every request, continuing the trace of the
+// caller, named after the matched route template and tagged with the video ID.
+// Metrics scrapes and health probes are not traced.
+func Middleware(service string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		tagged := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			span := trace.SpanFromContext(r.Context())
+			span.SetAttributes(AttrService.String(service))
+			if videoID := mux.Vars(r)["videoId"]; videoID != "" {
+				span.SetAttributes(AttrVideoID.String(videoID))
+			}
+			next.ServeHTTP(w, r)
+		})
+
+		return otelhttp.NewHandler(tagged, service,
+			otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+				if route := mux.CurrentRoute(r); route != nil {
+					if tmpl, err := route.GetPathTemplate(); err == nil {
+						return r.Method + " " + tmpl
+					}
+				}
+				return r.Method
+			}),
+			otelhttp.WithFilter(func(r *http.Request) bool {
+				return r.URL.Path != "/metrics" && !strings.HasPrefix(r.URL.Path, "/health")
+			}),
+		)
+	}
+}
+
+// Transport wraps an HTTP transport to start client spans and send the trace
+// context to the upstream
+func Transport(base http.RoundTripper) http.RoundTripper {
+	return otelhttp.NewTransport(base)
+}
@@ -0,0 +1,52 @@
+package tracing
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// QueryLogger is a pgx logger that records completed queries as spans. pgx v4 has no
+// tracing hooks, but it logs every query with its context and duration once it
+// finishes, so the span is created after the fact with its real start time.
+type QueryLogger struct{}
+
+// Log implements pgx.Logger
+func (QueryLogger) Log(ctx context.Context, level pgx.LogLevel, msg string, data map[string]interface{}) {
+	switch msg {
+	case "Query", "Exec", "SendBatch":
+	default:
+		return
+	}
+
+	// Only trace queries made on behalf of a traced operation
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return
+	}
+
+	end := time.Now()
+	start := end
+	if elapsed, ok := data["time"].(time.Duration); ok {
+		start = end.Add(-elapsed)
+	}
+
+	statement, _ := data["sql"].(string)
+	_, span := Tracer().Start(ctx, "db."+strings.ToLower(msg),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithTimestamp(start),
+		trace.WithAttributes(
+			semconv.DBSystemPostgreSQL,
+			semconv.DBQueryText(statement),
+		),
+	)
+	if err, ok := data["err"].(error); ok && level <= pgx.LogLevelError {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End(trace.WithTimestamp(end))
+}
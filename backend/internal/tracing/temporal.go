@@ -0,0 +1,23 @@
+package tracing
+
+import (
+	"fmt"
+
+	"go.temporal.io/sdk/client"
+	temporalotel "go.temporal.io/sdk/contrib/opentelemetry"
+	"go.temporal.io/sdk/interceptor"
+)
+
+// NewTemporalClient creates a Temporal client that carries the trace context in
+// workflow and activity headers. Workers created from the client use the same
+// interceptor, so workflow and activity spans join the trace of the request that
+// started the workflow.
+func NewTemporalClient(options client.Options) (client.Client, error) {
+	tracingInterceptor, err := temporalotel.NewTracingInterceptor(temporalotel.TracerOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create tracing interceptor: %v", err)
+	}
+
+	options.Interceptors = append(options.Interceptors, interceptor.ClientInterceptor(tracingInterceptor))
+	return client.NewClient(options)
+}
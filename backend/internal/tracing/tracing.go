@@ -0,0 +1,125 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Exporters selectable with tracing.exporter
+const (
+	ExporterNone   = "none"
+	ExporterOTLP   = "otlp"
+	ExporterStdout = "stdout"
+)
+
+// InstrumentationName names the tracer used by Falcon's own spans
+const InstrumentationName = "github.com/falcon/backend"
+
+// Attribute keys shared by spans across services
+const (
+	AttrService = attribute.Key("falcon.service")
+	AttrVideoID = attribute.Key("falcon.video_id")
+)
+
+// Config selects and configures the span exporter
+type Config struct {
+	// Exporter is ExporterNone, ExporterOTLP or ExporterStdout
+	Exporter string `mapstructure:"exporter"`
+	// Endpoint is the host:port of an OTLP/HTTP collector, empty uses the
+	// OTEL_EXPORTER_OTLP_ENDPOINT environment variable or localhost:4318
+	Endpoint string `mapstructure:"endpoint"`
+	// Insecure sends spans to the collector over plain HTTP
+	Insecure bool `mapstructure:"insecure"`
+	// SampleRatio is the fraction of new traces recorded, child spans follow their parent
+	SampleRatio float64 `mapstructure:"sample_ratio"`
+}
+
+// Init installs the global tracer provider and W3C trace context propagation for a
+// service. Propagation is enabled even without an exporter, so traces started by
+// other services pass through. The returned function flushes pending spans.
+func Init(ctx context.Context, service string, config Config) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch config.Exporter {
+	case "", ExporterNone:
+		return func(context.Context) error { return nil }, nil
+	case ExporterOTLP:
+		var opts []otlptracehttp.Option
+		if config.Endpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpoint(config.Endpoint))
+		}
+		if config.Insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		exporter, err = otlptracehttp.New(ctx, opts...)
+	case ExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter %q", config.Exporter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s exporter: %v", config.Exporter, err)
+	}
+
+	res, err := resource.New(ctx,
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+		resource.WithHost(),
+		resource.WithAttributes(semconv.ServiceName("falcon-"+service)),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build trace resource: %v", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(config.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
+
+// Tracer returns the tracer for Falcon's own spans
+func Tracer() trace.Tracer {
+	return otel.Tracer(InstrumentationName)
+}
+
+// Start starts a span as a child of any span in ctx
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return Tracer().Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End ends a span, marking it failed when the error it points to is set. It takes
+// the address of the operation's error so it can be deferred.
+func End(span trace.Span, err *error) {
+	if err != nil && *err != nil {
+		span.RecordError(*err)
+		span.SetStatus(codes.Error, (*err).Error())
+	}
+	span.End()
+}
+
+// SetVideoID tags the current span with the video it concerns, so traces can be
+// found by video across services
+func SetVideoID(ctx context.Context, videoID string) {
+	trace.SpanFromContext(ctx).SetAttributes(AttrVideoID.String(videoID))
+}
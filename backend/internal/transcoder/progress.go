@@ -0,0 +1,109 @@
+package transcoder
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/falcon/backend/internal/events"
+	"github.com/falcon/backend/internal/ffmpeg"
+)
+
+// publishTimeout bounds a progress publish, which blocks reading ffmpeg's output
+const publishTimeout = 2 * time.Second
+
+// progressTracker publishes the percent of a transcode done across its ffmpeg
+// runs, each counting for the same share of the work. Whole percents are
+// published once, so subscribers receive at most 100 updates per transcode.
+type progressTracker struct {
+	ctx       context.Context
+	publisher *events.Publisher
+	videoID   string
+	reprocess bool
+	duration  time.Duration
+	runs      int
+	next      int
+
+	mu        sync.Mutex
+	published float64
+	warned    bool
+}
+
+// newProgressTracker creates a tracker for a transcode of the input made of runs ffmpeg runs
+func newProgressTracker(ctx context.Context, publisher *events.Publisher, input TranscodeInput, runs int) *progressTracker {
+	return &progressTracker{
+		ctx:       ctx,
+		publisher: publisher,
+		videoID:   input.VideoID,
+		reprocess: input.Reprocess,
+		duration:  time.Duration(input.Duration * float64(time.Second)),
+		runs:      runs,
+	}
+}
+
+// encode returns a context reporting the progress of the next ffmpeg run. Progress
+// is not reported when the input duration is unknown.
+func (t *progressTracker) encode(ctx context.Context) context.Context {
+	if t.duration <= 0 || t.next >= t.runs {
+		return ctx
+	}
+
+	run := t.next
+	t.next++
+	return ffmpeg.WithProgress(ctx, func(encoded time.Duration) {
+		fraction := math.Min(float64(encoded)/float64(t.duration), 1)
+		t.report((float64(run) + fraction) / float64(t.runs) * 100)
+	})
+}
+
+// finish reports the encoding as complete, covering runs that stop just short of
+// the input duration
+func (t *progressTracker) finish() {
+	t.report(100)
+}
+
+// report publishes a new whole percent. Publish failures are logged once since
+// progress is informational.
+func (t *progressTracker) report(percent float64) {
+	percent = math.Floor(percent)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if percent <= t.published {
+		return
+	}
+	t.published = percent
+
+	ctx, cancel := context.WithTimeout(t.ctx, publishTimeout)
+	defer cancel()
+
+	err := t.publisher.Publish(ctx, events.Update{
+		Type:      events.TypeProgress,
+		VideoID:   t.videoID,
+		Percent:   percent,
+		Reprocess: t.reprocess,
+	})
+	if err != nil && !t.warned {
+		t.warned = true
+		slog.WarnContext(t.ctx, "Failed to publish transcode progress", "error", err)
+	}
+}
+
+// publishState tells subscribers about a state transition. Failures are logged
+// since the transition itself is recorded in the database.
+func publishState(ctx context.Context, videoID, state, errMsg string) {
+	deps := GetDependencies(ctx)
+
+	err := deps.Events.Publish(ctx, events.Update{
+		Type:    events.TypeState,
+		VideoID: videoID,
+		State:   state,
+		Error:   errMsg,
+	})
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to publish state transition", "state", state, "error", err)
+	}
+}
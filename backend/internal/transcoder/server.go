@@ -0,0 +1,39 @@
+package transcoder
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/falcon/backend/internal/health"
+	"github.com/falcon/backend/internal/logging"
+	"github.com/falcon/backend/internal/metrics"
+	"github.com/falcon/backend/internal/tracing"
+	"github.com/gorilla/mux"
+	"go.temporal.io/sdk/client"
+)
+
+// NewChecker creates the readiness checks of the transcoder: activities read and
+// write Postgres and the bucket, run ffmpeg and poll Temporal
+func NewChecker(timeout time.Duration, temporalClient client.Client, deps *ActivityDependencies) *health.Checker {
+	return health.NewChecker("transcoder", timeout).
+		Add(health.CheckPostgres, health.Postgres(deps.DB)).
+		Add(health.CheckStorage, health.Storage(deps.Storage)).
+		Add(health.CheckTemporal, health.Temporal(temporalClient)).
+		Add(health.CheckFFmpeg, health.FFmpeg(deps.FFmpeg))
+}
+
+// NewHealthServer creates the HTTP server answering the transcoder's liveness and
+// readiness probes and metrics scrapes, the worker itself only talks to Temporal
+func NewHealthServer(addr string, checker *health.Checker) *http.Server {
+	router := mux.NewRouter()
+	health.Register(router, checker)
+	router.Handle("/metrics", metrics.Handler()).Methods("GET")
+	router.Use(tracing.Middleware("transcoder"), logging.Middleware, metrics.Middleware("transcoder"))
+
+	return &http.Server{
+		Handler:      router,
+		Addr:         addr,
+		ReadTimeout:  15 * time.Second,
+		WriteTimeout: 15 * time.Second,
+	}
+}
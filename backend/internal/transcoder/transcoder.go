@@ -0,0 +1,776 @@
+package transcoder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/falcon/backend/internal/database"
+	"github.com/falcon/backend/internal/ffmpeg"
+	"github.com/falcon/backend/internal/logging"
+	"github.com/falcon/backend/internal/metrics"
+	"github.com/falcon/backend/internal/webhooks"
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+)
+
+// Define workflow constants
+const (
+	TaskQueue = "TRANSCODER_TASK_QUEUE"
+)
+
+// TranscodeParams contains parameters for the transcoding workflow
+type TranscodeParams struct {
+	VideoID     string `json:"videoID"`
+	ObjectKey   string `json:"objectKey"`
+	Filename    string `json:"filename"`
+	ContentType string `json:"contentType"`
+	Profile     string `json:"profile,omitempty"`
+
+	// Reprocess re-encodes a video that is already playable. The video keeps its
+	// processing state and only the active stream version changes on success.
+	Reprocess bool `json:"reprocess,omitempty"`
+}
+
+// ResolutionConfig defines a resolution for transcoding
+type ResolutionConfig struct {
+	Width   int    `json:"width"`
+	Height  int    `json:"height"`
+	Bitrate string `json:"bitrate"`
+}
+
+// TranscodeWorkflow defines the workflow for video transcoding
+func TranscodeWorkflow(ctx workflow.Context, params TranscodeParams) (string, error) {
+	ctx = logging.WithWorkflowVideoID(ctx, params.VideoID)
+	logger := logging.WorkflowLogger(ctx)
+
+	if params.Profile == "" {
+		params.Profile = database.DefaultProfile
+	}
+	logger.Info("Starting transcoding workflow", "profile", params.Profile, "reprocess", params.Reprocess)
+
+	// Update video status to "processing"
+	if !params.Reprocess {
+		if err := updateVideoStatus(ctx, params.VideoID, database.StateProcessing, ""); err != nil {
+			return "", err
+		}
+	}
+
+	// Mark the job as failed. A failed reprocess leaves the video and its active streams
+	// untouched. Finishing a job that was never recorded changes nothing.
+	fail := func(err error) (string, error) {
+		if !params.Reprocess {
+			updateVideoStatus(ctx, params.VideoID, database.StateError, err.Error())
+		}
+		finishTranscodeJob(ctx, database.JobFailed, err.Error())
+		emitEvent(ctx, database.EventVideoFailed, params, database.StateError, err.Error())
+		return "", err
+	}
+
+	// Record the job so failures can be diagnosed later
+	if err := workflow.ExecuteActivity(withBookkeepingOptions(ctx), StartTranscodeJobActivity, params).Get(ctx, nil); err != nil {
+		return fail(err)
+	}
+	emitEvent(ctx, database.EventVideoTranscoding, params, database.StateProcessing, "")
+
+	// Load the profile once so the whole run uses the same settings
+	var profile database.Profile
+	if err := workflow.ExecuteActivity(withBookkeepingOptions(ctx), LoadProfileActivity, params.Profile).Get(ctx, &profile); err != nil {
+		return fail(err)
+	}
+
+	// Every encode writes to a new version prefix so the active streams are never overwritten
+	var version int
+	if err := workflow.ExecuteActivity(withBookkeepingOptions(ctx), AllocateStreamVersionActivity, params.VideoID).Get(ctx, &version); err != nil {
+		return fail(err)
+	}
+
+	// Activity options with retry policy
+	activityOptions := workflow.ActivityOptions{
+		StartToCloseTimeout: 30 * time.Minute, // Video transcoding can take a while
+		RetryPolicy: &temporal.RetryPolicy{
+			InitialInterval:    time.Minute,
+			BackoffCoefficient: 2.0,
+			MaximumInterval:    10 * time.Minute,
+			MaximumAttempts:    3,
+		},
+	}
+	ctx = workflow.WithActivityOptions(ctx, activityOptions)
+
+	// 1. Download video
+	var downloadResult DownloadResult
+	if err := workflow.ExecuteActivity(ctx, DownloadVideoActivity, params).Get(ctx, &downloadResult); err != nil {
+		return fail(err)
+	}
+
+	// 2. Extract metadata
+	var metadataResult MetadataResult
+	if err := workflow.ExecuteActivity(ctx, ExtractMetadataActivity, downloadResult).Get(ctx, &metadataResult); err != nil {
+		return fail(err)
+	}
+
+	// 3. Transcode video
+	var transcodeResult TranscodeResult
+	if err := workflow.ExecuteActivity(ctx, TranscodeVideoActivity, TranscodeInput{
+		VideoID:   params.VideoID,
+		LocalPath: downloadResult.LocalPath,
+		Duration:  metadataResult.Duration,
+		FrameRate: metadataResult.FrameRate,
+		NoAudio:   metadataResult.NoAudio,
+		Version:   version,
+		Profile:   profile,
+		Reprocess: params.Reprocess,
+	}).Get(ctx, &transcodeResult); err != nil {
+		return fail(err)
+	}
+
+	// 4. Switch playback to the new streams
+	if err := workflow.ExecuteActivity(withBookkeepingOptions(ctx), ActivateStreamsActivity, transcodeResult).Get(ctx, nil); err != nil {
+		return fail(err)
+	}
+
+	// 5. Cleanup temporary files
+	cleanupDirs := []string{downloadResult.WorkDir, transcodeResult.OutputDirectory}
+	if err := workflow.ExecuteActivity(ctx, CleanupActivity, cleanupDirs).Get(ctx, nil); err != nil {
+		logger.Warn("Cleanup failed", "error", err)
+		// Non-critical error, continue
+	}
+
+	// Update video status to "completed"
+	if !params.Reprocess {
+		if err := updateVideoStatus(ctx, params.VideoID, database.StateCompleted, ""); err != nil {
+			return fail(err)
+		}
+	}
+	finishTranscodeJob(ctx, database.JobSucceeded, "")
+	emitEvent(ctx, database.EventVideoReady, params, database.StateCompleted, "")
+
+	logger.Info("Transcoding workflow completed")
+	return "Transcoding completed for " + params.VideoID, nil
+}
+
+// withBookkeepingOptions applies the short timeouts used for database-only activities
+func withBookkeepingOptions(ctx workflow.Context) workflow.Context {
+	return workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		StartToCloseTimeout: 5 * time.Second,
+		RetryPolicy: &temporal.RetryPolicy{
+			InitialInterval:    time.Second,
+			BackoffCoefficient: 2.0,
+			MaximumInterval:    10 * time.Second,
+			MaximumAttempts:    5,
+		},
+	})
+}
+
+// Helper function to record the outcome of the job, logging failures since the
+// workflow result does not depend on it
+func finishTranscodeJob(ctx workflow.Context, status, errMsg string) {
+	err := workflow.ExecuteActivity(withBookkeepingOptions(ctx), FinishTranscodeJobActivity, JobOutcome{
+		Status: status,
+		Error:  errMsg,
+	}).Get(ctx, nil)
+	if err != nil {
+		logging.WorkflowLogger(ctx).Warn("Failed to record transcode job outcome", "error", err)
+	}
+}
+
+// Helper function to notify webhook subscribers of a lifecycle event, logging
+// failures since the workflow result does not depend on it
+func emitEvent(ctx workflow.Context, eventType string, params TranscodeParams, state, errMsg string) {
+	// A reprocess never changes the state of the video
+	if params.Reprocess {
+		state = database.StateCompleted
+	}
+
+	err := webhooks.DispatchFromWorkflow(ctx, webhooks.Event{
+		Type: eventType,
+		Data: webhooks.EventData{
+			VideoID:   params.VideoID,
+			State:     state,
+			Profile:   params.Profile,
+			Reprocess: params.Reprocess,
+			Error:     errMsg,
+		},
+	})
+	if err != nil {
+		logging.WorkflowLogger(ctx).Warn("Failed to dispatch webhook event", "event", eventType, "error", err)
+	}
+}
+
+// JobOutcome describes how a transcode job ended
+type JobOutcome struct {
+	Status string
+	Error  string
+}
+
+// StartTranscodeJobActivity records the start of the current workflow run
+func StartTranscodeJobActivity(ctx context.Context, params TranscodeParams) error {
+	deps := GetDependencies(ctx)
+	info := activity.GetInfo(ctx)
+
+	return deps.DB.StartTranscodeJob(ctx, &database.TranscodeJob{
+		VideoID:    params.VideoID,
+		WorkflowID: info.WorkflowExecution.ID,
+		RunID:      info.WorkflowExecution.RunID,
+		Profile:    params.Profile,
+	})
+}
+
+// LoadProfileActivity retrieves the transcoding profile of the current run.
+// Missing profiles are not retried since they cannot appear later.
+func LoadProfileActivity(ctx context.Context, name string) (*database.Profile, error) {
+	deps := GetDependencies(ctx)
+
+	profile, err := deps.DB.GetProfile(ctx, name)
+	if errors.Is(err, database.ErrProfileNotFound) {
+		return nil, temporal.NewNonRetryableApplicationError(err.Error(), "ProfileNotFound", err)
+	}
+
+	return profile, err
+}
+
+// AllocateStreamVersionActivity returns the stream version the current run writes to
+func AllocateStreamVersionActivity(ctx context.Context, videoID string) (int, error) {
+	deps := GetDependencies(ctx)
+	return deps.DB.NextStreamVersion(ctx, videoID)
+}
+
+// ActivateStreamsActivity records the transcoded streams and makes them the video's active set
+func ActivateStreamsActivity(ctx context.Context, result TranscodeResult) error {
+	deps := GetDependencies(ctx)
+
+	streams := make([]*database.VideoStream, 0, len(result.Streams))
+	for _, info := range result.Streams {
+		streams = append(streams, &database.VideoStream{
+			ID:          fmt.Sprintf("%s-%d-%s", result.VideoID, result.Version, info.Variant),
+			Resolution:  info.Resolution,
+			Bitrate:     info.Bitrate,
+			Codec:       info.Codec,
+			Format:      info.Format,
+			Path:        info.Path,
+			Size:        info.Size,
+			SegmentSize: info.SegmentSize,
+			CreatedAt:   time.Now(),
+		})
+	}
+
+	return deps.DB.ActivateStreams(ctx, database.StreamSet{
+		VideoID:   result.VideoID,
+		Version:   result.Version,
+		Profile:   result.Profile,
+		Packaging: result.Packaging,
+		Streams:   streams,
+	})
+}
+
+// FinishTranscodeJobActivity records the final status of the current workflow run
+func FinishTranscodeJobActivity(ctx context.Context, outcome JobOutcome) error {
+	deps := GetDependencies(ctx)
+	info := activity.GetInfo(ctx)
+
+	return deps.DB.FinishTranscodeJob(ctx, info.WorkflowExecution.ID, info.WorkflowExecution.RunID, outcome.Status, outcome.Error)
+}
+
+// recordAttempt stores the current attempt of the running activity on the job
+func recordAttempt(ctx context.Context) {
+	deps := GetDependencies(ctx)
+	info := activity.GetInfo(ctx)
+
+	err := deps.DB.RecordJobAttempt(ctx, info.WorkflowExecution.ID, info.WorkflowExecution.RunID, info.ActivityType.Name, info.Attempt)
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to record activity attempt", "error", err)
+	}
+}
+
+// Helper function to update video status
+func updateVideoStatus(ctx workflow.Context, videoID, status, errMsg string) error {
+	return workflow.ExecuteActivity(withBookkeepingOptions(ctx), UpdateVideoStatusActivity, StatusUpdate{
+		VideoID: videoID,
+		Status:  status,
+		Error:   errMsg,
+	}).Get(ctx, nil)
+}
+
+// StatusUpdate describes a processing state change requested by the workflow
+type StatusUpdate struct {
+	VideoID string
+	Status  string
+	Error   string
+}
+
+// UpdateVideoStatusActivity moves a video to a new processing state
+func UpdateVideoStatusActivity(ctx context.Context, update StatusUpdate) error {
+	return transitionVideo(ctx, update.VideoID, update.Status, update.Error)
+}
+
+// transitionVideo applies a state transition tagged with the current workflow execution.
+// Illegal transitions are not retried since they cannot succeed later.
+func transitionVideo(ctx context.Context, videoID, status, errMsg string) error {
+	deps := GetDependencies(ctx)
+	info := activity.GetInfo(ctx)
+
+	err := deps.DB.TransitionVideo(ctx, database.Transition{
+		VideoID:    videoID,
+		To:         status,
+		Error:      errMsg,
+		WorkflowID: info.WorkflowExecution.ID,
+		RunID:      info.WorkflowExecution.RunID,
+	})
+	if errors.Is(err, database.ErrIllegalTransition) {
+		return temporal.NewNonRetryableApplicationError(err.Error(), "IllegalTransition", err)
+	}
+	if err == nil {
+		publishState(ctx, videoID, status, errMsg)
+	}
+
+	return err
+}
+
+// DownloadResult stores the result of downloading a video
+type DownloadResult struct {
+	VideoID      string
+	WorkDir      string
+	LocalPath    string
+	OriginalPath string
+	Reprocess    bool
+}
+
+// DownloadVideoActivity downloads the video from storage
+func DownloadVideoActivity(ctx context.Context, params TranscodeParams) (DownloadResult, error) {
+	ctx = logging.WithVideoID(ctx, params.VideoID)
+	deps := GetDependencies(ctx)
+	recordAttempt(ctx)
+
+	// Update video status
+	if !params.Reprocess {
+		if err := transitionVideo(ctx, params.VideoID, database.StateDownloading, ""); err != nil {
+			return DownloadResult{}, err
+		}
+	}
+
+	// Create a temporary directory for processing
+	tempDir, err := os.MkdirTemp("", "transcode-"+params.VideoID)
+	if err != nil {
+		return DownloadResult{}, err
+	}
+
+	// Determine local path
+	filename := filepath.Base(params.ObjectKey)
+	localPath := filepath.Join(tempDir, filename)
+
+	// Download the file
+	err = deps.Storage.DownloadFile(ctx, params.ObjectKey, localPath)
+	if err != nil {
+		return DownloadResult{}, err
+	}
+
+	return DownloadResult{
+		VideoID:      params.VideoID,
+		WorkDir:      tempDir,
+		LocalPath:    localPath,
+		OriginalPath: params.ObjectKey,
+		Reprocess:    params.Reprocess,
+	}, nil
+}
+
+// MetadataResult stores the result of metadata extraction
+type MetadataResult struct {
+	VideoID   string
+	Duration  float64
+	FrameRate float64
+	// NoAudio marks inputs without an audio stream. Results recorded before it
+	// existed decode as false and keep encoding audio.
+	NoAudio bool
+}
+
+// ExtractMetadataActivity extracts metadata from the video
+func ExtractMetadataActivity(ctx context.Context, download DownloadResult) (MetadataResult, error) {
+	ctx = logging.WithVideoID(ctx, download.VideoID)
+	deps := GetDependencies(ctx)
+	recordAttempt(ctx)
+
+	// Use FFmpeg to get media info
+	info, err := deps.FFmpeg.GetMediaInfo(ctx, download.LocalPath)
+	if err != nil {
+		return MetadataResult{}, err
+	}
+
+	// Extract duration and frame rate
+	var duration, frameRate float64
+	if durationStr, ok := info["duration"]; ok {
+		duration, _ = strconv.ParseFloat(durationStr, 64)
+	}
+	if frameRateStr, ok := info["frame_rate"]; ok {
+		frameRate, _ = strconv.ParseFloat(frameRateStr, 64)
+	}
+
+	// Update video status
+	if !download.Reprocess {
+		err = transitionVideo(ctx, download.VideoID, database.StateAnalyzing, "")
+		if err != nil {
+			return MetadataResult{}, err
+		}
+	}
+
+	return MetadataResult{
+		VideoID:   download.VideoID,
+		Duration:  duration,
+		FrameRate: frameRate,
+		NoAudio:   info["audio_codec"] == "",
+	}, nil
+}
+
+// TranscodeInput contains the parameters of the transcoding activity
+type TranscodeInput struct {
+	VideoID   string
+	LocalPath string
+	Duration  float64
+	FrameRate float64
+	NoAudio   bool
+	Version   int
+	Profile   database.Profile
+	Reprocess bool
+}
+
+// TranscodeResult stores the result of transcoding
+type TranscodeResult struct {
+	VideoID         string
+	Version         int
+	Profile         string
+	Packaging       string
+	OutputDirectory string
+	Streams         []StreamInfo
+}
+
+// StreamInfo contains information about a transcoded stream
+type StreamInfo struct {
+	Variant     string
+	Resolution  string
+	Bitrate     string
+	Codec       string
+	Format      string
+	Path        string
+	Size        int64
+	SegmentSize int
+}
+
+// TranscodeVideoActivity transcodes the video into the formats of its profile and
+// uploads the outputs under the version prefix of the run
+func TranscodeVideoActivity(ctx context.Context, input TranscodeInput) (TranscodeResult, error) {
+	ctx = logging.WithVideoID(ctx, input.VideoID)
+	deps := GetDependencies(ctx)
+	recordAttempt(ctx)
+
+	// Make sure the video exists
+	if _, err := deps.DB.GetVideo(ctx, input.VideoID); err != nil {
+		return TranscodeResult{}, err
+	}
+
+	// Update video status
+	if !input.Reprocess {
+		err := transitionVideo(ctx, input.VideoID, database.StateTranscoding, "")
+		if err != nil {
+			return TranscodeResult{}, err
+		}
+	}
+
+	// Create a temporary directory for transcoded files
+	outputDir, err := os.MkdirTemp("", "output-"+input.VideoID)
+	if err != nil {
+		return TranscodeResult{}, err
+	}
+
+	profile := input.Profile
+	opts := ffmpeg.EncodeOptions{
+		SegmentDuration: profile.SegmentDuration,
+		AudioCodec:      profile.Audio.Codec,
+		AudioBitrate:    profile.Audio.Bitrate,
+		AudioChannels:   profile.Audio.Channels,
+		FrameRate:       input.FrameRate,
+		HasAudio:        !input.NoAudio,
+	}
+	for _, r := range profile.Renditions {
+		opts.Renditions = append(opts.Renditions, ffmpeg.Resolution{
+			Width:   r.Width,
+			Height:  r.Height,
+			Bitrate: r.Bitrate,
+			Codec:   r.Codec,
+		})
+	}
+
+	prefix := database.StreamPrefix(input.VideoID, input.Version)
+	segmentFilename := input.VideoID
+	var streams []StreamInfo
+
+	cmaf := profile.Packaging == database.PackagingCMAF
+
+	// Report progress across every ffmpeg run of the transcode
+	runs := len(profile.Downloads)
+	if cmaf {
+		runs++
+	} else {
+		if profile.HasFormat(database.FormatHLS) {
+			runs++
+		}
+		if profile.HasFormat(database.FormatDASH) {
+			runs++
+		}
+	}
+	progress := newProgressTracker(ctx, deps.Events, input, runs)
+
+	// Package one set of segments for both HLS and DASH
+	if cmaf {
+		cmafDir, err := makeFormatDir(outputDir, database.FormatCMAF)
+		if err != nil {
+			return TranscodeResult{}, err
+		}
+
+		start := time.Now()
+		if err := deps.FFmpeg.TranscodeToCMAF(progress.encode(ctx), input.LocalPath, cmafDir, opts); err != nil {
+			recordFFmpegFailure(ctx, database.FormatCMAF, err)
+			return TranscodeResult{}, err
+		}
+		observeTranscode(profile.Name, database.FormatCMAF, ladderRendition, start)
+
+		for i, res := range opts.Renditions {
+			streams = append(streams, StreamInfo{
+				Variant:     fmt.Sprintf("%s-v%d", database.FormatCMAF, i),
+				Resolution:  fmt.Sprintf("%dx%d", res.Width, res.Height),
+				Bitrate:     res.Bitrate,
+				Codec:       codecName(res.Codec),
+				Format:      database.FormatCMAF,
+				Path:        prefix + "/cmaf/" + ffmpeg.CMAFPlaylistName(i),
+				Size:        variantSize(cmafDir, fmt.Sprintf("init_v%d", i)) + variantSize(cmafDir, fmt.Sprintf("chunk_v%d", i)),
+				SegmentSize: targetDuration(ctx, filepath.Join(cmafDir, ffmpeg.CMAFPlaylistName(i)), profile.SegmentDuration),
+			})
+		}
+	}
+
+	// Transcode to HLS
+	if !cmaf && profile.HasFormat(database.FormatHLS) {
+		hlsDir, err := makeFormatDir(outputDir, database.FormatHLS)
+		if err != nil {
+			return TranscodeResult{}, err
+		}
+
+		start := time.Now()
+		if err := deps.FFmpeg.TranscodeToHLS(progress.encode(ctx), input.LocalPath, hlsDir, segmentFilename, opts); err != nil {
+			recordFFmpegFailure(ctx, database.FormatHLS, err)
+			return TranscodeResult{}, err
+		}
+		observeTranscode(profile.Name, database.FormatHLS, ladderRendition, start)
+
+		for i, res := range opts.Renditions {
+			variantName := fmt.Sprintf("v%d", i)
+			playlistFile := fmt.Sprintf("%s_%s.m3u8", segmentFilename, variantName)
+
+			streams = append(streams, StreamInfo{
+				Variant:     database.FormatHLS + "-" + variantName,
+				Resolution:  fmt.Sprintf("%dx%d", res.Width, res.Height),
+				Bitrate:     res.Bitrate,
+				Codec:       codecName(res.Codec),
+				Format:      database.FormatHLS,
+				Path:        prefix + "/hls/" + playlistFile,
+				Size:        variantSize(hlsDir, segmentFilename+"_"+variantName),
+				SegmentSize: targetDuration(ctx, filepath.Join(hlsDir, playlistFile), profile.SegmentDuration),
+			})
+		}
+	}
+
+	// Transcode to DASH
+	if !cmaf && profile.HasFormat(database.FormatDASH) {
+		dashDir, err := makeFormatDir(outputDir, database.FormatDASH)
+		if err != nil {
+			return TranscodeResult{}, err
+		}
+
+		start := time.Now()
+		if err := deps.FFmpeg.TranscodeToDASH(progress.encode(ctx), input.LocalPath, dashDir, opts); err != nil {
+			recordFFmpegFailure(ctx, database.FormatDASH, err)
+			return TranscodeResult{}, err
+		}
+		observeTranscode(profile.Name, database.FormatDASH, ladderRendition, start)
+
+		// All representations share the segment timeline
+		segmentSize := targetDuration(ctx, filepath.Join(dashDir, "manifest.mpd"), profile.SegmentDuration)
+
+		for i, res := range opts.Renditions {
+			streams = append(streams, StreamInfo{
+				Variant:     fmt.Sprintf("%s-v%d", database.FormatDASH, i),
+				Resolution:  fmt.Sprintf("%dx%d", res.Width, res.Height),
+				Bitrate:     res.Bitrate,
+				Codec:       codecName(res.Codec),
+				Format:      database.FormatDASH,
+				Path:        prefix + "/dash/manifest.mpd",
+				Size:        variantSize(dashDir, fmt.Sprintf("init_v%d", i)) + variantSize(dashDir, fmt.Sprintf("chunk_v%d", i)),
+				SegmentSize: segmentSize,
+			})
+		}
+	}
+
+	// Transcode progressive MP4 downloads
+	if len(profile.Downloads) > 0 {
+		mp4Dir, err := makeFormatDir(outputDir, database.FormatMP4)
+		if err != nil {
+			return TranscodeResult{}, err
+		}
+
+		for i, r := range profile.Downloads {
+			res := ffmpeg.Resolution{
+				Width:   r.Width,
+				Height:  r.Height,
+				Bitrate: r.Bitrate,
+				Codec:   r.Codec,
+			}
+			filename := database.MP4FileName(r)
+			localFile := filepath.Join(mp4Dir, filename)
+
+			start := time.Now()
+			if err := deps.FFmpeg.TranscodeToMP4(progress.encode(ctx), input.LocalPath, localFile, res, opts); err != nil {
+				recordFFmpegFailure(ctx, database.FormatMP4, err)
+				return TranscodeResult{}, err
+			}
+			observeTranscode(profile.Name, database.FormatMP4, strings.TrimSuffix(filename, ".mp4"), start)
+
+			var size int64
+			if info, err := os.Stat(localFile); err == nil {
+				size = info.Size()
+			}
+
+			streams = append(streams, StreamInfo{
+				Variant:    fmt.Sprintf("%s-v%d", database.FormatMP4, i),
+				Resolution: fmt.Sprintf("%dx%d", res.Width, res.Height),
+				Bitrate:    res.Bitrate,
+				Codec:      codecName(res.Codec),
+				Format:     database.FormatMP4,
+				Path:       prefix + "/mp4/" + filename,
+				Size:       size,
+			})
+		}
+	}
+
+	progress.finish()
+
+	// Extract a poster frame
+	if profile.Thumbnails {
+		thumbnailDir, err := makeFormatDir(outputDir, "thumbnails")
+		if err != nil {
+			return TranscodeResult{}, err
+		}
+
+		poster := filepath.Join(thumbnailDir, "poster.jpg")
+		if err := deps.FFmpeg.GenerateThumbnail(ctx, input.LocalPath, poster, input.Duration/10, 640); err != nil {
+			// Thumbnails are optional, keep the encoded streams
+			metrics.FFmpegFailures.WithLabelValues("thumbnail").Inc()
+			slog.WarnContext(ctx, "Failed to generate thumbnail", "error", err)
+		}
+	}
+
+	// Upload transcoded files to storage
+	if _, err := deps.Storage.UploadDirectory(ctx, outputDir, prefix); err != nil {
+		return TranscodeResult{}, err
+	}
+
+	return TranscodeResult{
+		VideoID:         input.VideoID,
+		Version:         input.Version,
+		Profile:         profile.Name,
+		Packaging:       profile.Packaging,
+		OutputDirectory: outputDir,
+		Streams:         streams,
+	}, nil
+}
+
+// targetDuration returns the longest segment duration of an encoded playlist or manifest,
+// falling back to the requested duration when it cannot be read
+func targetDuration(ctx context.Context, path string, requested int) int {
+	var duration int
+	var err error
+	if strings.HasSuffix(path, ".mpd") {
+		duration, err = ffmpeg.ManifestTargetDuration(path)
+	} else {
+		duration, err = ffmpeg.PlaylistTargetDuration(path)
+	}
+
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to read target duration, using the requested duration", "path", path, "requested", requested, "error", err)
+		return requested
+	}
+	return duration
+}
+
+// Helper function to name the codec of a rendition, where empty means H.264
+func codecName(codec string) string {
+	if codec == "" {
+		return ffmpeg.CodecH264
+	}
+	return codec
+}
+
+// Helper function to create the output subdirectory of a format
+func makeFormatDir(outputDir, name string) (string, error) {
+	dir := filepath.Join(outputDir, name)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// Helper function to sum the size of a variant's playlist and segments
+func variantSize(outputDir, variantPrefix string) int64 {
+	matches, _ := filepath.Glob(filepath.Join(outputDir, variantPrefix+"[._]*"))
+
+	var size int64
+	for _, match := range matches {
+		if info, err := os.Stat(match); err == nil {
+			size += info.Size()
+		}
+	}
+	return size
+}
+
+// ladderRendition labels encodes that produce every rendition of the ladder in one run
+const ladderRendition = "ladder"
+
+// observeTranscode records the encoding time of a format started at start
+func observeTranscode(profile, format, rendition string, start time.Time) {
+	metrics.TranscodeDuration.WithLabelValues(profile, format, rendition).Observe(time.Since(start).Seconds())
+}
+
+// recordFFmpegFailure counts a failed ffmpeg run of an operation and stores its exit
+// code and stderr on the job
+func recordFFmpegFailure(ctx context.Context, operation string, err error) {
+	metrics.FFmpegFailures.WithLabelValues(operation).Inc()
+
+	var execErr *ffmpeg.ExecError
+	if !errors.As(err, &execErr) {
+		return
+	}
+
+	deps := GetDependencies(ctx)
+	info := activity.GetInfo(ctx)
+
+	if err := deps.DB.RecordJobFFmpegFailure(ctx, info.WorkflowExecution.ID, info.WorkflowExecution.RunID, execErr.ExitCode, execErr.StderrTail); err != nil {
+		slog.WarnContext(ctx, "Failed to record ffmpeg failure", "error", err)
+	}
+}
+
+// CleanupActivity removes the temporary directories of a run
+func CleanupActivity(ctx context.Context, dirs []string) error {
+	for _, dir := range dirs {
+		if dir == "" {
+			continue
+		}
+		if err := os.RemoveAll(dir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package transcoder
+
+import (
+	"context"
+
+	"github.com/falcon/backend/internal/database"
+	"github.com/falcon/backend/internal/events"
+	"github.com/falcon/backend/internal/ffmpeg"
+	"github.com/falcon/backend/internal/storage"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/worker"
+)
+
+// NewWorker creates a worker on the transcoder task queue with the workflow and its
+// activities registered. Activities find their dependencies in the worker context.
+func NewWorker(temporalClient client.Client, deps *ActivityDependencies) worker.Worker {
+	w := worker.New(temporalClient, TaskQueue, worker.Options{
+		BackgroundActivityContext: context.WithValue(
+			context.Background(),
+			"dependencies",
+			deps,
+		),
+	})
+
+	// Register workflows and activities
+	w.RegisterWorkflow(TranscodeWorkflow)
+	w.RegisterActivity(DownloadVideoActivity)
+	w.RegisterActivity(ExtractMetadataActivity)
+	w.RegisterActivity(TranscodeVideoActivity)
+	w.RegisterActivity(AllocateStreamVersionActivity)
+	w.RegisterActivity(LoadProfileActivity)
+	w.RegisterActivity(ActivateStreamsActivity)
+	w.RegisterActivity(CleanupActivity)
+	w.RegisterActivity(UpdateVideoStatusActivity)
+	w.RegisterActivity(StartTranscodeJobActivity)
+	w.RegisterActivity(FinishTranscodeJobActivity)
+
+	return w
+}
+
+// ActivityDependencies holds references to services needed by activities
+type ActivityDependencies struct {
+	Storage *storage.StorageService
+	DB      *database.Database
+	FFmpeg  *ffmpeg.FFmpeg
+	Events  *events.Publisher
+}
+
+// GetDependencies extracts dependencies from the context
+func GetDependencies(ctx context.Context) *ActivityDependencies {
+	return ctx.Value("dependencies").(*ActivityDependencies)
+}
@@ -0,0 +1,649 @@
+package uploader
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/falcon/backend/internal/database"
+	"github.com/falcon/backend/internal/dedupe"
+	"github.com/falcon/backend/internal/id"
+	"github.com/falcon/backend/internal/logging"
+	"github.com/falcon/backend/internal/storage"
+	"github.com/falcon/backend/internal/tracing"
+	"github.com/falcon/backend/internal/validation"
+	"github.com/falcon/backend/internal/webhooks"
+	"github.com/gorilla/mux"
+	enumspb "go.temporal.io/api/enums/v1"
+	"go.temporal.io/api/serviceerror"
+	"go.temporal.io/sdk/client"
+)
+
+// VideoUploadResponse represents the response to a video upload request
+type VideoUploadResponse struct {
+	VideoID     string `json:"video_id"`
+	Slug        string `json:"slug"`
+	Title       string `json:"title"`
+	Filename    string `json:"filename"`
+	Size        int64  `json:"size"`
+	ContentType string `json:"content_type"`
+	ContentHash string `json:"content_hash"`
+	Profile     string `json:"profile"`
+	DuplicateOf string `json:"duplicate_of,omitempty"`
+	Status      string `json:"status"`
+	Message     string `json:"message"`
+	Timestamp   string `json:"timestamp"`
+}
+
+// UploadHandler handles video upload requests
+type UploadHandler struct {
+	storageService *storage.StorageService
+	db             *database.Database
+	temporalClient client.Client
+	validator      *validation.Validator
+	dedupePolicy   string
+	maxUploadSize  int64
+}
+
+// NewUploadHandler creates a new upload handler. Request bodies larger than
+// maxUploadSize bytes are rejected, 0 disables the limit.
+func NewUploadHandler(storageService *storage.StorageService, db *database.Database, temporalClient client.Client, validator *validation.Validator, dedupePolicy string, maxUploadSize int64) *UploadHandler {
+	return &UploadHandler{
+		storageService: storageService,
+		db:             db,
+		temporalClient: temporalClient,
+		validator:      validator,
+		dedupePolicy:   dedupePolicy,
+		maxUploadSize:  maxUploadSize,
+	}
+}
+
+// UploadVideo handles video file uploads
+func (h *UploadHandler) UploadVideo(w http.ResponseWriter, r *http.Request) {
+	// Set response headers
+	w.Header().Set("Content-Type", "application/json")
+
+	if h.maxUploadSize > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, h.maxUploadSize)
+	}
+
+	// Parse multipart form, keeping up to 500MB in memory
+	if err := r.ParseMultipartForm(500 << 20); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			handleError(w, r, "Upload too large", err, http.StatusRequestEntityTooLarge)
+			return
+		}
+		handleError(w, r, "Failed to parse form", err, http.StatusBadRequest)
+		return
+	}
+
+	// Get the file from the form
+	file, header, err := r.FormFile("video")
+	if err != nil {
+		handleError(w, r, "Failed to get video file", err, http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	// Read user-supplied metadata
+	title := strings.TrimSpace(r.FormValue("title"))
+	if title == "" {
+		title = strings.TrimSuffix(header.Filename, filepath.Ext(header.Filename))
+	}
+
+	metadata, err := parseMetadata(r.FormValue("metadata"))
+	if err != nil {
+		handleError(w, r, "Invalid metadata", err, http.StatusBadRequest)
+		return
+	}
+
+	dedupePolicy, err := parseDedupePolicy(r.FormValue("dedupe"), h.dedupePolicy)
+	if err != nil {
+		handleError(w, r, "Invalid dedupe policy", err, http.StatusBadRequest)
+		return
+	}
+
+	profile := strings.TrimSpace(r.FormValue("profile"))
+	if profile == "" {
+		profile = database.DefaultProfile
+	}
+	if _, err := h.db.GetProfile(r.Context(), profile); err != nil {
+		if errors.Is(err, database.ErrProfileNotFound) {
+			handleError(w, r, "Unknown profile", err, http.StatusBadRequest)
+			return
+		}
+		handleError(w, r, "Failed to get profile", err, http.StatusInternalServerError)
+		return
+	}
+
+	// Generate a unique filename
+	videoID := id.NewVideoID()
+	tracing.SetVideoID(r.Context(), videoID)
+	r = r.WithContext(logging.WithVideoID(r.Context(), videoID))
+	ext := filepath.Ext(header.Filename)
+	filename := videoID + ext
+	tempFile := filepath.Join(os.TempDir(), filename)
+
+	// Save to temporary file
+	out, err := os.Create(tempFile)
+	if err != nil {
+		handleError(w, r, "Failed to create temporary file", err, http.StatusInternalServerError)
+		return
+	}
+	defer out.Close()
+	defer os.Remove(tempFile) // Clean up temp file when done
+
+	// Copy file content to temporary file, hashing it on the way
+	hasher := sha256.New()
+	size, err := io.Copy(io.MultiWriter(out, hasher), file)
+	if err != nil {
+		handleError(w, r, "Failed to save file", err, http.StatusInternalServerError)
+		return
+	}
+	out.Close() // Close now to ensure file is fully written
+	contentHash := hex.EncodeToString(hasher.Sum(nil))
+
+	// Validate the file content instead of trusting the client-supplied type
+	result, err := h.validator.Validate(r.Context(), tempFile)
+	if err != nil {
+		var rejection *validation.Rejection
+		if errors.As(err, &rejection) {
+			slog.InfoContext(r.Context(), "Rejected upload", "filename", header.Filename, "reason", rejection)
+			handleRejection(w, rejection)
+			return
+		}
+		handleError(w, r, "Failed to validate file", err, http.StatusInternalServerError)
+		return
+	}
+	contentType := result.ContentType
+
+	// Check for an earlier upload of the same content
+	var existing *database.Video
+	if dedupePolicy != dedupe.None {
+		existing, err = h.db.FindVideoByContentHash(r.Context(), contentHash)
+		if err != nil && !errors.Is(err, database.ErrVideoNotFound) {
+			handleError(w, r, "Failed to check for duplicates", err, http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if existing != nil && dedupePolicy == dedupe.ReturnExisting {
+		writeExisting(w, existing)
+		return
+	}
+
+	// Renditions can only be shared once the existing video finished transcoding with the
+	// requested profile, otherwise fall through to a normal upload and encode
+	if existing != nil && dedupePolicy == dedupe.ReuseRenditions && existing.ProcessingState == database.StateCompleted &&
+		existing.Profile == profile {
+		now := time.Now()
+		video := &database.Video{
+			ID:              videoID,
+			Slug:            id.NewSlug(),
+			Title:           title,
+			Description:     strings.TrimSpace(r.FormValue("description")),
+			Tags:            parseTags(r.MultipartForm.Value["tags"]),
+			Metadata:        metadata,
+			OriginalName:    header.Filename,
+			OriginalPath:    existing.OriginalPath,
+			ProcessingState: database.StateCompleted,
+			Duration:        existing.Duration,
+			Size:            size,
+			ContentType:     contentType,
+			ContentHash:     contentHash,
+			RenditionsFrom:  existing.StorageID(),
+			ActiveVersion:   existing.ActiveVersion,
+			Profile:         existing.Profile,
+			Packaging:       existing.Packaging,
+			CreatedAt:       now,
+			UpdatedAt:       now,
+		}
+
+		if err := h.db.CreateReusedVideo(r.Context(), video, existing.ID); err != nil {
+			handleError(w, r, "Failed to create video", err, http.StatusInternalServerError)
+			return
+		}
+		h.emit(r.Context(), database.EventVideoUploaded, video)
+		h.emit(r.Context(), database.EventVideoReady, video)
+
+		json.NewEncoder(w).Encode(VideoUploadResponse{
+			VideoID:     videoID,
+			Slug:        video.Slug,
+			Title:       title,
+			Filename:    header.Filename,
+			Size:        size,
+			ContentType: contentType,
+			ContentHash: contentHash,
+			Profile:     video.Profile,
+			DuplicateOf: existing.ID,
+			Status:      database.StateCompleted,
+			Message:     "Video was already transcoded, reusing the existing renditions",
+			Timestamp:   now.Format(time.RFC3339),
+		})
+		return
+	}
+
+	// Upload to storage
+	objectKey := fmt.Sprintf("uploads/%s/%s", videoID, filename)
+	_, err = h.storageService.UploadFile(r.Context(), tempFile, objectKey)
+	if err != nil {
+		handleError(w, r, "Failed to upload to storage", err, http.StatusInternalServerError)
+		return
+	}
+
+	// Create the video record so it is visible before a worker picks it up
+	now := time.Now()
+	video := &database.Video{
+		ID:              videoID,
+		Slug:            id.NewSlug(),
+		Title:           title,
+		Description:     strings.TrimSpace(r.FormValue("description")),
+		Tags:            parseTags(r.MultipartForm.Value["tags"]),
+		Metadata:        metadata,
+		OriginalName:    header.Filename,
+		OriginalPath:    objectKey,
+		ProcessingState: database.StateUploaded,
+		Profile:         profile,
+		Size:            size,
+		ContentType:     contentType,
+		ContentHash:     contentHash,
+		DedupeOriginal:  dedupePolicy != dedupe.None && existing == nil,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}
+
+	err = h.db.CreateVideo(r.Context(), video)
+	if errors.Is(err, database.ErrDuplicateContent) {
+		// A concurrent upload of the same content was created since the lookup
+		existing, err = h.db.FindVideoByContentHash(r.Context(), contentHash)
+		if err != nil && !errors.Is(err, database.ErrVideoNotFound) {
+			handleError(w, r, "Failed to check for duplicates", err, http.StatusInternalServerError)
+			return
+		}
+		if existing != nil && dedupePolicy == dedupe.ReturnExisting {
+			if err := h.storageService.DeleteObject(r.Context(), objectKey); err != nil {
+				slog.WarnContext(r.Context(), "Failed to delete duplicate upload", "object_key", objectKey, "error", err)
+			}
+			writeExisting(w, existing)
+			return
+		}
+
+		// Renditions cannot be reused before the original finished, or the original
+		// failed, so the upload is encoded on its own
+		video.DedupeOriginal = false
+		err = h.db.CreateVideo(r.Context(), video)
+	}
+	if err != nil {
+		handleError(w, r, "Failed to create video", err, http.StatusInternalServerError)
+		return
+	}
+	h.emit(r.Context(), database.EventVideoUploaded, video)
+
+	// Create response
+	response := VideoUploadResponse{
+		VideoID:     videoID,
+		Slug:        video.Slug,
+		Title:       title,
+		Filename:    header.Filename,
+		Size:        size,
+		ContentType: contentType,
+		ContentHash: contentHash,
+		Profile:     profile,
+		Status:      database.StateUploaded,
+		Message:     "Video uploaded successfully and scheduled for transcoding",
+		Timestamp:   time.Now().Format(time.RFC3339),
+	}
+
+	// Start transcoding workflow
+	if err := h.scheduleTranscode(r.Context(), video, video.Profile); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to start transcoding workflow", "error", err)
+		response.Status = database.StateFailedToSchedule
+		response.Message = "Video uploaded successfully but transcoding could not be scheduled; it will be retried"
+		w.WriteHeader(http.StatusAccepted)
+	}
+
+	// Return success response
+	json.NewEncoder(w).Encode(response)
+}
+
+// RetrySchedule starts the transcoding workflow for a video that failed to schedule
+func (h *UploadHandler) RetrySchedule(w http.ResponseWriter, r *http.Request) {
+	videoID := mux.Vars(r)["videoId"]
+
+	// Set response headers
+	w.Header().Set("Content-Type", "application/json")
+
+	video, err := h.db.GetVideo(r.Context(), videoID)
+	if err != nil {
+		if errors.Is(err, database.ErrVideoNotFound) {
+			handleError(w, r, "Video not found", err, http.StatusNotFound)
+			return
+		}
+		handleError(w, r, "Failed to get video", err, http.StatusInternalServerError)
+		return
+	}
+
+	if video.ProcessingState != database.StateFailedToSchedule {
+		handleError(w, r, fmt.Sprintf("Video is in state %s and does not need rescheduling", video.ProcessingState), nil, http.StatusConflict)
+		return
+	}
+
+	if err := h.scheduleTranscode(r.Context(), video, video.Profile); err != nil {
+		if isAlreadyRunning(err) {
+			handleError(w, r, "A transcoding workflow is already running for this video", err, http.StatusConflict)
+			return
+		}
+		handleError(w, r, "Failed to start transcoding workflow", err, http.StatusServiceUnavailable)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{
+		"video_id": videoID,
+		"status":   database.StateUploaded,
+		"message":  "Video scheduled for transcoding",
+	})
+}
+
+// TranscodeRequest is the optional body of a re-transcode request
+type TranscodeRequest struct {
+	Profile string `json:"profile"`
+}
+
+// Retranscode encodes a video again from its stored original. Completed videos keep
+// serving their current streams until the new encode succeeds.
+func (h *UploadHandler) Retranscode(w http.ResponseWriter, r *http.Request) {
+	videoID := mux.Vars(r)["videoId"]
+
+	// Set response headers
+	w.Header().Set("Content-Type", "application/json")
+
+	var req TranscodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		handleError(w, r, "Invalid request body", err, http.StatusBadRequest)
+		return
+	}
+
+	video, err := h.db.GetVideo(r.Context(), videoID)
+	if err != nil {
+		if errors.Is(err, database.ErrVideoNotFound) {
+			handleError(w, r, "Video not found", err, http.StatusNotFound)
+			return
+		}
+		handleError(w, r, "Failed to get video", err, http.StatusInternalServerError)
+		return
+	}
+
+	// Without a profile the video is encoded again with the profile it last used
+	if req.Profile == "" {
+		req.Profile = video.Profile
+	}
+	if _, err := h.db.GetProfile(r.Context(), req.Profile); err != nil {
+		if errors.Is(err, database.ErrProfileNotFound) {
+			handleError(w, r, "Unknown profile", err, http.StatusBadRequest)
+			return
+		}
+		handleError(w, r, "Failed to get profile", err, http.StatusInternalServerError)
+		return
+	}
+
+	switch video.ProcessingState {
+	case database.StateCompleted:
+		err = h.startTranscode(r.Context(), video, req.Profile, true)
+	case database.StateError, database.StateFailedToSchedule:
+		err = h.scheduleTranscode(r.Context(), video, req.Profile)
+	default:
+		handleError(w, r, fmt.Sprintf("Video is in state %s and cannot be transcoded again yet", video.ProcessingState), nil, http.StatusConflict)
+		return
+	}
+
+	if err != nil {
+		if isAlreadyRunning(err) {
+			handleError(w, r, "A transcoding workflow is already running for this video", err, http.StatusConflict)
+			return
+		}
+		handleError(w, r, "Failed to start transcoding workflow", err, http.StatusServiceUnavailable)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{
+		"video_id": videoID,
+		"profile":  req.Profile,
+		"message":  "Video scheduled for transcoding",
+	})
+}
+
+// RetryFailedSchedules periodically reschedules videos stuck in failed_to_schedule
+func (h *UploadHandler) RetryFailedSchedules(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		videos, err := h.db.ListVideosByState(ctx, database.StateFailedToSchedule, 50)
+		if err != nil {
+			slog.Error("Failed to list videos to reschedule", "error", err)
+			continue
+		}
+
+		for _, video := range videos {
+			err := h.scheduleTranscode(ctx, video, video.Profile)
+			if err == nil {
+				slog.Info("Rescheduled transcoding workflow", logging.KeyVideoID, video.ID)
+				continue
+			}
+
+			slog.Warn("Retry of transcoding workflow failed", logging.KeyVideoID, video.ID, "error", err)
+			if isTemporalUnavailable(err) {
+				// The other videos would fail the same way, wait for the next tick
+				break
+			}
+		}
+	}
+}
+
+// errTranscodeRunning is returned when a video already has an open transcoding workflow
+var errTranscodeRunning = errors.New("a transcoding workflow is already running for this video")
+
+// scheduleTranscode starts the transcoding workflow for a stored video, recording
+// failed_to_schedule on the video when the workflow cannot be started. The state
+// is left alone when a workflow of the video is already open, since that run owns it.
+func (h *UploadHandler) scheduleTranscode(ctx context.Context, video *database.Video, profile string) error {
+	if h.transcodeRunning(ctx, video.ID) {
+		return fmt.Errorf("%w: %s", errTranscodeRunning, video.ID)
+	}
+
+	if video.ProcessingState != database.StateUploaded {
+		if err := h.db.UpdateVideoStatus(ctx, video.ID, database.StateUploaded); err != nil {
+			return err
+		}
+	}
+
+	err := h.startTranscode(ctx, video, profile, false)
+	if err == nil {
+		return nil
+	}
+
+	// A run started since the check above, or the start succeeded but its
+	// response was lost, so the video is scheduled
+	var alreadyStarted *serviceerror.WorkflowExecutionAlreadyStarted
+	if errors.As(err, &alreadyStarted) {
+		return nil
+	}
+	if h.transcodeRunning(ctx, video.ID) {
+		return nil
+	}
+
+	if statusErr := h.db.UpdateVideoStatus(ctx, video.ID, database.StateFailedToSchedule); statusErr != nil {
+		slog.ErrorContext(ctx, "Failed to mark video as failed_to_schedule", logging.KeyVideoID, video.ID, "error", statusErr)
+	}
+	return err
+}
+
+// transcodeRunning reports whether the transcoding workflow of a video is open.
+// Failing to describe it counts as not running.
+func (h *UploadHandler) transcodeRunning(ctx context.Context, videoID string) bool {
+	desc, err := h.temporalClient.DescribeWorkflowExecution(ctx, transcodeWorkflowID(videoID), "")
+	if err != nil {
+		return false
+	}
+	return desc.GetWorkflowExecutionInfo().GetStatus() == enumspb.WORKFLOW_EXECUTION_STATUS_RUNNING
+}
+
+// transcodeWorkflowID returns the ID of the transcoding workflow of a video
+func transcodeWorkflowID(videoID string) string {
+	return "transcode-" + videoID
+}
+
+// isAlreadyRunning reports whether starting a transcode failed because one is open
+func isAlreadyRunning(err error) bool {
+	var alreadyStarted *serviceerror.WorkflowExecutionAlreadyStarted
+	return errors.Is(err, errTranscodeRunning) || errors.As(err, &alreadyStarted)
+}
+
+// isTemporalUnavailable reports whether an error shows that Temporal cannot be reached
+func isTemporalUnavailable(err error) bool {
+	var unavailable *serviceerror.Unavailable
+	var deadlineExceeded *serviceerror.DeadlineExceeded
+	return errors.As(err, &unavailable) || errors.As(err, &deadlineExceeded) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// startTranscode starts the transcoding workflow of a video from its stored original.
+// Only one workflow may run per video at a time.
+func (h *UploadHandler) startTranscode(ctx context.Context, video *database.Video, profile string, reprocess bool) error {
+	workflowOptions := client.StartWorkflowOptions{
+		ID:                                       transcodeWorkflowID(video.ID),
+		TaskQueue:                                "TRANSCODER_TASK_QUEUE",
+		WorkflowExecutionErrorWhenAlreadyStarted: true,
+	}
+
+	workflowParams := map[string]interface{}{
+		"videoID":     video.ID,
+		"objectKey":   video.OriginalPath,
+		"filename":    video.OriginalName,
+		"contentType": video.ContentType,
+		"profile":     profile,
+		"reprocess":   reprocess,
+	}
+
+	_, err := h.temporalClient.ExecuteWorkflow(ctx, workflowOptions, "TranscodeWorkflow", workflowParams)
+	return err
+}
+
+// writeExisting responds to a deduplicated upload with the video it duplicates
+func writeExisting(w http.ResponseWriter, existing *database.Video) {
+	json.NewEncoder(w).Encode(VideoUploadResponse{
+		VideoID:     existing.ID,
+		Slug:        existing.Slug,
+		Title:       existing.Title,
+		Filename:    existing.OriginalName,
+		Size:        existing.Size,
+		ContentType: existing.ContentType,
+		ContentHash: existing.ContentHash,
+		Profile:     existing.Profile,
+		DuplicateOf: existing.ID,
+		Status:      existing.ProcessingState,
+		Message:     "Video was already uploaded, returning the existing video",
+		Timestamp:   time.Now().Format(time.RFC3339),
+	})
+}
+
+// emit dispatches a webhook event about a video. Failures are logged since the
+// request does not depend on subscribers being notified.
+func (h *UploadHandler) emit(ctx context.Context, eventType string, video *database.Video) {
+	err := webhooks.Dispatch(ctx, h.temporalClient, webhooks.Event{
+		Type: eventType,
+		Data: webhooks.EventData{
+			VideoID: video.ID,
+			State:   video.ProcessingState,
+			Profile: video.Profile,
+		},
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to dispatch webhook event", "event", eventType, "error", err)
+	}
+}
+
+// Helper functions
+func handleError(w http.ResponseWriter, r *http.Request, message string, err error, statusCode int) {
+	errMsg := message
+	if err != nil {
+		errMsg = fmt.Sprintf("%s: %v", message, err)
+		slog.ErrorContext(r.Context(), message, "error", err, "status", statusCode)
+	}
+
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(map[string]string{
+		"error":   message,
+		"details": errMsg,
+	})
+}
+
+// parseDedupePolicy validates the requested dedupe policy, falling back to the configured default
+func parseDedupePolicy(value, fallback string) (string, error) {
+	if value == "" {
+		value = fallback
+	}
+
+	switch value {
+	case dedupe.None, dedupe.ReturnExisting, dedupe.ReuseRenditions:
+		return value, nil
+	case "":
+		return dedupe.None, nil
+	}
+
+	return "", fmt.Errorf("unknown dedupe policy %q, expected %s, %s or %s",
+		value, dedupe.None, dedupe.ReturnExisting, dedupe.ReuseRenditions)
+}
+
+// parseTags accepts repeated tags fields as well as comma-separated values
+func parseTags(values []string) []string {
+	var tags []string
+	for _, value := range values {
+		for _, tag := range strings.Split(value, ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				tags = append(tags, tag)
+			}
+		}
+	}
+	return tags
+}
+
+// parseMetadata decodes the optional metadata field, a JSON object of string values
+func parseMetadata(value string) (map[string]string, error) {
+	metadata := map[string]string{}
+	if strings.TrimSpace(value) == "" {
+		return metadata, nil
+	}
+
+	if err := json.Unmarshal([]byte(value), &metadata); err != nil {
+		return nil, fmt.Errorf("metadata must be a JSON object of strings: %v", err)
+	}
+
+	return metadata, nil
+}
+
+// handleRejection returns a structured validation failure to the client
+func handleRejection(w http.ResponseWriter, rejection *validation.Rejection) {
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":  "Upload rejected",
+		"reason": rejection,
+	})
+}
@@ -0,0 +1,113 @@
+package uploader
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/falcon/backend/internal/database"
+	"github.com/gorilla/mux"
+)
+
+// ProfileHandler manages transcoding profiles
+type ProfileHandler struct {
+	db *database.Database
+}
+
+// NewProfileHandler creates a new profile handler
+func NewProfileHandler(db *database.Database) *ProfileHandler {
+	return &ProfileHandler{db: db}
+}
+
+// ListProfiles returns all transcoding profiles
+func (h *ProfileHandler) ListProfiles(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	profiles, err := h.db.ListProfiles(r.Context())
+	if err != nil {
+		handleError(w, r, "Failed to list profiles", err, http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"profiles": profiles,
+	})
+}
+
+// GetProfile returns a single transcoding profile
+func (h *ProfileHandler) GetProfile(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	profile, err := h.db.GetProfile(r.Context(), mux.Vars(r)["name"])
+	if err != nil {
+		handleProfileError(w, r, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(profile)
+}
+
+// CreateProfile adds a new transcoding profile
+func (h *ProfileHandler) CreateProfile(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var profile database.Profile
+	if err := json.NewDecoder(r.Body).Decode(&profile); err != nil {
+		handleError(w, r, "Invalid request body", err, http.StatusBadRequest)
+		return
+	}
+
+	if err := h.db.CreateProfile(r.Context(), &profile); err != nil {
+		handleProfileError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(profile)
+}
+
+// UpdateProfile replaces the settings of a transcoding profile. Videos pick up the
+// new settings the next time they are transcoded.
+func (h *ProfileHandler) UpdateProfile(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var profile database.Profile
+	if err := json.NewDecoder(r.Body).Decode(&profile); err != nil {
+		handleError(w, r, "Invalid request body", err, http.StatusBadRequest)
+		return
+	}
+	profile.Name = mux.Vars(r)["name"]
+
+	if err := h.db.UpdateProfile(r.Context(), &profile); err != nil {
+		handleProfileError(w, r, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(profile)
+}
+
+// DeleteProfile removes a transcoding profile
+func (h *ProfileHandler) DeleteProfile(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if err := h.db.DeleteProfile(r.Context(), mux.Vars(r)["name"]); err != nil {
+		handleProfileError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleProfileError maps profile errors to HTTP status codes
+func handleProfileError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, database.ErrProfileNotFound):
+		handleError(w, r, "Profile not found", err, http.StatusNotFound)
+	case errors.Is(err, database.ErrInvalidProfile):
+		handleError(w, r, "Invalid profile", err, http.StatusBadRequest)
+	case errors.Is(err, database.ErrDuplicateProfile), errors.Is(err, database.ErrDefaultProfile):
+		handleError(w, r, "Profile conflict", err, http.StatusConflict)
+	default:
+		handleError(w, r, "Failed to manage profile", err, http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,65 @@
+package uploader
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/falcon/backend/internal/database"
+	"github.com/falcon/backend/internal/ffmpeg"
+	"github.com/falcon/backend/internal/health"
+	"github.com/falcon/backend/internal/logging"
+	"github.com/falcon/backend/internal/metrics"
+	"github.com/falcon/backend/internal/storage"
+	"github.com/falcon/backend/internal/tracing"
+	"github.com/gorilla/mux"
+	"go.temporal.io/sdk/client"
+)
+
+// NewRouter defines the routes of the uploader API, with health routes reporting
+// the checker's dependencies
+func NewRouter(uploadHandler *UploadHandler, profileHandler *ProfileHandler, webhookHandler *WebhookHandler, checker *health.Checker) *mux.Router {
+	router := mux.NewRouter()
+
+	health.Register(router, checker)
+	router.Handle("/metrics", metrics.Handler()).Methods("GET")
+	router.Handle("/upload", metrics.Uploads(http.HandlerFunc(uploadHandler.UploadVideo))).Methods("POST")
+	router.HandleFunc("/videos/{videoId}/schedule", uploadHandler.RetrySchedule).Methods("POST")
+	router.HandleFunc("/videos/{videoId}/transcode", uploadHandler.Retranscode).Methods("POST")
+	router.HandleFunc("/profiles", profileHandler.ListProfiles).Methods("GET")
+	router.HandleFunc("/profiles", profileHandler.CreateProfile).Methods("POST")
+	router.HandleFunc("/profiles/{name}", profileHandler.GetProfile).Methods("GET")
+	router.HandleFunc("/profiles/{name}", profileHandler.UpdateProfile).Methods("PUT")
+	router.HandleFunc("/profiles/{name}", profileHandler.DeleteProfile).Methods("DELETE")
+	router.HandleFunc("/webhooks", webhookHandler.ListWebhooks).Methods("GET")
+	router.HandleFunc("/webhooks", webhookHandler.CreateWebhook).Methods("POST")
+	router.HandleFunc("/webhooks/{webhookId}", webhookHandler.GetWebhook).Methods("GET")
+	router.HandleFunc("/webhooks/{webhookId}", webhookHandler.UpdateWebhook).Methods("PUT")
+	router.HandleFunc("/webhooks/{webhookId}", webhookHandler.DeleteWebhook).Methods("DELETE")
+	router.HandleFunc("/webhooks/{webhookId}/deliveries", webhookHandler.ListDeliveries).Methods("GET")
+	router.HandleFunc("/webhooks/{webhookId}/deliveries/{deliveryId}/redeliver", webhookHandler.Redeliver).Methods("POST")
+
+	router.Use(tracing.Middleware("uploader"), logging.Middleware, metrics.Middleware("uploader"))
+
+	return router
+}
+
+// NewChecker creates the readiness checks of the uploader: uploads are stored in
+// the bucket, probed with ffmpeg, recorded in Postgres and scheduled on Temporal
+func NewChecker(timeout time.Duration, db *database.Database, storageService *storage.StorageService, temporalClient client.Client, ff *ffmpeg.FFmpeg) *health.Checker {
+	return health.NewChecker("uploader", timeout).
+		Add(health.CheckPostgres, health.Postgres(db)).
+		Add(health.CheckStorage, health.Storage(storageService)).
+		Add(health.CheckTemporal, health.Temporal(temporalClient)).
+		Add(health.CheckFFmpeg, health.FFmpeg(ff))
+}
+
+// NewServer creates the HTTP server of the uploader API with the given read and
+// write timeouts, uploads need minutes
+func NewServer(addr string, handler http.Handler, readTimeout, writeTimeout time.Duration) *http.Server {
+	return &http.Server{
+		Handler:      handler,
+		Addr:         addr,
+		ReadTimeout:  readTimeout,
+		WriteTimeout: writeTimeout,
+	}
+}
@@ -0,0 +1,274 @@
+package uploader
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/falcon/backend/internal/database"
+	"github.com/falcon/backend/internal/webhooks"
+	"github.com/gorilla/mux"
+	"go.temporal.io/sdk/client"
+)
+
+// Bounds of the delivery log page size
+const (
+	defaultDeliveryLimit = 50
+	maxDeliveryLimit     = 500
+)
+
+// WebhookHandler manages webhook subscriptions and their delivery log
+type WebhookHandler struct {
+	db             *database.Database
+	temporalClient client.Client
+}
+
+// NewWebhookHandler creates a new webhook handler
+func NewWebhookHandler(db *database.Database, temporalClient client.Client) *WebhookHandler {
+	return &WebhookHandler{db: db, temporalClient: temporalClient}
+}
+
+// WebhookRequest is the body of a subscription create or update. Active defaults
+// to true and a missing secret is generated on create or kept on update.
+type WebhookRequest struct {
+	URL         string   `json:"url"`
+	Secret      string   `json:"secret"`
+	Events      []string `json:"events"`
+	Description string   `json:"description"`
+	Active      *bool    `json:"active"`
+}
+
+// subscription builds the subscription described by the request
+func (req *WebhookRequest) subscription() *database.WebhookSubscription {
+	sub := &database.WebhookSubscription{
+		URL:         req.URL,
+		Secret:      req.Secret,
+		Events:      req.Events,
+		Description: req.Description,
+		Active:      true,
+	}
+	if req.Active != nil {
+		sub.Active = *req.Active
+	}
+	return sub
+}
+
+// ListWebhooks returns all subscriptions without their secrets
+func (h *WebhookHandler) ListWebhooks(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	subs, err := h.db.ListWebhooks(r.Context())
+	if err != nil {
+		handleError(w, r, "Failed to list webhooks", err, http.StatusInternalServerError)
+		return
+	}
+	for _, sub := range subs {
+		sub.Secret = ""
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"webhooks": subs,
+	})
+}
+
+// GetWebhook returns a single subscription without its secret
+func (h *WebhookHandler) GetWebhook(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	webhookID, ok := parseID(w, r, "webhookId")
+	if !ok {
+		return
+	}
+
+	sub, err := h.db.GetWebhook(r.Context(), webhookID)
+	if err != nil {
+		handleWebhookError(w, r, err)
+		return
+	}
+	sub.Secret = ""
+
+	json.NewEncoder(w).Encode(sub)
+}
+
+// CreateWebhook adds a subscription. The response is the only one that includes
+// the signing secret.
+func (h *WebhookHandler) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req WebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		handleError(w, r, "Invalid request body", err, http.StatusBadRequest)
+		return
+	}
+
+	sub := req.subscription()
+	if sub.Secret == "" {
+		secret, err := webhooks.NewSecret()
+		if err != nil {
+			handleError(w, r, "Failed to create webhook", err, http.StatusInternalServerError)
+			return
+		}
+		sub.Secret = secret
+	}
+
+	if err := h.db.CreateWebhook(r.Context(), sub); err != nil {
+		handleWebhookError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(sub)
+}
+
+// UpdateWebhook replaces the settings of a subscription
+func (h *WebhookHandler) UpdateWebhook(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	webhookID, ok := parseID(w, r, "webhookId")
+	if !ok {
+		return
+	}
+
+	var req WebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		handleError(w, r, "Invalid request body", err, http.StatusBadRequest)
+		return
+	}
+
+	sub := req.subscription()
+	sub.ID = webhookID
+	if err := h.db.UpdateWebhook(r.Context(), sub); err != nil {
+		handleWebhookError(w, r, err)
+		return
+	}
+	sub.Secret = ""
+
+	json.NewEncoder(w).Encode(sub)
+}
+
+// DeleteWebhook removes a subscription and its delivery log
+func (h *WebhookHandler) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	webhookID, ok := parseID(w, r, "webhookId")
+	if !ok {
+		return
+	}
+
+	if err := h.db.DeleteWebhook(r.Context(), webhookID); err != nil {
+		handleWebhookError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListDeliveries returns the latest deliveries of a subscription, newest first
+func (h *WebhookHandler) ListDeliveries(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	webhookID, ok := parseID(w, r, "webhookId")
+	if !ok {
+		return
+	}
+
+	limit := defaultDeliveryLimit
+	if r.URL.Query().Get("limit") != "" {
+		fmt.Sscanf(r.URL.Query().Get("limit"), "%d", &limit)
+	}
+	if limit < 1 || limit > maxDeliveryLimit {
+		limit = defaultDeliveryLimit
+	}
+
+	// Distinguish an unknown subscription from one without deliveries
+	if _, err := h.db.GetWebhook(r.Context(), webhookID); err != nil {
+		handleWebhookError(w, r, err)
+		return
+	}
+
+	deliveries, err := h.db.ListWebhookDeliveries(r.Context(), webhookID, limit)
+	if err != nil {
+		handleWebhookError(w, r, err)
+		return
+	}
+	if deliveries == nil {
+		deliveries = []*database.WebhookDelivery{}
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"deliveries": deliveries,
+	})
+}
+
+// Redeliver sends the payload of an earlier delivery again as a new delivery
+func (h *WebhookHandler) Redeliver(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	webhookID, ok := parseID(w, r, "webhookId")
+	if !ok {
+		return
+	}
+	deliveryID, ok := parseID(w, r, "deliveryId")
+	if !ok {
+		return
+	}
+
+	original, err := h.db.GetWebhookDelivery(r.Context(), deliveryID)
+	if err != nil {
+		handleWebhookError(w, r, err)
+		return
+	}
+	if original.SubscriptionID != webhookID {
+		handleWebhookError(w, r, fmt.Errorf("%w: %d", database.ErrDeliveryNotFound, deliveryID))
+		return
+	}
+
+	delivery, err := h.db.RedeliverWebhook(r.Context(), deliveryID)
+	if err != nil {
+		handleWebhookError(w, r, err)
+		return
+	}
+
+	_, err = h.temporalClient.ExecuteWorkflow(r.Context(), client.StartWorkflowOptions{
+		ID:        fmt.Sprintf("webhook-delivery-%d", delivery.ID),
+		TaskQueue: webhooks.TaskQueue,
+	}, webhooks.DeliverWorkflow, delivery.ID)
+	if err != nil {
+		// Keep the delivery log honest about the redelivery that never ran
+		if failErr := h.db.FailWebhookDelivery(r.Context(), delivery.ID); failErr != nil {
+			slog.ErrorContext(r.Context(), "Failed to mark webhook delivery as failed", "delivery", delivery.ID, "error", failErr)
+		}
+		handleError(w, r, "Failed to schedule redelivery", err, http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(delivery)
+}
+
+// parseID reads a numeric route variable, answering 400 when it is malformed
+func parseID(w http.ResponseWriter, r *http.Request, name string) (int64, bool) {
+	value, err := strconv.ParseInt(mux.Vars(r)[name], 10, 64)
+	if err != nil {
+		handleError(w, r, "Invalid "+name, err, http.StatusBadRequest)
+		return 0, false
+	}
+	return value, true
+}
+
+// handleWebhookError maps webhook errors to HTTP status codes
+func handleWebhookError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, database.ErrWebhookNotFound):
+		handleError(w, r, "Webhook not found", err, http.StatusNotFound)
+	case errors.Is(err, database.ErrDeliveryNotFound):
+		handleError(w, r, "Webhook delivery not found", err, http.StatusNotFound)
+	case errors.Is(err, database.ErrInvalidWebhook):
+		handleError(w, r, "Invalid webhook", err, http.StatusBadRequest)
+	default:
+		handleError(w, r, "Failed to manage webhook", err, http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,127 @@
+package validation
+
+import (
+	"bytes"
+	"io"
+	"os"
+)
+
+// Container names returned by Sniff
+const (
+	ContainerMP4      = "mp4"
+	ContainerMOV      = "mov"
+	ContainerMatroska = "matroska"
+	ContainerWebM     = "webm"
+	ContainerAVI      = "avi"
+	ContainerMPEGTS   = "mpegts"
+	ContainerMPEGPS   = "mpegps"
+	ContainerMXF      = "mxf"
+	ContainerFLV      = "flv"
+	ContainerASF      = "asf"
+)
+
+// sniffLen is the number of leading bytes inspected by Sniff
+const sniffLen = 4096
+
+// containerTypes maps detected containers to the content type stored for the video
+var containerTypes = map[string]string{
+	ContainerMP4:      "video/mp4",
+	ContainerMOV:      "video/quicktime",
+	ContainerMatroska: "video/x-matroska",
+	ContainerWebM:     "video/webm",
+	ContainerAVI:      "video/x-msvideo",
+	ContainerMPEGTS:   "video/mp2t",
+	ContainerMPEGPS:   "video/mpeg",
+	ContainerMXF:      "application/mxf",
+	ContainerFLV:      "video/x-flv",
+	ContainerASF:      "video/x-ms-asf",
+}
+
+var (
+	ebmlMagic = []byte{0x1A, 0x45, 0xDF, 0xA3}
+	mxfMagic  = []byte{0x06, 0x0E, 0x2B, 0x34, 0x02, 0x05, 0x01, 0x01, 0x0D, 0x01, 0x02}
+	asfMagic  = []byte{0x30, 0x26, 0xB2, 0x75, 0x8E, 0x66, 0xCF, 0x11}
+	psMagic   = []byte{0x00, 0x00, 0x01, 0xBA}
+)
+
+// ContentType returns the MIME type for a container detected by Sniff
+func ContentType(container string) string {
+	if contentType, ok := containerTypes[container]; ok {
+		return contentType
+	}
+	return "application/octet-stream"
+}
+
+// SniffFile detects the container format of a file from its leading bytes
+func SniffFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	header := make([]byte, sniffLen)
+	n, err := io.ReadFull(file, header)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", err
+	}
+
+	return Sniff(header[:n]), nil
+}
+
+// Sniff detects the container format from magic bytes, returning "" when unknown
+func Sniff(header []byte) string {
+	switch {
+	case len(header) >= 12 && bytes.Equal(header[4:8], []byte("ftyp")):
+		if bytes.Equal(header[8:12], []byte("qt  ")) {
+			return ContainerMOV
+		}
+		return ContainerMP4
+	case len(header) >= 8 && isQuickTimeAtom(header[4:8]):
+		return ContainerMOV
+	case bytes.HasPrefix(header, ebmlMagic):
+		// The EBML header carries the DocType near the start of the file
+		if bytes.Contains(header[:min(len(header), 64)], []byte("webm")) {
+			return ContainerWebM
+		}
+		return ContainerMatroska
+	case len(header) >= 12 && bytes.Equal(header[0:4], []byte("RIFF")) && bytes.Equal(header[8:12], []byte("AVI ")):
+		return ContainerAVI
+	case bytes.HasPrefix(header, mxfMagic):
+		return ContainerMXF
+	case bytes.HasPrefix(header, asfMagic):
+		return ContainerASF
+	case bytes.HasPrefix(header, []byte("FLV")):
+		return ContainerFLV
+	case bytes.HasPrefix(header, psMagic):
+		return ContainerMPEGPS
+	case isMPEGTS(header):
+		return ContainerMPEGTS
+	default:
+		return ""
+	}
+}
+
+// isQuickTimeAtom reports whether a box type is a top-level QuickTime atom
+func isQuickTimeAtom(boxType []byte) bool {
+	switch string(boxType) {
+	case "moov", "mdat", "wide", "free", "skip", "pnot":
+		return true
+	}
+	return false
+}
+
+// isMPEGTS checks for the 0x47 sync byte at the start of consecutive 188 or 192 byte packets
+func isMPEGTS(header []byte) bool {
+	for _, packetSize := range []int{188, 192} {
+		offset := packetSize - 188
+		packets := 0
+		for pos := offset; pos < len(header) && header[pos] == 0x47; pos += packetSize {
+			packets++
+		}
+		if packets >= 3 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,110 @@
+package validation
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// tsPackets returns count MPEG-TS packets of the given size, each starting with the sync byte
+func tsPackets(packetSize, count int) []byte {
+	data := make([]byte, packetSize*count)
+	for i := 0; i < count; i++ {
+		data[i*packetSize+packetSize-188] = 0x47
+	}
+	return data
+}
+
+func TestSniff(t *testing.T) {
+	tests := []struct {
+		name   string
+		header []byte
+		want   string
+	}{
+		{"mp4", []byte("\x00\x00\x00\x20ftypisom\x00\x00\x02\x00"), ContainerMP4},
+		{"mov ftyp", []byte("\x00\x00\x00\x14ftypqt  \x00\x00\x00\x00"), ContainerMOV},
+		{"mov atom", []byte("\x00\x00\x00\x08wide\x00\x00\x00\x00mdat"), ContainerMOV},
+		{"mov moov", []byte("\x00\x00\x01\x00moov"), ContainerMOV},
+		{"webm", append([]byte{0x1A, 0x45, 0xDF, 0xA3, 0x9F, 0x42, 0x82, 0x84}, "webm"...), ContainerWebM},
+		{"matroska", append([]byte{0x1A, 0x45, 0xDF, 0xA3, 0xA3, 0x42, 0x82, 0x88}, "matroska"...), ContainerMatroska},
+		{"avi", []byte("RIFF\x00\x10\x00\x00AVI LIST"), ContainerAVI},
+		{"wav", []byte("RIFF\x00\x10\x00\x00WAVEfmt "), ""},
+		{"mxf", []byte{0x06, 0x0E, 0x2B, 0x34, 0x02, 0x05, 0x01, 0x01, 0x0D, 0x01, 0x02, 0x01}, ContainerMXF},
+		{"asf", []byte{0x30, 0x26, 0xB2, 0x75, 0x8E, 0x66, 0xCF, 0x11, 0xA6, 0xD9}, ContainerASF},
+		{"flv", []byte("FLV\x01\x05\x00\x00\x00\x09"), ContainerFLV},
+		{"mpeg-ps", []byte{0x00, 0x00, 0x01, 0xBA, 0x44, 0x00}, ContainerMPEGPS},
+		{"mpeg-ts", tsPackets(188, 3), ContainerMPEGTS},
+		{"m2ts", tsPackets(192, 3), ContainerMPEGTS},
+		{"mpeg-ts too short", tsPackets(188, 2), ""},
+		{"png", []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"), ""},
+		{"text", []byte("hello, world"), ""},
+		{"short ftyp", []byte("\x00\x00\x00\x20ftyp"), ""},
+		{"empty", nil, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Sniff(tt.header); got != tt.want {
+				t.Errorf("Sniff() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSniffFile(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		name    string
+		content []byte
+		want    string
+	}{
+		{"shorter than sniffLen", []byte("FLV\x01\x05"), ContainerFLV},
+		{"longer than sniffLen", append([]byte("\x00\x00\x00\x20ftypmp42"), bytes.Repeat([]byte{0}, 2*sniffLen)...), ContainerMP4},
+		{"empty", nil, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, tt.name)
+			if err := os.WriteFile(path, tt.content, 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := SniffFile(path)
+			if err != nil {
+				t.Fatalf("SniffFile() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("SniffFile() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := SniffFile(filepath.Join(dir, "missing")); err == nil {
+		t.Error("SniffFile() of a missing file succeeded")
+	}
+}
+
+func TestContentType(t *testing.T) {
+	tests := []struct {
+		container string
+		want      string
+	}{
+		{ContainerMP4, "video/mp4"},
+		{ContainerMOV, "video/quicktime"},
+		{ContainerWebM, "video/webm"},
+		{ContainerMPEGTS, "video/mp2t"},
+		{"", "application/octet-stream"},
+		{"unknown", "application/octet-stream"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.container, func(t *testing.T) {
+			if got := ContentType(tt.container); got != tt.want {
+				t.Errorf("ContentType(%q) = %q, want %q", tt.container, got, tt.want)
+			}
+		})
+	}
+}
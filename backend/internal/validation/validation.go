@@ -0,0 +1,176 @@
+package validation
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/falcon/backend/internal/ffmpeg"
+)
+
+// Rejection codes returned to clients
+const (
+	CodeUnrecognizedContainer = "unrecognized_container"
+	CodeContainerNotAllowed   = "container_not_allowed"
+	CodeProbeFailed           = "probe_failed"
+	CodeNoVideoStream         = "no_video_stream"
+	CodeVideoCodecNotAllowed  = "video_codec_not_allowed"
+	CodeAudioCodecNotAllowed  = "audio_codec_not_allowed"
+	CodeDurationExceeded      = "duration_exceeded"
+	CodeResolutionExceeded    = "resolution_exceeded"
+)
+
+// Rules configures which uploads are accepted. Empty allowlists and zero limits are not enforced.
+type Rules struct {
+	Containers  []string      `mapstructure:"containers"`
+	VideoCodecs []string      `mapstructure:"video_codecs"`
+	AudioCodecs []string      `mapstructure:"audio_codecs"`
+	MaxDuration time.Duration `mapstructure:"max_duration"`
+	MaxWidth    int           `mapstructure:"max_width"`
+	MaxHeight   int           `mapstructure:"max_height"`
+}
+
+// Rejection explains why a file was not accepted
+type Rejection struct {
+	Code    string            `json:"code"`
+	Message string            `json:"message"`
+	Details map[string]string `json:"details,omitempty"`
+}
+
+// Error implements the error interface
+func (r *Rejection) Error() string {
+	return fmt.Sprintf("%s: %s", r.Code, r.Message)
+}
+
+// Result describes a file that passed validation
+type Result struct {
+	Container   string
+	ContentType string
+	VideoCodec  string
+	AudioCodec  string
+	Width       int
+	Height      int
+	Duration    float64
+}
+
+// Validator checks uploaded files against a set of rules
+type Validator struct {
+	ffmpeg *ffmpeg.FFmpeg
+	rules  Rules
+}
+
+// NewValidator creates a new validator
+func NewValidator(ff *ffmpeg.FFmpeg, rules Rules) *Validator {
+	return &Validator{
+		ffmpeg: ff,
+		rules:  rules,
+	}
+}
+
+// Validate sniffs and probes a local file, returning a *Rejection if it is not acceptable
+func (v *Validator) Validate(ctx context.Context, path string) (*Result, error) {
+	// Detect the container from magic bytes rather than trusting the client
+	container, err := SniffFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %v", err)
+	}
+	if container == "" {
+		return nil, &Rejection{
+			Code:    CodeUnrecognizedContainer,
+			Message: "File is not a recognized video container",
+		}
+	}
+	if !allowed(v.rules.Containers, container) {
+		return nil, &Rejection{
+			Code:    CodeContainerNotAllowed,
+			Message: fmt.Sprintf("Container %s is not allowed", container),
+			Details: map[string]string{"container": container, "allowed": strings.Join(v.rules.Containers, ",")},
+		}
+	}
+
+	// Run a quick ffprobe pass to check streams and limits
+	probe, err := v.ffmpeg.Probe(ctx, path)
+	if err != nil {
+		return nil, &Rejection{
+			Code:    CodeProbeFailed,
+			Message: "File could not be decoded",
+			Details: map[string]string{"container": container, "error": err.Error()},
+		}
+	}
+
+	video := probe.VideoStream()
+	if video == nil {
+		return nil, &Rejection{
+			Code:    CodeNoVideoStream,
+			Message: "File does not contain a video stream",
+			Details: map[string]string{"container": container},
+		}
+	}
+	if !allowed(v.rules.VideoCodecs, video.CodecName) {
+		return nil, &Rejection{
+			Code:    CodeVideoCodecNotAllowed,
+			Message: fmt.Sprintf("Video codec %s is not allowed", video.CodecName),
+			Details: map[string]string{"codec": video.CodecName, "allowed": strings.Join(v.rules.VideoCodecs, ",")},
+		}
+	}
+
+	result := &Result{
+		Container:   container,
+		ContentType: ContentType(container),
+		VideoCodec:  video.CodecName,
+		Width:       video.Width,
+		Height:      video.Height,
+		Duration:    probe.DurationSeconds(),
+	}
+
+	if audio := probe.AudioStream(); audio != nil {
+		if !allowed(v.rules.AudioCodecs, audio.CodecName) {
+			return nil, &Rejection{
+				Code:    CodeAudioCodecNotAllowed,
+				Message: fmt.Sprintf("Audio codec %s is not allowed", audio.CodecName),
+				Details: map[string]string{"codec": audio.CodecName, "allowed": strings.Join(v.rules.AudioCodecs, ",")},
+			}
+		}
+		result.AudioCodec = audio.CodecName
+	}
+
+	if v.rules.MaxDuration > 0 && result.Duration > v.rules.MaxDuration.Seconds() {
+		return nil, &Rejection{
+			Code:    CodeDurationExceeded,
+			Message: fmt.Sprintf("Duration %.1fs exceeds the maximum of %s", result.Duration, v.rules.MaxDuration),
+			Details: map[string]string{
+				"duration":     fmt.Sprintf("%.3f", result.Duration),
+				"max_duration": fmt.Sprintf("%.0f", v.rules.MaxDuration.Seconds()),
+			},
+		}
+	}
+
+	if (v.rules.MaxWidth > 0 && result.Width > v.rules.MaxWidth) || (v.rules.MaxHeight > 0 && result.Height > v.rules.MaxHeight) {
+		return nil, &Rejection{
+			Code:    CodeResolutionExceeded,
+			Message: fmt.Sprintf("Resolution %dx%d exceeds the maximum of %dx%d", result.Width, result.Height, v.rules.MaxWidth, v.rules.MaxHeight),
+			Details: map[string]string{
+				"resolution":     fmt.Sprintf("%dx%d", result.Width, result.Height),
+				"max_resolution": fmt.Sprintf("%dx%d", v.rules.MaxWidth, v.rules.MaxHeight),
+			},
+		}
+	}
+
+	return result, nil
+}
+
+// Helper function to check a value against an optional allowlist
+func allowed(allowlist []string, value string) bool {
+	if len(allowlist) == 0 {
+		return true
+	}
+
+	for _, item := range allowlist {
+		if strings.EqualFold(item, value) {
+			return true
+		}
+	}
+
+	return false
+}
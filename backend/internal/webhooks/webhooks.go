@@ -0,0 +1,55 @@
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+// Headers set on every delivery
+const (
+	SignatureHeader = "X-Falcon-Signature"
+	EventHeader     = "X-Falcon-Event"
+	DeliveryHeader  = "X-Falcon-Delivery"
+)
+
+// Event is the JSON body posted to webhook subscribers
+type Event struct {
+	ID        string    `json:"id"`
+	Type      string    `json:"type"`
+	CreatedAt time.Time `json:"created_at"`
+	Data      EventData `json:"data"`
+}
+
+// EventData describes the video an event is about
+type EventData struct {
+	VideoID   string `json:"video_id"`
+	State     string `json:"state,omitempty"`
+	Profile   string `json:"profile,omitempty"`
+	Reprocess bool   `json:"reprocess,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// Sign returns the signature header value of a body sent at timestamp. The
+// signature is the hex HMAC-SHA256 of "<timestamp>.<body>" keyed by the secret.
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	t := timestamp.Unix()
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", t)
+	mac.Write(body)
+
+	return fmt.Sprintf("t=%d,v1=%s", t, hex.EncodeToString(mac.Sum(nil)))
+}
+
+// NewSecret returns a random signing secret for a subscription
+func NewSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate webhook secret: %v", err)
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
@@ -0,0 +1,99 @@
+package webhooks
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSign(t *testing.T) {
+	tests := []struct {
+		name      string
+		secret    string
+		timestamp time.Time
+		body      string
+		want      string
+	}{
+		{
+			name:      "json body",
+			secret:    "secret",
+			timestamp: time.Unix(1700000000, 0),
+			body:      `{"a":1}`,
+			want:      "t=1700000000,v1=49f24e537407743fa4a0242bb63b94b9a47ee99cbbe071ccd8a22550ae411686",
+		},
+		{
+			name:      "sub-second precision is dropped",
+			secret:    "secret",
+			timestamp: time.Unix(1700000000, 999999999),
+			body:      `{"a":1}`,
+			want:      "t=1700000000,v1=49f24e537407743fa4a0242bb63b94b9a47ee99cbbe071ccd8a22550ae411686",
+		},
+		{
+			name:      "time zone does not matter",
+			secret:    "secret",
+			timestamp: time.Unix(1700000000, 0).In(time.FixedZone("UTC+5", 5*60*60)),
+			body:      `{"a":1}`,
+			want:      "t=1700000000,v1=49f24e537407743fa4a0242bb63b94b9a47ee99cbbe071ccd8a22550ae411686",
+		},
+		{
+			name:      "timestamp is signed",
+			secret:    "secret",
+			timestamp: time.Unix(1700000001, 0),
+			body:      `{"a":1}`,
+			want:      "t=1700000001,v1=950e69daa0000e4a287a28e0bc82e5020dfc6320b01f7904da992d14233ddce0",
+		},
+		{
+			name:      "other secret",
+			secret:    "other",
+			timestamp: time.Unix(1700000000, 0),
+			body:      `{"a":1}`,
+			want:      "t=1700000000,v1=2cb38bd50b3aa61b12df512da616c9577f2a99edb9467110d361a655e1ad3bd5",
+		},
+		{
+			name:      "empty body",
+			secret:    "secret",
+			timestamp: time.Unix(1700000000, 0),
+			body:      "",
+			want:      "t=1700000000,v1=4bc5f74d868b97888288889c5d9d65df02526f94c1592a79fdf4fe8b26e311e5",
+		},
+		{
+			name:      "epoch",
+			secret:    "whsec_abc",
+			timestamp: time.Unix(0, 0),
+			body:      "payload",
+			want:      "t=0,v1=094902bc85da17aefd19859851127d14472201aa31f468deba115b47579453e5",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Sign(tt.secret, tt.timestamp, []byte(tt.body)); got != tt.want {
+				t.Errorf("Sign() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewSecret(t *testing.T) {
+	seen := make(map[string]bool)
+
+	for i := 0; i < 10; i++ {
+		secret, err := NewSecret()
+		if err != nil {
+			t.Fatalf("NewSecret() error = %v", err)
+		}
+
+		key, ok := strings.CutPrefix(secret, "whsec_")
+		if !ok {
+			t.Fatalf("NewSecret() = %q, want the whsec_ prefix", secret)
+		}
+		if b, err := hex.DecodeString(key); err != nil || len(b) != 32 {
+			t.Fatalf("NewSecret() = %q, want 32 hex encoded bytes", secret)
+		}
+		if seen[secret] {
+			t.Fatalf("NewSecret() returned %q twice", secret)
+		}
+		seen[secret] = true
+	}
+}
@@ -0,0 +1,56 @@
+package webhooks
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/falcon/backend/internal/database"
+	"github.com/falcon/backend/internal/tracing"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/worker"
+)
+
+// NewWorker creates a worker on the webhook task queue with the workflows and their
+// activities registered. Activities find their dependencies in the worker context.
+func NewWorker(temporalClient client.Client, deps *ActivityDependencies) worker.Worker {
+	w := worker.New(temporalClient, TaskQueue, worker.Options{
+		BackgroundActivityContext: context.WithValue(
+			context.Background(),
+			"dependencies",
+			deps,
+		),
+	})
+
+	// Register workflows and activities
+	w.RegisterWorkflow(DispatchWorkflow)
+	w.RegisterWorkflow(DeliverWorkflow)
+	w.RegisterActivity(CreateDeliveriesActivity)
+	w.RegisterActivity(DeliverActivity)
+	w.RegisterActivity(FailDeliveryActivity)
+
+	return w
+}
+
+// ActivityDependencies holds references to services needed by activities
+type ActivityDependencies struct {
+	DB     *database.Database
+	Client *http.Client
+}
+
+// NewHTTPClient returns the client deliveries are sent with. Redirects are not
+// followed so a subscriber cannot point deliveries at another host.
+func NewHTTPClient(timeout time.Duration) *http.Client {
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: tracing.Transport(http.DefaultTransport),
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// GetDependencies extracts dependencies from the context
+func GetDependencies(ctx context.Context) *ActivityDependencies {
+	return ctx.Value("dependencies").(*ActivityDependencies)
+}
@@ -0,0 +1,231 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/falcon/backend/internal/database"
+	"github.com/falcon/backend/internal/id"
+	"github.com/falcon/backend/internal/logging"
+	"github.com/falcon/backend/internal/metrics"
+	"go.temporal.io/api/enums/v1"
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+)
+
+// TaskQueue is the Temporal task queue of the webhook worker
+const TaskQueue = "WEBHOOK_TASK_QUEUE"
+
+// MaxTimeout bounds the configured timeout of a delivery attempt, which must
+// finish within the activity's start-to-close timeout
+const MaxTimeout = time.Minute
+
+// maxResponseBytes bounds how much of a subscriber's response is read
+const maxResponseBytes = 4 << 10
+
+// Dispatch starts the delivery of an event to every subscriber from outside a
+// workflow. Events without an ID get a new one.
+func Dispatch(ctx context.Context, temporalClient client.Client, event Event) error {
+	if event.ID == "" {
+		event.ID = id.NewEventID()
+	}
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now().UTC()
+	}
+
+	_, err := temporalClient.ExecuteWorkflow(ctx, client.StartWorkflowOptions{
+		ID:        "webhook-" + event.ID,
+		TaskQueue: TaskQueue,
+	}, DispatchWorkflow, event)
+	if err != nil {
+		return fmt.Errorf("failed to dispatch %s event: %v", event.Type, err)
+	}
+
+	return nil
+}
+
+// DispatchFromWorkflow starts the delivery of an event from workflow code. The
+// deliveries run in a child workflow that outlives its parent, and the event ID is
+// derived from the parent run so replays do not send the event twice.
+func DispatchFromWorkflow(ctx workflow.Context, event Event) error {
+	info := workflow.GetInfo(ctx)
+	event.ID = fmt.Sprintf("%s-%s", info.WorkflowExecution.RunID, event.Type)
+	event.CreatedAt = workflow.Now(ctx).UTC()
+
+	ctx = workflow.WithChildOptions(ctx, workflow.ChildWorkflowOptions{
+		WorkflowID:        "webhook-" + event.ID,
+		TaskQueue:         TaskQueue,
+		ParentClosePolicy: enums.PARENT_CLOSE_POLICY_ABANDON,
+	})
+
+	// Wait for the child to start so it is not lost if the parent completes first
+	child := workflow.ExecuteChildWorkflow(ctx, DispatchWorkflow, event)
+	return child.GetChildWorkflowExecution().Get(ctx, nil)
+}
+
+// DispatchWorkflow records a delivery of the event for every subscriber and delivers
+// them concurrently. Failed deliveries are recorded and do not fail the workflow.
+func DispatchWorkflow(ctx workflow.Context, event Event) error {
+	ctx = logging.WithWorkflowVideoID(ctx, event.Data.VideoID)
+	logger := logging.WorkflowLogger(ctx)
+
+	if event.ID == "" {
+		event.ID = workflow.GetInfo(ctx).WorkflowExecution.ID
+	}
+
+	var deliveryIDs []int64
+	if err := workflow.ExecuteActivity(withBookkeepingOptions(ctx), CreateDeliveriesActivity, event).Get(ctx, &deliveryIDs); err != nil {
+		return err
+	}
+	logger.Info("Dispatching webhook event", "event", event.Type, "deliveries", len(deliveryIDs))
+
+	wg := workflow.NewWaitGroup(ctx)
+	for _, deliveryID := range deliveryIDs {
+		deliveryID := deliveryID
+		wg.Add(1)
+		workflow.Go(ctx, func(ctx workflow.Context) {
+			defer wg.Done()
+			if err := deliver(ctx, deliveryID); err != nil {
+				logger.Warn("Webhook delivery failed", "delivery", deliveryID, "error", err)
+			}
+		})
+	}
+	wg.Wait(ctx)
+
+	return nil
+}
+
+// DeliverWorkflow delivers a single recorded delivery, such as a manual redelivery
+func DeliverWorkflow(ctx workflow.Context, deliveryID int64) error {
+	return deliver(ctx, deliveryID)
+}
+
+// deliver sends a delivery until it succeeds or its retries run out, in which case
+// the delivery is marked as failed
+func deliver(ctx workflow.Context, deliveryID int64) error {
+	deliveryCtx := workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		StartToCloseTimeout: MaxTimeout + 10*time.Second,
+		RetryPolicy: &temporal.RetryPolicy{
+			InitialInterval:    30 * time.Second,
+			BackoffCoefficient: 2.0,
+			MaximumInterval:    time.Hour,
+			MaximumAttempts:    10,
+		},
+	})
+
+	err := workflow.ExecuteActivity(deliveryCtx, DeliverActivity, deliveryID).Get(ctx, nil)
+	if err == nil {
+		return nil
+	}
+
+	if failErr := workflow.ExecuteActivity(withBookkeepingOptions(ctx), FailDeliveryActivity, deliveryID).Get(ctx, nil); failErr != nil {
+		logging.WorkflowLogger(ctx).Warn("Failed to record webhook delivery failure", "delivery", deliveryID, "error", failErr)
+	}
+	return err
+}
+
+// withBookkeepingOptions applies the short timeouts used for database-only activities
+func withBookkeepingOptions(ctx workflow.Context) workflow.Context {
+	return workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		StartToCloseTimeout: 5 * time.Second,
+		RetryPolicy: &temporal.RetryPolicy{
+			InitialInterval:    time.Second,
+			BackoffCoefficient: 2.0,
+			MaximumInterval:    10 * time.Second,
+			MaximumAttempts:    5,
+		},
+	})
+}
+
+// CreateDeliveriesActivity records a pending delivery of the event for every active
+// subscription to its type and returns their IDs
+func CreateDeliveriesActivity(ctx context.Context, event Event) ([]int64, error) {
+	deps := GetDependencies(ctx)
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return nil, err
+	}
+
+	return deps.DB.CreateWebhookDeliveries(ctx, event.ID, event.Type, event.Data.VideoID, payload)
+}
+
+// DeliverActivity posts the signed payload of a delivery to its subscriber and
+// records the attempt. Responses other than 2xx fail the attempt so it is retried.
+func DeliverActivity(ctx context.Context, deliveryID int64) error {
+	deps := GetDependencies(ctx)
+	info := activity.GetInfo(ctx)
+
+	delivery, err := deps.DB.GetWebhookDelivery(ctx, deliveryID)
+	if errors.Is(err, database.ErrDeliveryNotFound) {
+		return temporal.NewNonRetryableApplicationError(err.Error(), "DeliveryNotFound", err)
+	}
+	if err != nil {
+		return err
+	}
+	if delivery.Status == database.DeliverySucceeded {
+		return nil
+	}
+	ctx = logging.WithVideoID(ctx, delivery.VideoID)
+
+	sub, err := deps.DB.GetWebhook(ctx, delivery.SubscriptionID)
+	if errors.Is(err, database.ErrWebhookNotFound) {
+		return temporal.NewNonRetryableApplicationError(err.Error(), "WebhookNotFound", err)
+	}
+	if err != nil {
+		return err
+	}
+
+	responseStatus, sendErr := send(ctx, deps.Client, sub, delivery)
+
+	errMsg := ""
+	if sendErr != nil {
+		errMsg = sendErr.Error()
+	}
+	if err := deps.DB.RecordWebhookAttempt(ctx, deliveryID, info.Attempt, responseStatus, errMsg, sendErr == nil); err != nil {
+		return err
+	}
+	metrics.ObserveWebhookDelivery(delivery.EventType, sendErr)
+
+	return sendErr
+}
+
+// send posts a delivery to the subscription URL and returns the response status
+func send(ctx context.Context, httpClient *http.Client, sub *database.WebhookSubscription, delivery *database.WebhookDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Falcon-Webhooks/1.0")
+	req.Header.Set(EventHeader, delivery.EventType)
+	req.Header.Set(DeliveryHeader, fmt.Sprint(delivery.ID))
+	req.Header.Set(SignatureHeader, Sign(sub.Secret, time.Now(), delivery.Payload))
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, maxResponseBytes))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("subscriber responded with status %d", resp.StatusCode)
+	}
+
+	return resp.StatusCode, nil
+}
+
+// FailDeliveryActivity marks a delivery as failed once its retries are exhausted
+func FailDeliveryActivity(ctx context.Context, deliveryID int64) error {
+	deps := GetDependencies(ctx)
+	return deps.DB.FailWebhookDelivery(ctx, deliveryID)
+}
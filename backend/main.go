@@ -1,123 +1,135 @@
 package main
 
 import (
-	"bufio"
 	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
-	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
-	"os/exec"
 	"os/signal"
-	"strings"
-	"sync"
+	"path/filepath"
 	"syscall"
 	"time"
 
+	"github.com/falcon/backend/internal/config"
 	"github.com/falcon/backend/internal/database"
-	"github.com/golang/glog"
+	"github.com/falcon/backend/internal/gateway"
+	"github.com/falcon/backend/internal/health"
+	"github.com/falcon/backend/internal/logging"
+	"github.com/falcon/backend/internal/metrics"
+	"github.com/falcon/backend/internal/supervisor"
+	"github.com/falcon/backend/internal/tracing"
 	"github.com/gorilla/mux"
-	"github.com/spf13/viper"
 )
 
-// Service represents a backend service
-type Service struct {
-	Name         string
-	Command      string
-	Args         []string
-	Cmd          *exec.Cmd
-	StdoutLogger *log.Logger
-	StderrLogger *log.Logger
-}
-
+// Set up logging
 func init() {
-	// Initialize configuration
-	viper.SetConfigName("config")
-	viper.SetConfigType("yaml")
-	viper.AddConfigPath(".")
-	viper.AutomaticEnv()
-
-	if err := viper.ReadInConfig(); err != nil {
-		log.Fatalf("Error reading config file: %s", err)
-	}
-
-	// Set up logging
-	flag.Set("logtostderr", "true")
-	flag.Set("stderrthreshold", "INFO")
-	flag.Set("v", "2")
 	flag.Parse()
+	logging.Init("main")
 }
 
 func main() {
+	cfg := config.MustLoad()
+
+	// Export traces, continuing those started by other services
+	shutdownTracing, err := tracing.Init(context.Background(), "main", cfg.Tracing)
+	if err != nil {
+		logging.Fatal("Failed to initialize tracing", "error", err)
+	}
+	defer shutdownTracing(context.Background())
+
 	// Set up router for API
 	router := mux.NewRouter()
 
 	// Set up database
-	db, err := database.NewDatabaseFromConfig()
+	db, err := database.NewDatabase(cfg.Database)
 	if err != nil {
-		glog.Fatalf("Failed to connect to database: %v", err)
+		logging.Fatal("Failed to connect to database", "error", err)
 	}
 	defer db.Close()
 
-	// Create tables if they don't exist
-	if err := db.CreateTables(context.Background()); err != nil {
-		glog.Fatalf("Failed to create database tables: %v", err)
+	// Export connection pool statistics
+	if err := metrics.RegisterDBPool(db.Pool()); err != nil {
+		logging.Fatal("Failed to register pool metrics", "error", err)
+	}
+
+	// Apply pending schema migrations
+	if err := db.Migrate(context.Background()); err != nil {
+		logging.Fatal("Failed to migrate database", "error", err)
 	}
 
 	// Define API routes
-	router.HandleFunc("/health", healthCheckHandler).Methods("GET")
-	router.HandleFunc("/info", infoHandler).Methods("GET")
-
-	// Define services to start
-	services := []*Service{
-		{
-			Name:         "uploader",
-			Command:      "go",
-			Args:         []string{"run", "cmd/uploader/main.go"},
-			StdoutLogger: log.New(os.Stdout, "UPLOADER: ", log.LstdFlags),
-			StderrLogger: log.New(os.Stderr, "UPLOADER ERR: ", log.LstdFlags),
-		},
-		{
-			Name:         "transcoder",
-			Command:      "go",
-			Args:         []string{"run", "cmd/transcoder/main.go"},
-			StdoutLogger: log.New(os.Stdout, "TRANSCODER: ", log.LstdFlags),
-			StderrLogger: log.New(os.Stderr, "TRANSCODER ERR: ", log.LstdFlags),
-		},
-		{
-			Name:         "streamer",
-			Command:      "go",
-			Args:         []string{"run", "cmd/streamer/main.go"},
-			StdoutLogger: log.New(os.Stdout, "STREAMER: ", log.LstdFlags),
-			StderrLogger: log.New(os.Stderr, "STREAMER ERR: ", log.LstdFlags),
-		},
+	apiTimeout := gateway.Deadline(30 * time.Second)
+	router.Handle("/info", apiTimeout(http.HandlerFunc(infoHandler))).Methods("GET")
+	router.Handle("/metrics", apiTimeout(metrics.Handler())).Methods("GET")
+	router.Use(tracing.Middleware("main"), logging.Middleware, metrics.Middleware("main"))
+
+	// Route the public API to the uploader and streamer, so clients use one origin
+	gw, err := gateway.New(cfg.GatewayConfig())
+	if err != nil {
+		logging.Fatal("Invalid gateway configuration", "error", err)
+	}
+	gw.Register(router)
+
+	// Readiness covers the main service's own database and every service it runs
+	checker := health.NewChecker("main", cfg.Health.Timeout).
+		Add(health.CheckPostgres, health.Postgres(db))
+	var services []string
+
+	// Run the services in this process, or as supervised binaries
+	switch mode := flag.Arg(0); mode {
+	case "all":
+		stopAll := startAll(cfg, db)
+		defer stopAll()
+		services = []string{"uploader", "transcoder", "streamer"}
+	case "", "supervise":
+		// The services are stopped when ctx is cancelled
+		ctx, stopServices := context.WithCancel(context.Background())
+		specs := serviceSpecs(cfg)
+		slog.Info("Supervising services", "count", len(specs), "policy", cfg.Supervisor.Policy.String())
+
+		sup := supervisor.New(cfg.Supervisor.Policy, specs)
+		sup.Start(ctx)
+		defer func() {
+			stopServices()
+			sup.Wait()
+		}()
+
+		router.Handle("/services", apiTimeout(servicesHandler(sup))).Methods("GET")
+		services = cfg.Supervisor.Services
+	default:
+		logging.Fatal("Unknown mode, expected supervise or all", "mode", mode)
 	}
 
-	// Start services
-	startServices(services)
-	defer stopServices(services)
+	urls := readinessURLs(cfg)
+	for _, name := range services {
+		if url, ok := urls[name]; ok {
+			checker.AddService(name, url)
+		}
+	}
+
+	router.Handle("/health/live", apiTimeout(health.LiveHandler("main"))).Methods("GET")
+	router.Handle("/health/ready", apiTimeout(health.ReadyHandler(checker))).Methods("GET")
+	router.Handle("/health", apiTimeout(health.ReadyHandler(checker))).Methods("GET")
 
 	// Set up server
-	port := viper.GetString("server.port")
-	if port == "" {
-		port = "8000"
-	}
+	addr := fmt.Sprintf("%s:%d", cfg.Server.Host, cfg.Server.Port)
 
+	// Read and write timeouts are set per route, uploads need far longer than API calls
 	srv := &http.Server{
-		Handler:      router,
-		Addr:         ":" + port,
-		WriteTimeout: 15 * time.Second,
-		ReadTimeout:  15 * time.Second,
+		Handler:           router,
+		Addr:              addr,
+		ReadHeaderTimeout: 15 * time.Second,
+		IdleTimeout:       2 * time.Minute,
 	}
 
 	// Run server in a goroutine
 	go func() {
-		glog.Infof("Main server starting on port %s", port)
+		slog.Info("Main server starting", "addr", addr)
 		if err := srv.ListenAndServe(); err != nil {
-			glog.Errorf("Server error: %v", err)
+			slog.Error("Server error", "error", err)
 		}
 	}()
 
@@ -127,101 +139,62 @@ func main() {
 
 	// Block until signal is received
 	<-c
-	glog.Info("Shutting down...")
+	slog.Info("Shutting down...")
 
 	// Graceful shutdown
-	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
 	defer cancel()
 
-	if err := srv.Shutdown(ctx); err != nil {
-		glog.Errorf("Server shutdown failed: %v", err)
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		slog.Error("Server shutdown failed", "error", err)
 	}
 
-	glog.Info("Server stopped")
+	slog.Info("Server stopped")
 }
 
-// startServices starts all services in goroutines
-func startServices(services []*Service) {
-	for _, service := range services {
-		go startService(service)
-	}
-}
-
-// startService starts a single service and handles its output
-func startService(service *Service) {
-	glog.Infof("Starting %s service", service.Name)
-
-	service.Cmd = exec.Command(service.Command, service.Args...)
-
-	// Set up pipes for stdout and stderr
-	stdout, err := service.Cmd.StdoutPipe()
-	if err != nil {
-		glog.Errorf("Failed to get stdout pipe for %s: %v", service.Name, err)
-		return
-	}
-
-	stderr, err := service.Cmd.StderrPipe()
-	if err != nil {
-		glog.Errorf("Failed to get stderr pipe for %s: %v", service.Name, err)
-		return
-	}
-
-	// Start the service
-	if err := service.Cmd.Start(); err != nil {
-		glog.Errorf("Failed to start %s: %v", service.Name, err)
-		return
-	}
-
-	// Process output in goroutines
-	go processOutput(stdout, service.StdoutLogger)
-	go processOutput(stderr, service.StderrLogger)
-
-	// Wait for the service to complete
-	if err := service.Cmd.Wait(); err != nil {
-		if strings.Contains(err.Error(), "killed") {
-			glog.Infof("%s service was stopped", service.Name)
-		} else {
-			glog.Errorf("%s service exited with error: %v", service.Name, err)
+// serviceSpecs returns the prebuilt service binaries to supervise, found in
+// supervisor.bin_dir under their service name. Each service is given the launcher's
+// config file, so they do not depend on sharing its working directory.
+func serviceSpecs(cfg *config.Config) []supervisor.Spec {
+	var args []string
+	if cfg.File != "" {
+		path, err := filepath.Abs(cfg.File)
+		if err != nil {
+			path = cfg.File
 		}
-	} else {
-		glog.Infof("%s service completed", service.Name)
+		args = []string{"--config", path}
 	}
-}
 
-// processOutput reads from a pipe and writes to a logger
-func processOutput(pipe io.ReadCloser, logger *log.Logger) {
-	scanner := bufio.NewScanner(pipe)
-	for scanner.Scan() {
-		logger.Println(scanner.Text())
+	specs := make([]supervisor.Spec, 0, len(cfg.Supervisor.Services))
+	for _, name := range cfg.Supervisor.Services {
+		specs = append(specs, supervisor.Spec{
+			Name: name,
+			Path: filepath.Join(cfg.Supervisor.BinDir, name),
+			Args: args,
+		})
 	}
+	return specs
 }
 
-// stopServices stops all services
-func stopServices(services []*Service) {
-	var wg sync.WaitGroup
-
-	for _, service := range services {
-		if service.Cmd != nil && service.Cmd.Process != nil {
-			wg.Add(1)
-			go func(s *Service) {
-				defer wg.Done()
-				glog.Infof("Stopping %s service", s.Name)
-				if err := s.Cmd.Process.Kill(); err != nil {
-					glog.Errorf("Failed to stop %s: %v", s.Name, err)
-				}
-			}(service)
-		}
+// readinessURLs returns the base URLs serving each service's health routes.
+// Services without a listener, such as the watcher, are left out.
+func readinessURLs(cfg *config.Config) map[string]string {
+	gw := cfg.GatewayConfig()
+	return map[string]string{
+		"uploader":   gw.UploaderURL,
+		"streamer":   gw.StreamerURL,
+		"transcoder": fmt.Sprintf("http://localhost:%d", cfg.Transcoder.Port),
 	}
-
-	wg.Wait()
-	glog.Info("All services stopped")
 }
 
-// Health check handler
-func healthCheckHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte(`{"status":"ok","message":"Service is healthy"}`))
+// servicesHandler returns the state of the supervised services
+func servicesHandler(sup *supervisor.Supervisor) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"services": sup.Status(),
+		})
+	}
 }
 
 // Info handler returns information about the service
@@ -232,12 +205,23 @@ func infoHandler(w http.ResponseWriter, r *http.Request) {
 		"description": "Open Source Video on Demand Platform",
 		"endpoints": []string{
 			"/health",
+			"/health/live",
+			"/health/ready",
+			"/metrics",
 			"/info",
+			"/services",
 			"/upload",
 			"/videos",
 			"/videos/{id}",
+			"/videos/{id}/history",
+			"/videos/{id}/jobs",
+			"/videos/{id}/schedule",
+			"/videos/{id}/transcode",
 			"/videos/{id}/hls/{filename}",
 			"/videos/{id}/dash/{filename}",
+			"/videos/{id}/mp4/{filename}",
+			"/profiles",
+			"/profiles/{name}",
 		},
 		"services": []string{
 			"uploader",
@@ -5,12 +5,14 @@ const multer = require('multer');
 const { v4: uuidv4 } = require('uuid');
 const path = require('path');
 const dotenv = require('dotenv');
+const axios = require('axios');
 
 // Load environment variables
 dotenv.config();
 
 const app = express();
 const PORT = process.env.API_PORT || 3001;
+const BACKEND_URL = process.env.BACKEND_URL || 'http://localhost:8000';
 
 // Middleware
 app.use(cors());
@@ -75,15 +77,48 @@ app.post('/api/videos/upload', upload.single('video'), (req, res) => {
 });
 
 // Get video status endpoint
-app.get('/api/videos/:id/status', (req, res) => {
-  // This would normally query the Go backend for the video status
-  res.status(200).json({
-    videoId: req.params.id,
-    status: 'processing', // or 'completed', 'failed', etc.
-    progress: Math.floor(Math.random() * 100) // Mock progress
-  });
+app.get('/api/videos/:id/status', async (req, res, next) => {
+  try {
+    const response = await axios.get(`${BACKEND_URL}/videos/${encodeURIComponent(req.params.id)}`);
+    res.status(200).json({
+      videoId: response.data.videoId,
+      status: response.data.status
+    });
+  } catch (error) {
+    if (error.response && error.response.status === 404) {
+      return res.status(404).json({ error: 'Video not found' });
+    }
+    next(error);
+  }
 });
 
+// Relay the backend's Server-Sent Events with state transitions and transcode progress
+app.get('/api/videos/:id/events', (req, res) => relayEvents(`/videos/${encodeURIComponent(req.params.id)}/events`, req, res));
+app.get('/api/events', (req, res) => relayEvents('/events', req, res));
+
+async function relayEvents(path, req, res) {
+  try {
+    const upstream = await axios.get(`${BACKEND_URL}${path}`, {
+      responseType: 'stream',
+      headers: { Accept: 'text/event-stream' }
+    });
+
+    res.status(200);
+    res.set({
+      'Content-Type': 'text/event-stream',
+      'Cache-Control': 'no-cache',
+      'X-Accel-Buffering': 'no'
+    });
+    res.flushHeaders();
+
+    upstream.data.pipe(res);
+    req.on('close', () => upstream.data.destroy());
+  } catch (error) {
+    const status = error.response ? error.response.status : 502;
+    res.status(status).json({ error: 'Event stream unavailable' });
+  }
+}
+
 // Error handling middleware
 app.use((err, req, res, next) => {
   console.error(err.stack);
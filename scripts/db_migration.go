@@ -3,10 +3,12 @@ package main
 import (
 	"context"
 	"flag"
+	"fmt"
 	"log"
 	"time"
 
 	"github.com/falcon/backend/internal/database"
+	"github.com/falcon/backend/internal/id"
 	"github.com/spf13/viper"
 )
 
@@ -25,7 +27,7 @@ func init() {
 
 func main() {
 	// Parse command line flags
-	reset := flag.Bool("reset", false, "Drop and recreate all tables")
+	reset := flag.Bool("reset", false, "Revert all migrations before applying them again")
 	seed := flag.Bool("seed", false, "Seed the database with sample data")
 	flag.Parse()
 
@@ -42,16 +44,16 @@ func main() {
 
 	// Reset database if specified
 	if *reset {
-		log.Println("Dropping all tables...")
-		if err := dropTables(ctx, db); err != nil {
-			log.Fatalf("Failed to drop tables: %v", err)
+		log.Println("Reverting all migrations...")
+		if err := db.MigrateTo(ctx, 0); err != nil {
+			log.Fatalf("Failed to revert migrations: %v", err)
 		}
 	}
 
-	// Create tables
-	log.Println("Creating tables...")
-	if err := db.CreateTables(ctx); err != nil {
-		log.Fatalf("Failed to create tables: %v", err)
+	// Apply migrations
+	log.Println("Applying migrations...")
+	if err := db.Migrate(ctx); err != nil {
+		log.Fatalf("Failed to apply migrations: %v", err)
 	}
 
 	// Seed database if specified
@@ -65,26 +67,18 @@ func main() {
 	log.Println("Migration completed successfully")
 }
 
-// dropTables drops all tables in the database
-func dropTables(ctx context.Context, db *database.Database) error {
-	// This is a simplified implementation - in a real system, you would handle constraints and foreign keys
-	_, err := db.Pool().Exec(ctx, `
-		DROP TABLE IF EXISTS video_streams CASCADE;
-		DROP TABLE IF EXISTS videos CASCADE;
-	`)
-	return err
-}
-
 // seedDatabase adds sample data to the database
 func seedDatabase(ctx context.Context, db *database.Database) error {
 	// Add a sample video
 	now := time.Now()
+	videoID := id.NewVideoID()
 	sampleVideo := &database.Video{
-		ID:              "sample-video-1",
+		ID:              videoID,
+		Slug:            id.NewSlug(),
 		Title:           "Sample Video",
 		OriginalName:    "sample.mp4",
-		OriginalPath:    "uploads/sample-video-1/sample.mp4",
-		ProcessingState: "completed",
+		OriginalPath:    fmt.Sprintf("uploads/%s/%s.mp4", videoID, videoID),
+		ProcessingState: database.StateCompleted,
 		Duration:        120.5,
 		Size:            15728640, // 15MB
 		ContentType:     "video/mp4",
@@ -111,12 +105,12 @@ func seedDatabase(ctx context.Context, db *database.Database) error {
 
 	for i, res := range resolutions {
 		stream := &database.VideoStream{
-			ID:          sampleVideo.ID + "-v" + string(i+'0'),
+			ID:          fmt.Sprintf("%s-v%d", videoID, i),
 			VideoID:     sampleVideo.ID,
 			Resolution:  res.resolution,
 			Bitrate:     res.bitrate,
 			Format:      "hls",
-			Path:        "videos/sample-video-1/hls/sample-video-1_v" + string(i+'0') + ".m3u8",
+			Path:        fmt.Sprintf("videos/%s/hls/%s_v%d.m3u8", videoID, videoID, i),
 			Size:        int64(5000000 - i*1000000), // Decreasing size for lower resolutions
 			SegmentSize: 10,
 			CreatedAt:   now,
@@ -128,4 +122,4 @@ func seedDatabase(ctx context.Context, db *database.Database) error {
 	}
 
 	return nil
-} 
\ No newline at end of file
+}